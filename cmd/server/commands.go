@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/internal/config"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/jessevdk/go-flags"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commands are subcommands that run instead of the server, e.g. `lmt lndconnect <uri>`.
+var commands = map[string]func(args []string) error{
+	"lndconnect": runLNDConnect,
+	"export":     runExport,
+	"config":     runConfig,
+}
+
+// lndConnectOptions are the flags of `lmt lndconnect`. The proxy defaults to
+// the one in lmt.conf, if there is one.
+type lndConnectOptions struct {
+	ConfigFile string `short:"c" long:"config" env:"LMT_CONFIG_FILE" description:"Path to config file" default:"lmt.conf"`
+	SocksProxy string `long:"socks-proxy" env:"LND_SOCKS_PROXY" description:"Connect through this SOCKS5 proxy (default: lnd.socks-proxy)"`
+}
+
+// runLNDConnect checks that lmt can reach LND with an lndconnect URI, the
+// same way the server would. The URI is taken from the first argument, or
+// LND_CONNECT if omitted.
+func runLNDConnect(args []string) error {
+	var opts lndConnectOptions
+	args, err := flags.NewParser(&opts, flags.Default).ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	uri := os.Getenv("LND_CONNECT")
+	if len(args) > 0 {
+		uri = args[0]
+	}
+	if uri == "" {
+		return fmt.Errorf("usage: lmt lndconnect <lndconnect://host:port?cert=...&macaroon=...> [-c lmt.conf] [--socks-proxy host:port]")
+	}
+
+	// The URI is often checked before lmt is configured.
+	cfg := &config.Config{}
+	if _, err := os.Stat(opts.ConfigFile); err == nil {
+		if cfg, err = config.Load([]string{"--config", opts.ConfigFile}); err != nil {
+			return err
+		}
+	}
+	if opts.SocksProxy != "" {
+		cfg.LND.SocksProxy = opts.SocksProxy
+	}
+
+	lc, err := lndrest.ParseLNDConnectURI(uri)
+	if err != nil {
+		return err
+	}
+
+	client, err := lc.NewClient(lndClientOptions(cfg)...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	info, err := client.GetInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to reach LND at %s: %w", lc.Host, err)
+	}
+
+	fmt.Printf("Connected to %s (%s)\n", info.Alias, info.IdentityPubkey)
+	fmt.Printf("  version:         %s\n", info.Version)
+	fmt.Printf("  block height:    %d\n", info.BlockHeight)
+	fmt.Printf("  synced to chain: %t\n", info.SyncedToChain)
+	fmt.Printf("  active channels: %d\n", info.NumActiveChannels)
+
+	if perms, err := lndrest.MacaroonPermissions(lc.Macaroon); err == nil {
+		missing := lndrest.MissingPermissions(perms, []lndrest.MacaroonPermission{
+			{Entity: "invoices", Action: "read"},
+			{Entity: "invoices", Action: "write"},
+		})
+		for _, p := range missing {
+			fmt.Printf("Warning: macaroon lacks %s, which lmt needs to receive payments\n", p)
+		}
+	}
+
+	return nil
+}
+
+// exportOptions are the flags of `lmt export`. The data directory, currency
+// and price file default to those in lmt.conf.
+type exportOptions struct {
+	ConfigFile string `short:"c" long:"config" env:"LMT_CONFIG_FILE" description:"Path to config file" default:"lmt.conf"`
+	DataDir    string `long:"datadir" description:"lmt data directory (default: general.datadir)"`
+	Format     string `long:"format" description:"Output format" choice:"csv" choice:"json" choice:"koinly" default:"csv"`
+	From       string `long:"from" description:"Only payments settled at or after this time (RFC 3339 or unix seconds)"`
+	Until      string `long:"until" description:"Only payments settled before this time (RFC 3339 or unix seconds)"`
+	Currency   string `long:"currency" description:"Fiat currency to value payments in (default: fiat.currency)"`
+	PriceFile  string `long:"price-file" description:"CSV file of BTC prices (date,currency,price) (default: fiat.price-file)"`
+	Output     string `short:"o" long:"output" description:"Write to this file instead of stdout"`
+}
+
+// runExport writes the settled payments from the invoice history as a ledger
+// for accounting. It only reads the history, so it can run next to lmt.
+func runExport(args []string) error {
+	var opts exportOptions
+	if _, err := flags.NewParser(&opts, flags.Default).ParseArgs(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load([]string{"--config", opts.ConfigFile})
+	if err != nil {
+		return err
+	}
+	if opts.DataDir == "" {
+		opts.DataDir = cfg.General.DataDir
+	}
+	if opts.Currency == "" {
+		opts.Currency = cfg.Fiat.Currency
+	}
+	if opts.PriceFile == "" {
+		opts.PriceFile = cfg.Fiat.PriceFile
+	}
+
+	from, err := app.ParseTime(opts.From)
+	if err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	until, err := app.ParseTime(opts.Until)
+	if err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	var prices fiat.PriceSource
+	if opts.PriceFile != "" {
+		path, err := config.ExpandPath(opts.PriceFile)
+		if err != nil {
+			return err
+		}
+		if prices, err = fiat.LoadPriceFile(path); err != nil {
+			return err
+		}
+	}
+
+	dataDir, err := config.ExpandPath(opts.DataDir)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dataDir, "invoices.jsonl")
+	log, err := store.OpenJSONLogReadOnly(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no invoice history at %s, check --datadir", path)
+	} else if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	history, err := app.ReadInvoiceHistory(log)
+	if err != nil {
+		return err
+	}
+
+	records, _ := history.Query(app.InvoiceQuery{})
+	settled := app.SettledBetween(records, from, until)
+
+	entries, err := app.BuildLedger(context.Background(), settled, prices, strings.ToUpper(opts.Currency))
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if opts.Output != "" {
+		if out, err = os.Create(opts.Output); err != nil {
+			return fmt.Errorf("failed to create %s: %w", opts.Output, err)
+		}
+		defer out.Close()
+	}
+
+	return app.WriteLedger(out, app.LedgerFormat(opts.Format), entries)
+}
+
+// runConfig runs `lmt config check`, which validates the configuration the
+// server would start with, taking the same flags.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: lmt config check [-c lmt.conf] [flags]")
+	}
+
+	cfg, err := config.Load(args[1:])
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Configuration is valid (config file: %s)\n", cfg.ConfigFile)
+	return nil
+}
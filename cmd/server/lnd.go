@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/config"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+func ProvideLNDClient(cfg *config.Config) (*lndrest.Client, error) {
+	if cfg.LND.LNDConnect != "" {
+		lc, err := lndrest.ParseLNDConnectURI(cfg.LND.LNDConnect)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkMacaroonPermissions(lc.Macaroon, requiredPermissions(cfg)); err != nil {
+			return nil, err
+		}
+
+		return lc.NewClient(lndClientOptions(cfg)...)
+	}
+
+	macaroon, err := loadMacaroon(cfg.LND)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMacaroonPermissions(macaroon, requiredPermissions(cfg)); err != nil {
+		return nil, err
+	}
+
+	certPath, err := config.ExpandPath(cfg.LND.TLSCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := lndClientOptions(cfg)
+	if cfg.LND.TLSCertFingerprint != "" {
+		opts = append(opts, lndrest.WithCertFingerprint(cfg.LND.TLSCertFingerprint))
+	}
+
+	// Config.Validate only lets this through with lnd.insecure-skip-verify.
+	if certPath == "" && cfg.LND.TLSCertFingerprint == "" {
+		slog.Warn("lnd.insecure-skip-verify is set, the LND TLS certificate will not be verified")
+	}
+
+	return lndrest.NewClient(cfg.LND.Host, hex.EncodeToString(macaroon), certPath, opts...)
+}
+
+// lndClientOptions returns the client options shared by both ways of
+// configuring the LND connection.
+func lndClientOptions(cfg *config.Config) []lndrest.ClientOption {
+	opts := []lndrest.ClientOption{lndrest.WithRequestObserver(metrics.ObserveLNDRequest)}
+	if cfg.LND.SocksProxy != "" {
+		opts = append(opts, lndrest.WithProxy(cfg.LND.SocksProxy))
+	}
+	return opts
+}
+
+// loadMacaroon returns the raw macaroon, either given inline or read from macaroonpath.
+func loadMacaroon(cfg config.LNDConfig) ([]byte, error) {
+	if cfg.Macaroon != "" {
+		macaroon, err := lndrest.DecodeMacaroon(cfg.Macaroon)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lnd.macaroon: %w", err)
+		}
+		return macaroon, nil
+	}
+
+	macaroonPath, err := config.ExpandPath(cfg.MacaroonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	macaroon, err := os.ReadFile(macaroonPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read macaroon file: %w", err)
+	}
+	return macaroon, nil
+}
+
+// requiredPermissions lists the macaroon permissions needed by the enabled features.
+func requiredPermissions(cfg *config.Config) []lndrest.MacaroonPermission {
+	perms := []lndrest.MacaroonPermission{
+		{Entity: "invoices", Action: "read"},
+		{Entity: "invoices", Action: "write"},
+	}
+
+	if cfg.Nostr.Enabled {
+		perms = append(perms, lndrest.MacaroonPermission{Entity: "offchain", Action: "write"})
+	}
+
+	if cfg.LNURL.DynamicMaxSendable {
+		perms = append(perms, lndrest.MacaroonPermission{Entity: "offchain", Action: "read"})
+	}
+
+	return perms
+}
+
+// checkMacaroonPermissions fails if the macaroon lacks any required permission.
+func checkMacaroonPermissions(macaroon []byte, required []lndrest.MacaroonPermission) error {
+	granted, err := lndrest.MacaroonPermissions(macaroon)
+	if err != nil {
+		slog.Warn("Could not read macaroon permissions, skipping permission check", "error", err)
+		return nil
+	}
+
+	missing := lndrest.MissingPermissions(granted, required)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(missing))
+	for i, p := range missing {
+		names[i] = p.String()
+	}
+	return fmt.Errorf("LND macaroon is missing required permissions: %s", strings.Join(names, ", "))
+}
@@ -2,142 +2,445 @@ package main
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"github.com/asheswook/lightning-multitool/internal/app"
 	"github.com/asheswook/lightning-multitool/internal/config"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/asheswook/lightning-multitool/internal/health"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
 	"github.com/asheswook/lightning-multitool/internal/server"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/internal/tor"
+	"github.com/asheswook/lightning-multitool/internal/webhook"
 	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
 	"github.com/asheswook/lightning-multitool/pkg/oksusu"
-	"github.com/nbd-wtf/go-nostr/nip19"
 	"go.uber.org/dig"
 	"log/slog"
+	"net"
 	"os"
-	"os/user"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-func ProvideLNDClient(cfg *config.Config) (*lndrest.Client, error) {
-	macaroonPath := cfg.LND.MacaroonPath
-	if strings.HasPrefix(macaroonPath, "~/") {
-		usr, err := user.Current()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get current user: %w", err)
-		}
-		macaroonPath = filepath.Join(usr.HomeDir, macaroonPath[2:])
+func ProvideZapMonitor(signer nostrpkg.Signer, invoiceWatcher *app.InvoiceWatcher, settings *app.LiveSettings) app.ZapMonitor {
+	return app.NewZapMonitor(
+		invoiceWatcher,
+		signer,
+		settings,
+	)
+}
+
+func ProvideHoldInvoiceWorkflow(cfg *config.Config, lndClient *lndrest.Client) (*app.HoldInvoiceWorkflow, error) {
+	if cfg.Hold.ApprovalURL == "" {
+		return nil, nil
 	}
 
-	macaroonBytes, err := os.ReadFile(macaroonPath)
+	log, err := openDataLog(cfg, "hold_invoices.jsonl")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read macaroon file: %w", err)
+		return nil, err
 	}
-	macaroon := hex.EncodeToString(macaroonBytes)
 
-	return lndrest.NewClient(cfg.LND.Host, macaroon, "")
+	return app.NewHoldInvoiceWorkflow(
+		lndClient,
+		app.NewWebhookApproval(cfg.Hold.ApprovalURL),
+		cfg.Hold.Timeout,
+		log,
+	)
 }
 
-func ProvideZapMonitor(cfg *config.Config, lndClient *lndrest.Client) app.ZapMonitor {
-	var pubkey, privkey string
-	if cfg.Nostr.Enabled {
-		_, vpub, err := nip19.Decode(cfg.Nostr.PublicKey)
-		if err != nil {
-			panic(err)
-		}
-		_, vpriv, err := nip19.Decode(cfg.Nostr.PrivateKey)
-		if err != nil {
-			panic(err)
-		}
-		pubkey = vpub.(string)
-		privkey = vpriv.(string)
+func ProvideMaxSendable(cfg *config.Config, lndClient *lndrest.Client, settings *app.LiveSettings) app.MaxSendableProvider {
+	if !cfg.LNURL.DynamicMaxSendable {
+		return settings
 	}
 
-	return app.NewZapMonitor(
+	return app.NewLiquidityMaxSendable(
 		lndClient,
-		pubkey,
-		privkey,
-		cfg.Nostr.Relays,
+		settings,
+		cfg.LNURL.LiquidityCacheTTL,
 	)
 }
 
-func ProvideLNURLHandler(cfg *config.Config) app.LNURLHandler {
-	var nostrPublicKey string
-	if cfg.Nostr.Enabled {
-		_, vpub, err := nip19.Decode(cfg.Nostr.PublicKey)
-		if err != nil {
-			panic(err)
-		}
-		nostrPublicKey = vpub.(string)
+func ProvideCurrencyConverter(cfg *config.Config, prices fiat.PriceSource) (*app.CurrencyConverter, error) {
+	if len(cfg.LNURL.Currencies) == 0 {
+		return nil, nil
+	}
+	if prices == nil {
+		return nil, fmt.Errorf("lnurl.currencies requires fiat.price-file for exchange rates")
 	}
 
+	return app.NewCurrencyConverter(prices, cfg.LNURL.Currencies, cfg.LNURL.CurrencySpread, cfg.LNURL.MaxPriceAge), nil
+}
+
+func ProvideLNURLHandler(cfg *config.Config, signer nostrpkg.Signer, maxSendable app.MaxSendableProvider, settings *app.LiveSettings, converter *app.CurrencyConverter, onion *app.OnionAddress) app.LNURLHandler {
 	return app.NewLNURLHandler(
 		cfg.General.Username,
 		cfg.LNURL.Domain,
-		nostrPublicKey,
-		cfg.LNURL.MaxSendableMsat,
-		cfg.LNURL.MinSendableMsat,
-		cfg.LNURL.CommentAllowed,
+		nostrPublicKey(signer),
+		maxSendable,
+		settings,
+		converter,
+		onion,
 	)
 }
 
-func ProvideLNURLInvoiceHandler(cfg *config.Config, lndClient *lndrest.Client, zapMonitor app.ZapMonitor) app.LNURLInvoiceHandler {
-	var nostrPublicKey string
-	if cfg.Nostr.Enabled {
-		_, vpub, err := nip19.Decode(cfg.Nostr.PublicKey)
-		if err != nil {
-			panic(err)
-		}
-		nostrPublicKey = vpub.(string)
-	}
-
-	return app.NewLNURLInvoiceHandler(
+func ProvideInvoiceIssuer(cfg *config.Config, signer nostrpkg.Signer, lndClient *lndrest.Client, holdWorkflow *app.HoldInvoiceWorkflow, zapMonitor app.ZapMonitor, paymentTracker *app.PaymentTracker) app.InvoiceIssuer {
+	return app.NewInvoiceIssuer(
 		lndClient,
+		holdWorkflow,
 		zapMonitor,
+		paymentTracker,
+		nostrPublicKey(signer),
+		cfg.RateLimit.MaxOutstanding,
+		cfg.LNURL.InvoiceExpiry,
+	)
+}
+
+func ProvideCallbackLimiter(cfg *config.Config) (*server.CallbackLimiter, error) {
+	return server.NewCallbackLimiter(
 		cfg.General.Username,
-		nostrPublicKey,
+		cfg.RateLimit.IPRate,
+		cfg.RateLimit.IPBurst,
+		cfg.RateLimit.UserRate,
+		cfg.RateLimit.UserBurst,
+		cfg.RateLimit.TrustedProxies,
 	)
 }
 
-func ProvideNostrHandler(cfg *config.Config) app.NostrHandler {
-	var nostrPublicKey string
-	if cfg.Nostr.Enabled {
-		_, vpub, err := nip19.Decode(cfg.Nostr.PublicKey)
+func ProvideLNURLInvoiceHandler(cfg *config.Config, issuer app.InvoiceIssuer, converter *app.CurrencyConverter, settings *app.LiveSettings, maxSendable app.MaxSendableProvider) app.LNURLInvoiceHandler {
+	return app.NewLNURLInvoiceHandler(issuer, converter, settings, maxSendable, cfg.General.Username)
+}
+
+func ProvideWebhookDispatcher(cfg *config.Config) (*webhook.Dispatcher, error) {
+	endpoints := make([]webhook.Endpoint, 0, len(cfg.Webhook.Endpoints))
+	for _, s := range cfg.Webhook.Endpoints {
+		endpoint, err := webhook.ParseEndpoint(s)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		nostrPublicKey = vpub.(string)
+		endpoints = append(endpoints, endpoint)
+	}
+
+	log, err := openDataLog(cfg, "webhooks.jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher, err := webhook.NewDispatcher(endpoints, log, cfg.Webhook.MaxAttempts)
+	if err != nil {
+		return nil, err
 	}
 
-	return app.NewNostrHandler(cfg.General.Username, nostrPublicKey)
+	// Endpoints added through the admin API are kept next to the deliveries.
+	managed, err := openDataLog(cfg, "webhook_endpoints.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	if err := dispatcher.ManageEndpoints(managed); err != nil {
+		return nil, err
+	}
+	return dispatcher, nil
 }
 
-func ProvideOksusuHandler(cfg *config.Config, lndClient *lndrest.Client, zapMonitor app.ZapMonitor) app.OksusuHandler {
-	var nostrPublicKey string
-	if cfg.Nostr.Enabled {
-		_, vpub, err := nip19.Decode(cfg.Nostr.PublicKey)
+func ProvideDMNotifier(cfg *config.Config, signer nostrpkg.Signer, settings *app.LiveSettings) (*app.DMNotifier, error) {
+	if !cfg.Nostr.Enabled || len(cfg.Nostr.Notify) == 0 {
+		return nil, nil
+	}
+
+	owners := make([]string, 0, len(cfg.Nostr.Notify))
+	for _, npub := range cfg.Nostr.Notify {
+		owner, err := nostrpkg.ParsePublicKey(npub)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid nostr.notify %q: %w", npub, err)
+		}
+		owners = append(owners, owner)
+	}
+
+	return app.NewDMNotifier(
+		signer,
+		owners,
+		settings,
+		app.DMProtocol(cfg.Nostr.NotifyProtocol),
+		cfg.Nostr.NotifyMinAmount,
+		cfg.Nostr.NotifyBatchWindow,
+	), nil
+}
+
+func ProvideInvoiceHistory(cfg *config.Config) (*app.InvoiceHistory, error) {
+	log, err := openDataLog(cfg, "invoices.jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	return app.NewInvoiceHistory(log)
+}
+
+func ProvideInvoiceIndexes(cfg *config.Config) (*app.InvoiceIndexes, error) {
+	log, err := openDataLog(cfg, "invoice_indexes.jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	return app.NewInvoiceIndexes(log)
+}
+
+// openDataLog opens a JSON log in the data directory.
+func openDataLog(cfg *config.Config, name string) (*store.JSONLog, error) {
+	dataDir, err := config.ExpandPath(cfg.General.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.OpenJSONLog(filepath.Join(dataDir, name))
+}
+
+func ProvidePayPage(cfg *config.Config, issuer app.InvoiceIssuer, maxSendable app.MaxSendableProvider, settings *app.LiveSettings, events *app.PaymentEvents) (*server.PayPage, error) {
+	if !cfg.PayPage.Enabled {
+		return nil, nil
+	}
+
+	var amounts []int64
+	for _, field := range strings.Split(cfg.PayPage.Amounts, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		amount, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("invalid paypage.amounts entry %q", field)
+		}
+		amounts = append(amounts, amount)
+	}
+
+	avatar, err := config.ExpandPath(cfg.PayPage.Avatar)
+	if err != nil {
+		return nil, err
+	}
+	if avatar != "" {
+		if _, err := os.Stat(avatar); err != nil {
+			return nil, fmt.Errorf("paypage.avatar: %w", err)
+		}
+	}
+
+	return server.NewPayPage(server.PayPageOptions{
+		Username:    cfg.General.Username,
+		Domain:      cfg.LNURL.Domain,
+		Description: cfg.PayPage.Description,
+		AvatarFile:  avatar,
+		Amounts:     amounts,
+	}, issuer, maxSendable, settings, events), nil
+}
+
+func ProvideTLS(cfg *config.Config) (*server.TLS, error) {
+	if !cfg.Server.TLSEnabled() {
+		return nil, nil
+	}
+
+	opts := server.TLSOptions{
+		CertFile:         cfg.Server.TLSCert,
+		KeyFile:          cfg.Server.TLSKey,
+		ACME:             cfg.Server.ACME,
+		ACMEEmail:        cfg.Server.ACMEEmail,
+		ACMEDirectoryURL: cfg.Server.ACMEDirectoryURL,
+		Domain:           cfg.LNURL.Domain,
+	}
+	if cfg.Server.RedirectPort != "" {
+		opts.RedirectAddr = cfg.Server.Host + ":" + cfg.Server.RedirectPort
+	}
+
+	if opts.ACME {
+		cacheDir := cfg.Server.ACMECache
+		if cacheDir == "" {
+			cacheDir = filepath.Join(cfg.General.DataDir, "acme")
 		}
-		nostrPublicKey = vpub.(string)
+
+		var err error
+		if opts.ACMECacheDir, err = config.ExpandPath(cacheDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return server.NewTLS(opts)
+}
+
+// onionRetryDelay is how long to wait before publishing the onion service
+// again after the tor control connection was lost.
+const onionRetryDelay = 10 * time.Second
+
+// publishOnion serves the router on a local listener and publishes it as an
+// onion service on port 80. The onion service lives as long as lmt keeps the
+// control connection open, and is published again with the same address when
+// it is lost. The address is set in onionAddress before the first request is
+// served, and whether it is published is reported to checker.
+func publishOnion(ctx context.Context, cfg *config.Config, router server.Router, onionAddress *app.OnionAddress, checker *health.Checker, errCh chan<- error) (string, error) {
+	keyFile := cfg.Tor.KeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(cfg.General.DataDir, "onion_key")
+	}
+	keyFile, err := config.ExpandPath(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	controller, err := tor.Dial(cfg.Tor.Control, cfg.Tor.Password)
+	if err != nil {
+		ln.Close()
+		return "", err
+	}
+
+	onion, err := controller.PublishOnion(keyFile, 80, ln.Addr().String())
+	if err != nil {
+		controller.Close()
+		ln.Close()
+		return "", err
 	}
+	onionAddress.Set(onion)
+
+	var published atomic.Bool
+	published.Store(true)
+	checker.Add("tor", false, health.ConnectionCheck(published.Load, "the onion service is not published"))
+
+	go func() {
+		errCh <- router.ServeOnion(ln)
+	}()
+	go keepOnion(ctx, cfg, controller, keyFile, ln.Addr().String(), &published)
+	return onion, nil
+}
+
+// keepOnion publishes the onion service again whenever the control connection
+// is lost, e.g. when tor restarts, since tor removes the service with it.
+func keepOnion(ctx context.Context, cfg *config.Config, controller *tor.Controller, keyFile, target string, published *atomic.Bool) {
+	for {
+		lost := make(chan error, 1)
+		go func() { lost <- controller.Wait() }()
+
+		select {
+		case <-ctx.Done():
+			controller.Close()
+			return
+		case err := <-lost:
+			controller.Close()
+			published.Store(false)
+			slog.Warn("Lost the tor control connection, publishing the onion service again", "error", err, "retry_in", onionRetryDelay)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(onionRetryDelay):
+			}
+
+			var err error
+			if controller, err = tor.Dial(cfg.Tor.Control, cfg.Tor.Password); err == nil {
+				if _, err = controller.PublishOnion(keyFile, 80, target); err != nil {
+					controller.Close()
+				}
+			}
+			if err == nil {
+				break
+			}
+			slog.Warn("Failed to publish the onion service, retrying", "error", err, "retry_in", onionRetryDelay)
+		}
 
+		published.Store(true)
+		slog.Info("Published onion service again")
+	}
+}
+
+func ProvideFiatPrices(cfg *config.Config) (fiat.PriceSource, error) {
+	if cfg.Fiat.PriceFile == "" {
+		return nil, nil
+	}
+
+	path, err := config.ExpandPath(cfg.Fiat.PriceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiat.LoadPriceFile(path)
+}
+
+func ProvideHealthChecker(cfg *config.Config, lndClient *lndrest.Client, invoiceWatcher *app.InvoiceWatcher, settings *app.LiveSettings, signer nostrpkg.Signer) *health.Checker {
+	checker := health.NewChecker(5 * time.Second)
+	checker.Add("lnd", true, health.LNDCheck(lndClient))
+	checker.Add("invoice_subscription", false, health.SubscriptionCheck(invoiceWatcher.State))
+	if cfg.Nostr.Enabled {
+		checker.Add("nostr_relays", false, health.Cached(time.Minute, health.RelayCheck(settings.Relays)))
+	}
+	if signer != nil {
+		// Without a signer, payments still arrive, only zap receipts and DMs don't.
+		checker.Add("nostr_signer", false, health.SignerCheck(signer))
+	}
+	return checker
+}
+
+func ProvideAPI(cfg *config.Config, checker *health.Checker, dispatcher *webhook.Dispatcher, history *app.InvoiceHistory, reloader server.Reloader, prices fiat.PriceSource) *server.API {
+	return server.NewAPI(cfg.API.Token, checker, dispatcher, history, reloader, prices, cfg.Fiat.Currency)
+}
+
+func ProvideNostrHandler(cfg *config.Config, signer nostrpkg.Signer) app.NostrHandler {
+	return app.NewNostrHandler(cfg.General.Username, nostrPublicKey(signer))
+}
+
+func ProvideOksusuHandler(cfg *config.Config, signer nostrpkg.Signer, maxSendable app.MaxSendableProvider, settings *app.LiveSettings, issuer app.InvoiceIssuer) app.OksusuHandler {
 	return app.NewOksusuHandler(
 		cfg.General.Username,
 		cfg.Oksusu.Server,
-		nostrPublicKey,
-		cfg.LNURL.MaxSendableMsat,
-		cfg.LNURL.MinSendableMsat,
-		cfg.LNURL.CommentAllowed,
-		lndClient,
-		zapMonitor,
+		nostrPublicKey(signer),
+		maxSendable,
+		settings,
+		issuer,
 	)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Check the whole config up front, so mistakes are reported together
+	// instead of as the first provider that trips over them.
+	cfg, err := config.NewConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	container := dig.New()
 
-	if err := container.Provide(config.NewConfig); err != nil {
+	if err := container.Provide(func() *config.Config { return cfg }); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideNostrSigner); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideSettings); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideReloader); err != nil {
 		panic(err)
 	}
 
@@ -145,14 +448,58 @@ func main() {
 		panic(err)
 	}
 
+	if err := container.Provide(ProvideInvoiceIndexes); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(app.NewInvoiceWatcher); err != nil {
+		panic(err)
+	}
+
 	if err := container.Provide(ProvideZapMonitor); err != nil {
 		panic(err)
 	}
 
+	if err := container.Provide(ProvideHoldInvoiceWorkflow); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideMaxSendable); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideCurrencyConverter); err != nil {
+		panic(err)
+	}
+
 	if err := container.Provide(ProvideLNURLHandler); err != nil {
 		panic(err)
 	}
 
+	if err := container.Provide(app.NewPaymentTracker); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideInvoiceIssuer); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideWebhookDispatcher); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideInvoiceHistory); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideFiatPrices); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(ProvideDMNotifier); err != nil {
+		panic(err)
+	}
+
 	if err := container.Provide(ProvideLNURLInvoiceHandler); err != nil {
 		panic(err)
 	}
@@ -161,54 +508,133 @@ func main() {
 		panic(err)
 	}
 
+	if err := container.Provide(ProvideCallbackLimiter); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(app.NewOnionAddress); err != nil {
+		panic(err)
+	}
+
+	if err := container.Provide(app.NewPaymentEvents); err != nil {
+		panic(err)
+	}
+	if err := container.Provide(ProvidePayPage); err != nil {
+		panic(err)
+	}
 	if err := container.Provide(server.NewRouter); err != nil {
 		panic(err)
 	}
 
-	if err := container.Provide(server.NewAPI); err != nil {
+	if err := container.Provide(ProvideHealthChecker); err != nil {
 		panic(err)
 	}
 
-	if err := container.Provide(ProvideOksusuHandler); err != nil {
+	if err := container.Provide(ProvideAPI); err != nil {
+		panic(err)
+	}
+	if err := container.Provide(ProvideTLS); err != nil {
 		panic(err)
 	}
 
-	slog.Info("Test1")
+	if err := container.Provide(ProvideOksusuHandler); err != nil {
+		panic(err)
+	}
 
-	if err := container.Invoke(func(cfg *config.Config, router server.Router, handler app.OksusuHandler, api *server.API) error {
-		slog.Info("Invoke")
+	if err := container.Invoke(func(cfg *config.Config, router server.Router, handler app.OksusuHandler, api *server.API, invoiceWatcher *app.InvoiceWatcher, paymentTracker *app.PaymentTracker, dispatcher *webhook.Dispatcher, dmNotifier *app.DMNotifier, history *app.InvoiceHistory, checker *health.Checker, tlsServer *server.TLS, paymentEvents *app.PaymentEvents, reloader server.Reloader, holdWorkflow *app.HoldInvoiceWorkflow, onionAddress *app.OnionAddress) error {
 		go func() {
-			slog.Info("Test2")
 			if err := api.ListenAndServe("0.0.0.0" + ":" + cfg.API.Port); err != nil {
 				panic(err)
 			}
-
-			slog.Info("Test3")
 		}()
 
-		if cfg.Oksusu.Enabled {
-			// Oksu Connect Mode
-			if cfg.Oksusu.Token == "" {
-				return fmt.Errorf("oksu.token must be set when oksu.enabled is true")
-			}
-			slog.Info("Starting in Oksusu Connect mode")
-			client := oksusu.NewClient(cfg.Oksusu.Server, cfg.Oksusu.Token, handler)
-
-			// Run in a loop to handle automatic reconnections
-			for {
-				err := client.ConnectAndServe(context.Background())
-				if err != nil {
-					slog.Error("Oksu client disconnected with error", "error", err)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		go invoiceWatcher.Run(ctx)
+		go reloadOnSIGHUP(ctx, reloader)
+		if holdWorkflow != nil {
+			holdWorkflow.Resume(ctx)
+		}
+
+		paymentTracker.AddListener(history)
+		paymentTracker.Resume(history.Open())
+		paymentTracker.AddListener(app.NewWebhookNotifier(dispatcher))
+		paymentTracker.AddListener(paymentEvents)
+		if dmNotifier != nil {
+			paymentTracker.AddListener(dmNotifier)
+		}
+		go paymentTracker.Run(ctx)
+		go dispatcher.Run(ctx)
+
+		// All transports share the same handlers, and therefore the same
+		// LND client and zap monitor. Whichever one fails first stops lmt.
+		errCh := make(chan error, 3)
+
+		if cfg.Server.Enabled || !cfg.Oksusu.Enabled {
+			slog.Info("Starting standalone web server", "domain", cfg.LNURL.Domain)
+			go func() {
+				addr := cfg.Server.Host + ":" + cfg.Server.Port
+				if tlsServer != nil {
+					errCh <- router.ListenAndServeTLS(addr, tlsServer)
+					return
 				}
-				slog.Info("Attempting to reconnect in 10 seconds...")
-				time.Sleep(10 * time.Second)
+				errCh <- router.ListenAndServe(addr)
+			}()
+		}
+
+		if cfg.Tor.Enabled {
+			onion, err := publishOnion(ctx, cfg, router, onionAddress, checker, errCh)
+			if err != nil {
+				return err
 			}
-		} else {
-			// Standalone Web Server Mode
-			slog.Info("Starting in standard web server mode")
-			return router.ListenAndServe(cfg.Server.Host + ":" + cfg.Server.Port)
+			slog.Info("Published onion service", "address", "http://"+onion)
+		}
+
+		if cfg.Oksusu.Enabled {
+			slog.Info("Starting Oksu Connect", "server", cfg.Oksusu.Server)
+			client := oksusu.NewClient(cfg.Oksusu.Server, cfg.Oksusu.Token, handler, cfg.Oksusu.MaxConcurrent)
+			// Without the standalone server, Oksu Connect is the only way to get paid.
+			checker.Add("oksusu", !cfg.Server.Enabled, health.ConnectionCheck(client.Connected, "not connected to the Oksu server"))
+			client.OnConnectionChange(func(connected bool) {
+				if connected {
+					metrics.OksusuConnected.Set(1)
+				} else {
+					metrics.OksusuConnected.Set(0)
+				}
+			})
+			go func() {
+				errCh <- runOksusu(ctx, client)
+			}()
+		}
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			slog.Info("Shutting down")
+			return nil
 		}
 	}); err != nil {
 		panic(err)
 	}
 }
+
+// runOksusu keeps the Oksu Connect session alive, reconnecting after every
+// disconnect until ctx is canceled.
+func runOksusu(ctx context.Context, client *oksusu.Client) error {
+	for {
+		err := client.ConnectAndServe(ctx)
+		if err != nil {
+			slog.Error("Oksu client disconnected with error", "error", err)
+		}
+
+		slog.Info("Attempting to reconnect in 10 seconds...")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(10 * time.Second):
+			metrics.OksusuReconnects.Inc()
+		}
+	}
+}
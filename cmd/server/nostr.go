@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/config"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProvideNostrSigner returns the signer for zap receipts and DMs: a NIP-46
+// remote signer when nostr.bunker is set, the private key otherwise. It's nil
+// when Nostr is disabled. The remote signer is connected in the background,
+// so lmt starts while it is offline.
+func ProvideNostrSigner(cfg *config.Config) (nostrpkg.Signer, error) {
+	if !cfg.Nostr.Enabled {
+		return nil, nil
+	}
+
+	if cfg.Nostr.Bunker == "" {
+		keys, err := cfg.NostrKeys()
+		if err != nil {
+			return nil, err
+		}
+		signer, err := nostrpkg.NewLocalSigner(keys.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return signer, nil
+	}
+
+	clientKey, err := loadBunkerClientKey(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the NIP-46 client key: %w", err)
+	}
+
+	// The public key is advertised right away; the signer itself may be
+	// offline and is connected in the background.
+	publicKey, err := nostrpkg.ParsePublicKey(cfg.Nostr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Connecting to the remote signer in the background", "pubkey", publicKey)
+	return nostrpkg.ConnectRemoteSignerInBackground(context.Background(), cfg.Nostr.Bunker, clientKey, publicKey), nil
+}
+
+// loadBunkerClientKey returns the key lmt talks to the remote signer as,
+// creating it on the first start. It's kept in the data dir, so the signer
+// recognizes lmt after a restart without the one-time secret of the URI.
+func loadBunkerClientKey(cfg *config.Config) (string, error) {
+	dataDir, err := config.ExpandPath(cfg.General.DataDir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, "nip46_client_key")
+
+	b, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(b))
+		if !nostr.IsValid32ByteHex(key) {
+			return "", fmt.Errorf("%s is not a hex key", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return "", err
+	}
+	key := nostr.GeneratePrivateKey()
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// nostrPublicKey is the hex public key to advertise, empty when Nostr is
+// disabled.
+func nostrPublicKey(signer nostrpkg.Signer) string {
+	if signer == nil {
+		return ""
+	}
+	return signer.PublicKey()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/internal/config"
+	"github.com/asheswook/lightning-multitool/internal/server"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+// reloadableKeys are the config keys a reload applies. Everything else is
+// wired up once at startup and needs a restart.
+var reloadableKeys = []string{
+	"lnurl.min-sendable",
+	"lnurl.max-sendable",
+	"lnurl.comment-allowed",
+	"nostr.relays",
+}
+
+// settingsFromConfig returns the reloadable settings in cfg, which must have
+// been validated.
+func settingsFromConfig(cfg *config.Config) app.Settings {
+	return app.Settings{
+		MinSendable:    cfg.LNURL.MinSendableMsat,
+		MaxSendable:    cfg.LNURL.MaxSendableMsat,
+		CommentAllowed: cfg.LNURL.CommentAllowed,
+		Relays:         cfg.Nostr.Relays,
+	}
+}
+
+func ProvideSettings(cfg *config.Config) *app.LiveSettings {
+	return app.NewLiveSettings(settingsFromConfig(cfg))
+}
+
+// configReloader re-reads the config file on SIGHUP or /api/reload.
+type configReloader struct {
+	settings *app.LiveSettings
+
+	mtx     sync.Mutex
+	running *config.Config // the config lmt was started with
+	applied *config.Config // the config the current settings came from
+}
+
+func ProvideReloader(cfg *config.Config, settings *app.LiveSettings) server.Reloader {
+	return &configReloader{
+		settings: settings,
+		running:  cfg,
+		applied:  cfg,
+	}
+}
+
+// Reload applies the reloadable settings from the config file, and reports
+// every other key that differs from the running config. Nothing is applied
+// when the config file is invalid.
+func (r *configReloader) Reload() (server.ReloadResult, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return server.ReloadResult{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return server.ReloadResult{}, err
+	}
+
+	result := server.ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	for _, key := range config.Diff(r.applied, cfg) {
+		if slices.Contains(reloadableKeys, key) {
+			result.Applied = append(result.Applied, key)
+		}
+	}
+	for _, key := range config.Diff(r.running, cfg) {
+		if !slices.Contains(reloadableKeys, key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	r.settings.Set(settingsFromConfig(cfg))
+	r.applied = cfg
+
+	slog.Info("Reloaded config", "applied", result.Applied)
+	if len(result.RestartRequired) > 0 {
+		slog.Warn("Some config changes only take effect after a restart", "keys", result.RestartRequired)
+	}
+	return result, nil
+}
+
+// reloadOnSIGHUP reloads the config every time lmt receives SIGHUP.
+func reloadOnSIGHUP(ctx context.Context, reloader server.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if _, err := reloader.Reload(); err != nil {
+				slog.Error("Failed to reload config, keeping the current settings", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/asheswook/lightning-multitool/pkg/lnurl"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+)
+
+// currencyInfo is the display information of a currency.
+type currencyInfo struct {
+	name     string
+	symbol   string
+	decimals int
+}
+
+var knownCurrencies = map[string]currencyInfo{
+	"USD": {"US Dollar", "$", 2},
+	"EUR": {"Euro", "€", 2},
+	"GBP": {"British Pound", "£", 2},
+	"JPY": {"Japanese Yen", "¥", 0},
+	"KRW": {"South Korean Won", "₩", 0},
+	"CAD": {"Canadian Dollar", "$", 2},
+	"AUD": {"Australian Dollar", "$", 2},
+	"CHF": {"Swiss Franc", "CHF", 2},
+}
+
+func lookupCurrency(code string) currencyInfo {
+	if info, ok := knownCurrencies[code]; ok {
+		return info
+	}
+	return currencyInfo{name: code, symbol: code, decimals: 2}
+}
+
+// CurrencyConverter implements the LUD-21 currencies extension: it advertises
+// fiat currencies and converts amounts in them to msats. The spread, in
+// percent, is added to the rate to cover price movements until the payment
+// is converted. Rates older than maxPriceAge are not used; 0 allows any age.
+type CurrencyConverter struct {
+	prices      fiat.PriceSource
+	currencies  []string
+	spread      float64
+	maxPriceAge time.Duration
+}
+
+func NewCurrencyConverter(prices fiat.PriceSource, currencies []string, spread float64, maxPriceAge time.Duration) *CurrencyConverter {
+	// Entries may themselves be comma separated lists, as in "USD,EUR".
+	var codes []string
+	for _, list := range currencies {
+		for _, code := range strings.Split(list, ",") {
+			if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	return &CurrencyConverter{
+		prices:      prices,
+		currencies:  codes,
+		spread:      spread,
+		maxPriceAge: maxPriceAge,
+	}
+}
+
+// multiplier returns the msats one smallest unit of the currency is worth, spread included.
+func (c *CurrencyConverter) multiplier(ctx context.Context, code string) (float64, error) {
+	price, err := fiat.CurrentPrice(ctx, c.prices, code, c.maxPriceAge)
+	if err != nil {
+		return 0, err
+	}
+
+	info := lookupCurrency(code)
+	msatPerUnit := 1e11 / price / math.Pow10(info.decimals)
+	return msatPerUnit * (1 + c.spread/100), nil
+}
+
+// Currencies returns the supported currencies with their current rates.
+// Currencies without a rate are left out.
+func (c *CurrencyConverter) Currencies(ctx context.Context, minSendable, maxSendable int64) []lnurl.Currency {
+	currencies := make([]lnurl.Currency, 0, len(c.currencies))
+	for _, code := range c.currencies {
+		multiplier, err := c.multiplier(ctx, code)
+		if err != nil {
+			slog.Warn("No rate for currency, not advertising it", "currency", code, "error", err)
+			continue
+		}
+
+		info := lookupCurrency(code)
+		currency := lnurl.Currency{
+			Code:       code,
+			Name:       info.name,
+			Symbol:     info.symbol,
+			Decimals:   info.decimals,
+			Multiplier: multiplier,
+		}
+
+		convertible := lnurl.ConvertibleCurrency{
+			Min: int64(math.Ceil(float64(minSendable) / multiplier)),
+			Max: int64(math.Floor(float64(maxSendable) / multiplier)),
+		}
+		if convertible.Min <= convertible.Max {
+			currency.Convertible = &convertible
+		}
+
+		currencies = append(currencies, currency)
+	}
+	return currencies
+}
+
+// Convert converts an amount in smallest units of a currency to msats. It also
+// returns a description of the conversion for the invoice memo.
+func (c *CurrencyConverter) Convert(ctx context.Context, amount int64, code string) (int64, string, error) {
+	if !c.supports(code) {
+		return 0, "", fmt.Errorf("currency %s is not supported", code)
+	}
+
+	multiplier, err := c.multiplier(ctx, code)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get %s rate: %w", code, err)
+	}
+
+	info := lookupCurrency(code)
+	msat := int64(math.Ceil(float64(amount) * multiplier))
+	memo := fmt.Sprintf("%.*f %s", info.decimals, float64(amount)/math.Pow10(info.decimals), code)
+	return msat, memo, nil
+}
+
+func (c *CurrencyConverter) supports(code string) bool {
+	for _, supported := range c.currencies {
+		if supported == code {
+			return true
+		}
+	}
+	return false
+}
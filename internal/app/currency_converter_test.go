@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCurrencyConverter(t *testing.T) {
+	ctx := context.Background()
+	prices := fiat.StaticPrices{"USD": 1_000_000, "KRW": 100_000_000}
+
+	t.Run("advertises currencies with rates", func(t *testing.T) {
+		converter := NewCurrencyConverter(prices, []string{"usd,KRW", "EUR"}, 0, 0)
+
+		currencies := converter.Currencies(ctx, 1000, 1_000_000_000)
+		require.Len(t, currencies, 2, "EUR has no rate")
+
+		usd := currencies[0]
+		assert.Equal(t, "USD", usd.Code)
+		assert.Equal(t, 2, usd.Decimals)
+		// 1 BTC = 1,000,000 USD, so a cent is 1,000 msat.
+		assert.InDelta(t, 1000, usd.Multiplier, 1e-9)
+		require.NotNil(t, usd.Convertible)
+		assert.Equal(t, int64(1), usd.Convertible.Min)
+		assert.Equal(t, int64(1_000_000), usd.Convertible.Max)
+
+		krw := currencies[1]
+		assert.Equal(t, 0, krw.Decimals)
+		assert.InDelta(t, 1000, krw.Multiplier, 1e-9)
+	})
+
+	t.Run("converts with spread", func(t *testing.T) {
+		converter := NewCurrencyConverter(prices, []string{"USD"}, 2, 0)
+
+		msat, memo, err := converter.Convert(ctx, 1050, "USD")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1_071_000), msat)
+		assert.Equal(t, "10.50 USD", memo)
+
+		_, _, err = converter.Convert(ctx, 1000, "KRW")
+		assert.Error(t, err, "KRW is not enabled")
+	})
+
+	t.Run("refuses stale rates", func(t *testing.T) {
+		recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		old := time.Now().Add(-72 * time.Hour).UTC().Format(time.RFC3339)
+		file, err := fiat.ParsePrices(strings.NewReader(recent + ",USD,1000000\n" + old + ",EUR,900000\n"))
+		require.NoError(t, err)
+		converter := NewCurrencyConverter(file, []string{"USD,EUR"}, 0, 48*time.Hour)
+
+		currencies := converter.Currencies(ctx, 1000, 1_000_000_000)
+		require.Len(t, currencies, 1, "EUR's rate is too old")
+		assert.Equal(t, "USD", currencies[0].Code)
+
+		_, _, err = converter.Convert(ctx, 1000, "EUR")
+		assert.ErrorIs(t, err, fiat.ErrStalePrice)
+
+		msat, _, err := converter.Convert(ctx, 1000, "USD")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1_000_000), msat)
+	})
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"time"
+)
+
+// DailyTotal is what was received on one day.
+type DailyTotal struct {
+	Date          string `json:"date"` // YYYY-MM-DD
+	Payments      int    `json:"payments"`
+	AmountMsat    int64  `json:"amount_msat"`
+	Zaps          int    `json:"zaps"`
+	ZapAmountMsat int64  `json:"zap_amount_msat"`
+}
+
+// DailyTotals sums the settled payments of the last days days up to and
+// including the day of now, by settlement date in now's location. Days
+// without payments are included, oldest first.
+func DailyTotals(records []InvoiceRecord, days int, now time.Time) []DailyTotal {
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -days)
+
+	totals := make([]DailyTotal, days)
+	index := make(map[string]int, days)
+	for i := range totals {
+		totals[i].Date = start.AddDate(0, 0, i).Format(time.DateOnly)
+		index[totals[i].Date] = i
+	}
+
+	for _, record := range SettledBetween(records, start, end) {
+		i, ok := index[record.SettledAt.In(now.Location()).Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+
+		totals[i].Payments++
+		totals[i].AmountMsat += record.AmountPaidMsat
+		if record.ZapRequest != "" {
+			totals[i].Zaps++
+			totals[i].ZapAmountMsat += record.AmountPaidMsat
+		}
+	}
+	return totals
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDailyTotals(t *testing.T) {
+	now := time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)
+	settled := func(hash string, at time.Time, msat int64, zap bool) InvoiceRecord {
+		record := InvoiceRecord{
+			IssuedInvoice:  IssuedInvoice{PaymentHash: hash},
+			Status:         InvoiceStatus_SETTLED,
+			AmountPaidMsat: msat,
+			SettledAt:      &at,
+		}
+		if zap {
+			record.ZapRequest = "{}"
+		}
+		return record
+	}
+
+	records := []InvoiceRecord{
+		settled("a", now.Add(-time.Hour), 1000, false),
+		settled("b", now.Add(-2*time.Hour), 2000, true),
+		settled("c", now.AddDate(0, 0, -2), 5000, false),
+		settled("too-old", now.AddDate(0, 0, -3), 7000, false),
+		{IssuedInvoice: IssuedInvoice{PaymentHash: "open", AmountMsat: 9000}, Status: InvoiceStatus_OPEN},
+	}
+
+	totals := DailyTotals(records, 3, now)
+	require.Len(t, totals, 3)
+	assert.Equal(t, DailyTotal{Date: "2024-03-01", Payments: 1, AmountMsat: 5000}, totals[0])
+	assert.Equal(t, DailyTotal{Date: "2024-03-02"}, totals[1])
+	assert.Equal(t, DailyTotal{Date: "2024-03-03", Payments: 2, AmountMsat: 3000, Zaps: 1, ZapAmountMsat: 2000}, totals[2])
+}
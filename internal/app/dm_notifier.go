@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/nostrutil"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dmRetryDelay is how long payments are kept before trying again when the
+// remote signer is not connected.
+const dmRetryDelay = 30 * time.Second
+
+type DMProtocol string
+
+const (
+	// DMProtocol_AUTO uses NIP-17 for owners who publish DM relays and NIP-04 otherwise.
+	DMProtocol_AUTO  DMProtocol = "auto"
+	DMProtocol_NIP17 DMProtocol = "nip17"
+	DMProtocol_NIP04 DMProtocol = "nip04"
+)
+
+// DMNotifier sends the owners of the Lightning Address an encrypted Nostr DM
+// about every payment. Payments arriving within the batch window are sent as
+// one digest. While a remote signer is not connected, payments are kept and
+// sent once it is.
+type DMNotifier struct {
+	signer        nostrpkg.Signer
+	owners        []string
+	settings      *LiveSettings
+	protocol      DMProtocol
+	minAmountMsat int64
+	batchWindow   time.Duration
+	retryDelay    time.Duration // while the remote signer is not connected
+
+	mtx     sync.Mutex
+	pending []SettledPayment
+
+	// fetchDMRelays and publish talk to the relays; tests replace them.
+	fetchDMRelays func(ctx context.Context, pubkey string, relays []string) []string
+	publish       func(ctx context.Context, event nostr.Event, relays []string)
+}
+
+func NewDMNotifier(signer nostrpkg.Signer, owners []string, settings *LiveSettings, protocol DMProtocol, minAmountMsat int64, batchWindow time.Duration) *DMNotifier {
+	return &DMNotifier{
+		signer:        signer,
+		owners:        owners,
+		settings:      settings,
+		protocol:      protocol,
+		minAmountMsat: minAmountMsat,
+		batchWindow:   batchWindow,
+		retryDelay:    dmRetryDelay,
+		fetchDMRelays: nostrutil.FetchDMRelays,
+		publish:       nostrutil.PublishEvent,
+	}
+}
+
+func (n *DMNotifier) OnPaymentSettled(payment SettledPayment) {
+	if payment.AmountPaidMsat < n.minAmountMsat {
+		return
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.pending = append(n.pending, payment)
+	if len(n.pending) == 1 {
+		// The first payment of a batch starts the window.
+		time.AfterFunc(n.batchWindow, n.flush)
+	}
+}
+
+// flush sends everything collected during the batch window.
+func (n *DMNotifier) flush() {
+	n.mtx.Lock()
+	payments := n.pending
+	n.pending = nil
+	n.mtx.Unlock()
+
+	if len(payments) == 0 {
+		return
+	}
+
+	if !n.signer.Ready() {
+		slog.Warn("Remote signer is not connected, delaying payment DM", "payments", len(payments))
+		n.mtx.Lock()
+		scheduled := len(n.pending) > 0
+		n.pending = append(payments, n.pending...)
+		n.mtx.Unlock()
+		if !scheduled {
+			time.AfterFunc(n.retryDelay, n.flush)
+		}
+		return
+	}
+
+	content := formatPaymentDM(payments)
+	for _, owner := range n.owners {
+		n.send(context.Background(), owner, content)
+	}
+}
+
+func (n *DMNotifier) send(ctx context.Context, owner, content string) {
+	logger := slog.With("owner", owner)
+
+	protocol := n.protocol
+	relays := n.settings.Relays()
+	if protocol != DMProtocol_NIP04 {
+		if dmRelays := n.fetchDMRelays(ctx, owner, relays); len(dmRelays) > 0 {
+			relays = dmRelays
+		} else if protocol == DMProtocol_AUTO {
+			logger.Debug("Owner has no DM relays, falling back to NIP-04")
+			protocol = DMProtocol_NIP04
+		}
+	}
+
+	var (
+		event nostr.Event
+		err   error
+	)
+	if protocol == DMProtocol_NIP04 {
+		event, err = nostrutil.NewNIP04DM(ctx, n.signer, owner, content)
+	} else {
+		event, err = nostrutil.NewGiftWrappedDM(ctx, n.signer, owner, content)
+	}
+	if err != nil {
+		logger.Error("Failed to create payment DM", "protocol", protocol, "error", err)
+		return
+	}
+
+	logger.Info("Sending payment DM", "protocol", protocol, "event_id", event.ID)
+	n.publish(ctx, event, relays)
+}
+
+// formatPaymentDM renders a single payment, or a digest of several.
+func formatPaymentDM(payments []SettledPayment) string {
+	if len(payments) == 1 {
+		return "⚡ Received " + describePayment(payments[0])
+	}
+
+	var total int64
+	for _, payment := range payments {
+		total += payment.AmountPaidMsat
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚡ Received %d payments, %s in total:", len(payments), formatSats(total))
+	for _, payment := range payments {
+		b.WriteString("\n• ")
+		b.WriteString(describePayment(payment))
+	}
+	return b.String()
+}
+
+func describePayment(payment SettledPayment) string {
+	s := formatSats(payment.AmountPaidMsat)
+
+	if payment.ZapSender != "" {
+		s += " zap"
+		if npub, err := nip19.EncodePublicKey(payment.ZapSender); err == nil {
+			s += " from nostr:" + npub
+		}
+	}
+
+	if payment.Comment != "" {
+		s += fmt.Sprintf(": %q", payment.Comment)
+	}
+	return s
+}
+
+func formatSats(msat int64) string {
+	if msat%1000 == 0 {
+		return fmt.Sprintf("%d sats", msat/1000)
+	}
+	return fmt.Sprintf("%.3f sats", float64(msat)/1000)
+}
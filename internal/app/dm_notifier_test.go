@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip59"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var defaultRelays = []string{"wss://relay.example.com"}
+
+// publishedDM is an event the notifier handed to the relays.
+type publishedDM struct {
+	event  nostr.Event
+	relays []string
+}
+
+// dmTest runs a notifier against an owner whose DM relays the test chooses,
+// and records what it publishes instead of talking to relays.
+type dmTest struct {
+	signer   *nostrpkg.LocalSigner
+	ownerSK  string
+	owner    string
+	notifier *DMNotifier
+	sent     chan publishedDM
+}
+
+func newDMTest(t *testing.T, protocol DMProtocol, minAmountMsat int64, dmRelays []string) *dmTest {
+	signer, err := nostrpkg.NewLocalSigner(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+
+	ownerSK := nostr.GeneratePrivateKey()
+	owner, err := nostr.GetPublicKey(ownerSK)
+	require.NoError(t, err)
+
+	d := &dmTest{signer: signer, ownerSK: ownerSK, owner: owner, sent: make(chan publishedDM, 16)}
+	d.notifier = NewDMNotifier(signer, []string{owner}, NewLiveSettings(Settings{Relays: defaultRelays}), protocol, minAmountMsat, 50*time.Millisecond)
+	d.notifier.fetchDMRelays = func(_ context.Context, pubkey string, _ []string) []string {
+		assert.Equal(t, owner, pubkey)
+		return dmRelays
+	}
+	d.notifier.publish = func(_ context.Context, event nostr.Event, relays []string) {
+		d.sent <- publishedDM{event: event, relays: relays}
+	}
+	return d
+}
+
+// read decrypts a published DM as the owner.
+func (d *dmTest) read(t *testing.T, event nostr.Event) string {
+	t.Helper()
+
+	switch event.Kind {
+	case nostr.KindEncryptedDirectMessage:
+		sharedSecret, err := nip04.ComputeSharedSecret(event.PubKey, d.ownerSK)
+		require.NoError(t, err)
+		content, err := nip04.Decrypt(event.Content, sharedSecret)
+		require.NoError(t, err)
+		return content
+	case nostr.KindGiftWrap:
+		owner, err := keyer.NewPlainKeySigner(d.ownerSK)
+		require.NoError(t, err)
+		rumor, err := nip59.GiftUnwrap(event, func(sender, ciphertext string) (string, error) {
+			return owner.Decrypt(context.Background(), ciphertext, sender)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, d.signer.PublicKey(), rumor.PubKey)
+		return rumor.Content
+	}
+	t.Fatalf("unexpected event kind %d", event.Kind)
+	return ""
+}
+
+func TestDMNotifierBatchesPayments(t *testing.T) {
+	d := newDMTest(t, DMProtocol_NIP04, 0, nil)
+
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 1000})
+	d.notifier.OnPaymentSettled(SettledPayment{IssuedInvoice: IssuedInvoice{Comment: "thanks"}, AmountPaidMsat: 2000})
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 3500})
+
+	dm := receive(t, d.sent)
+	assert.Equal(t, "⚡ Received 3 payments, 6.500 sats in total:\n• 1 sats\n• 2 sats: \"thanks\"\n• 3.500 sats", d.read(t, dm.event))
+
+	// Payments after the window start a new batch.
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 21000})
+	assert.Equal(t, "⚡ Received 21 sats", d.read(t, receive(t, d.sent).event))
+	assert.Empty(t, d.sent)
+}
+
+// connectingSigner is not ready until connected is set, like a remote
+// signer that connects in the background.
+type connectingSigner struct {
+	*nostrpkg.LocalSigner
+	connected atomic.Bool
+}
+
+func (s *connectingSigner) Ready() bool {
+	return s.connected.Load()
+}
+
+func TestDMNotifierWaitsForSigner(t *testing.T) {
+	d := newDMTest(t, DMProtocol_NIP04, 0, nil)
+	signer := &connectingSigner{LocalSigner: d.signer}
+	d.notifier.signer = signer
+	d.notifier.retryDelay = 50 * time.Millisecond
+
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 1000})
+	time.Sleep(3 * d.notifier.batchWindow)
+	assert.Empty(t, d.sent)
+
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 2000})
+	signer.connected.Store(true)
+
+	dm := receive(t, d.sent)
+	assert.Equal(t, "⚡ Received 2 payments, 3 sats in total:\n• 1 sats\n• 2 sats", d.read(t, dm.event))
+	time.Sleep(3 * d.notifier.retryDelay)
+	assert.Empty(t, d.sent)
+}
+
+func TestDMNotifierMinAmount(t *testing.T) {
+	d := newDMTest(t, DMProtocol_NIP04, 10_000, nil)
+
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 9_999})
+	d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 10_000})
+
+	assert.Equal(t, "⚡ Received 10 sats", d.read(t, receive(t, d.sent).event))
+	time.Sleep(2 * d.notifier.batchWindow)
+	assert.Empty(t, d.sent)
+}
+
+func TestDMNotifierProtocol(t *testing.T) {
+	dmRelays := []string{"wss://dm.example.com"}
+
+	tests := []struct {
+		name     string
+		protocol DMProtocol
+		dmRelays []string
+		kind     int
+		relays   []string
+	}{
+		{"nip04 ignores DM relays", DMProtocol_NIP04, dmRelays, nostr.KindEncryptedDirectMessage, defaultRelays},
+		{"nip17 to DM relays", DMProtocol_NIP17, dmRelays, nostr.KindGiftWrap, dmRelays},
+		{"nip17 without DM relays", DMProtocol_NIP17, nil, nostr.KindGiftWrap, defaultRelays},
+		{"auto with DM relays", DMProtocol_AUTO, dmRelays, nostr.KindGiftWrap, dmRelays},
+		{"auto without DM relays", DMProtocol_AUTO, nil, nostr.KindEncryptedDirectMessage, defaultRelays},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDMTest(t, tt.protocol, 0, tt.dmRelays)
+			d.notifier.OnPaymentSettled(SettledPayment{AmountPaidMsat: 1000})
+
+			dm := receive(t, d.sent)
+			assert.Equal(t, tt.kind, dm.event.Kind)
+			assert.Equal(t, tt.relays, dm.relays)
+			assert.Equal(t, "⚡ Received 1 sats", d.read(t, dm.event))
+		})
+	}
+}
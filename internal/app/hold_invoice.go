@@ -0,0 +1,414 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// holdSafetyBlocks is how many blocks before the earliest HTLC expiry an
+	// accepted hold invoice is canceled at the latest.
+	holdSafetyBlocks = 10
+	// holdBlockInterval is a conservative block interval; blocks often come
+	// faster than the 10 minute average.
+	holdBlockInterval = 5 * time.Minute
+	// holdRetention is how long after its expiry an unresolved hold invoice
+	// is kept. By then LND has canceled any HTLC still held for it.
+	holdRetention = 7 * 24 * time.Hour
+	// holdLNDTimeout bounds settling or canceling an invoice in LND.
+	holdLNDTimeout = 30 * time.Second
+)
+
+// HoldApprovalRequest describes an accepted hold invoice waiting for a decision.
+type HoldApprovalRequest struct {
+	PaymentHash string `json:"payment_hash"`
+	AmountMsat  int64  `json:"amount_msat"`
+	Comment     string `json:"comment,omitempty"`
+	ZapRequest  string `json:"zap_request,omitempty"`
+}
+
+// HoldCondition decides whether an accepted hold invoice is settled or canceled.
+type HoldCondition interface {
+	Approve(ctx context.Context, req HoldApprovalRequest) (bool, error)
+}
+
+// HoldConditionFunc adapts a function to a HoldCondition.
+type HoldConditionFunc func(ctx context.Context, req HoldApprovalRequest) (bool, error)
+
+func (f HoldConditionFunc) Approve(ctx context.Context, req HoldApprovalRequest) (bool, error) {
+	return f(ctx, req)
+}
+
+// WebhookApproval asks an external HTTP endpoint whether to settle a hold invoice.
+// The request is POSTed as JSON; a 2xx response approves it and a 4xx response rejects it.
+type WebhookApproval struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewWebhookApproval(url string) WebhookApproval {
+	return WebhookApproval{
+		url:        url,
+		httpClient: &http.Client{},
+	}
+}
+
+func (a WebhookApproval) Approve(ctx context.Context, req HoldApprovalRequest) (bool, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal approval request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create approval request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("failed to send approval request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected approval response: %s", resp.Status)
+	}
+}
+
+// HoldInvoiceWorkflow issues hold invoices and settles them only after a
+// HoldCondition approves the payment. Payments that are rejected, fail the
+// condition or run out of time are canceled before their CLTV deadline.
+//
+// The preimage and the decision of every unresolved hold invoice are kept in
+// a log, so an invoice accepted while lmt restarts can still be resolved.
+type HoldInvoiceWorkflow struct {
+	lndService *lndrest.Client
+	condition  HoldCondition
+	timeout    time.Duration
+	log        *store.JSONLog
+
+	mtx     sync.Mutex
+	pending map[string]holdRecord
+
+	// retryInterval is how long to wait before subscribing to an invoice again.
+	retryInterval time.Duration
+}
+
+// holdRecord is an unresolved hold invoice in the log.
+type holdRecord struct {
+	HoldApprovalRequest
+	Preimage  string    `json:"preimage,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Approved is the condition's decision, once it was made. It is kept so
+	// that the condition isn't asked again when settling or canceling fails.
+	Approved *bool `json:"approved,omitempty"`
+	Resolved bool  `json:"resolved,omitempty"`
+}
+
+// NewHoldInvoiceWorkflow loads the unresolved hold invoices from log and
+// compacts it. Call Resume to start resolving them.
+func NewHoldInvoiceWorkflow(lnd *lndrest.Client, condition HoldCondition, timeout time.Duration, log *store.JSONLog) (*HoldInvoiceWorkflow, error) {
+	w := &HoldInvoiceWorkflow{
+		lndService:    lnd,
+		condition:     condition,
+		timeout:       timeout,
+		log:           log,
+		pending:       make(map[string]holdRecord),
+		retryInterval: 10 * time.Second,
+	}
+
+	err := log.Replay(func(line []byte) error {
+		var record holdRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil
+		}
+		if record.Resolved || time.Since(record.ExpiresAt) > holdRetention {
+			delete(w.pending, record.PaymentHash)
+		} else {
+			w.pending[record.PaymentHash] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hold invoices: %w", err)
+	}
+
+	records := make([]interface{}, 0, len(w.pending))
+	for _, record := range w.pending {
+		records = append(records, record)
+	}
+	if err := log.Rewrite(records); err != nil {
+		return nil, fmt.Errorf("failed to compact hold invoices: %w", err)
+	}
+
+	return w, nil
+}
+
+// Resume starts resolving the hold invoices that were still unresolved when
+// lmt stopped. They're watched until resolved or ctx is canceled.
+func (w *HoldInvoiceWorkflow) Resume(ctx context.Context) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, record := range w.pending {
+		slog.Info("Resuming hold invoice", "payment_hash", record.PaymentHash)
+		go w.watch(ctx, record, nil)
+	}
+}
+
+// CreateInvoice creates a hold invoice in place of a regular one and starts
+// waiting for it to be paid in the background.
+func (w *HoldInvoiceWorkflow) CreateInvoice(ctx context.Context, params lndrest.CreateInvoiceParams, req HoldApprovalRequest) (lndrest.CreateInvoiceResponse, error) {
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return lndrest.CreateInvoiceResponse{}, fmt.Errorf("failed to generate preimage: %w", err)
+	}
+	hash := sha256.Sum256(preimage)
+
+	// Keep the preimage before LND knows the hash; a payment can't be
+	// settled without it.
+	req.PaymentHash = hex.EncodeToString(hash[:])
+	expiry := defaultInvoiceExpiry
+	if params.Expiry > 0 {
+		expiry = time.Duration(params.Expiry) * time.Second
+	}
+	record := holdRecord{HoldApprovalRequest: req, Preimage: hex.EncodeToString(preimage), ExpiresAt: time.Now().Add(expiry)}
+	if err := w.save(record); err != nil {
+		return lndrest.CreateInvoiceResponse{}, err
+	}
+
+	res, err := w.lndService.AddHoldInvoice(ctx, lndrest.AddHoldInvoiceParams{
+		Memo:            params.Memo,
+		Hash:            hash[:],
+		Value:           params.Value,
+		ValueMsat:       params.ValueMsat,
+		DescriptionHash: params.DescriptionHash,
+		Expiry:          params.Expiry,
+		FallbackAddr:    params.FallbackAddr,
+		CltvExpiry:      uint64(params.CltvExpiry),
+		Private:         params.Private,
+	})
+	if err != nil {
+		w.forget(record)
+		return lndrest.CreateInvoiceResponse{}, err
+	}
+
+	// Subscribe before handing out the invoice so the ACCEPTED update can't be missed.
+	watchCtx, cancel := context.WithCancel(context.Background())
+	updates, err := w.lndService.SubscribeSingleInvoice(watchCtx, hash[:])
+	if err != nil {
+		cancel()
+		_ = w.lndService.CancelInvoice(ctx, hash[:])
+		w.forget(record)
+		return lndrest.CreateInvoiceResponse{}, fmt.Errorf("failed to subscribe to hold invoice: %w", err)
+	}
+
+	go func() {
+		defer cancel()
+		w.watch(watchCtx, record, updates)
+	}()
+
+	return lndrest.CreateInvoiceResponse{
+		RHash:          hash[:],
+		PaymentRequest: res.PaymentRequest,
+		AddIndex:       res.AddIndex,
+		PaymentAddr:    res.PaymentAddr,
+	}, nil
+}
+
+// save records the current state of an unresolved hold invoice.
+func (w *HoldInvoiceWorkflow) save(record holdRecord) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := w.log.Append(record); err != nil {
+		return fmt.Errorf("failed to persist hold invoice: %w", err)
+	}
+	w.pending[record.PaymentHash] = record
+	return nil
+}
+
+// forget records that a hold invoice is resolved and needs no more watching.
+func (w *HoldInvoiceWorkflow) forget(record holdRecord) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	delete(w.pending, record.PaymentHash)
+	if err := w.log.Append(holdRecord{HoldApprovalRequest: HoldApprovalRequest{PaymentHash: record.PaymentHash}, Resolved: true}); err != nil {
+		slog.Error("Failed to persist resolved hold invoice", "payment_hash", record.PaymentHash, "error", err)
+	}
+}
+
+// watch follows the hold invoice until it's resolved, subscribing again
+// whenever the stream ends. updates may be an already open subscription.
+func (w *HoldInvoiceWorkflow) watch(ctx context.Context, record holdRecord, updates <-chan lndrest.Invoice) {
+	logger := slog.With("payment_hash", record.PaymentHash)
+
+	hash, err := hex.DecodeString(record.PaymentHash)
+	if err != nil {
+		logger.Error("Invalid hold invoice payment hash, dropping it", "error", err)
+		w.forget(record)
+		return
+	}
+
+	for {
+		if updates == nil {
+			updates, err = w.lndService.SubscribeSingleInvoice(ctx, hash)
+			if err != nil {
+				logger.Warn("Failed to subscribe to hold invoice, retrying", "error", err, "retry_in", w.retryInterval)
+			}
+		}
+
+		if updates != nil {
+			if w.await(ctx, &record, updates) {
+				return
+			}
+			updates = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryInterval):
+		}
+	}
+}
+
+// await waits for the hold invoice to be accepted and then resolves it. It
+// returns false if the subscription ended before that, to subscribe again.
+func (w *HoldInvoiceWorkflow) await(ctx context.Context, record *holdRecord, updates <-chan lndrest.Invoice) bool {
+	logger := slog.With("payment_hash", record.PaymentHash)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case invoice, ok := <-updates:
+			if !ok {
+				logger.Warn("Hold invoice subscription closed before the invoice was resolved, resubscribing")
+				return false
+			}
+
+			switch invoice.State {
+			case lndrest.InvoiceState_ACCEPTED:
+				return w.resolve(ctx, record, invoice)
+			case lndrest.InvoiceState_SETTLED, lndrest.InvoiceState_CANCELED:
+				logger.Info("Hold invoice resolved", "state", invoice.State)
+				w.forget(*record)
+				return true
+			}
+		}
+	}
+}
+
+// resolve asks the condition about an accepted invoice and settles or
+// cancels it. It returns false if LND couldn't be told, to try again.
+func (w *HoldInvoiceWorkflow) resolve(ctx context.Context, record *holdRecord, invoice lndrest.Invoice) bool {
+	record.AmountMsat = invoice.AmtPaidMsat
+	logger := slog.With("payment_hash", record.PaymentHash, "amount_msat", record.AmountMsat)
+
+	if record.Approved == nil {
+		deadline := w.deadline(invoice)
+		logger.Info("Hold invoice accepted, waiting for approval", "deadline", deadline)
+
+		approveCtx, cancel := context.WithDeadline(ctx, deadline)
+		approved, err := w.condition.Approve(approveCtx, record.HoldApprovalRequest)
+		cancel()
+
+		if ctx.Err() != nil {
+			// lmt is stopping; the invoice is resumed after the restart.
+			return true
+		}
+		if err != nil {
+			logger.Warn("Hold invoice approval failed, canceling", "error", err)
+			approved = false
+		} else if !approved {
+			logger.Info("Hold invoice rejected, canceling")
+		}
+
+		record.Approved = &approved
+		if err := w.save(*record); err != nil {
+			logger.Error("Failed to persist hold invoice decision", "error", err)
+		}
+	}
+
+	// Keep resolving on shutdown; an invoice left accepted holds the
+	// payer's funds until its HTLCs time out.
+	lndCtx, lndCancel := context.WithTimeout(context.WithoutCancel(ctx), holdLNDTimeout)
+	defer lndCancel()
+
+	if *record.Approved {
+		preimage, err := hex.DecodeString(record.Preimage)
+		if err == nil {
+			err = w.lndService.SettleInvoice(lndCtx, preimage)
+		}
+		if err != nil {
+			logger.Error("Failed to settle hold invoice", "error", err)
+			return false
+		}
+		logger.Info("Hold invoice settled")
+		w.forget(*record)
+		return true
+	}
+
+	hash, _ := hex.DecodeString(record.PaymentHash)
+	if err := w.lndService.CancelInvoice(lndCtx, hash); err != nil {
+		logger.Error("Failed to cancel hold invoice", "error", err)
+		return false
+	}
+	w.forget(*record)
+	return true
+}
+
+// ResolveBy returns when an invoice that expires at expiresAt is settled or
+// canceled at the latest: a payment accepted just before expiry may wait
+// for the timeout and then for LND.
+func (w *HoldInvoiceWorkflow) ResolveBy(expiresAt time.Time) time.Time {
+	return expiresAt.Add(w.timeout + holdLNDTimeout)
+}
+
+// deadline returns when an accepted invoice must be resolved: after the
+// configured timeout, but never later than holdSafetyBlocks before the
+// earliest HTLC expiry.
+func (w *HoldInvoiceWorkflow) deadline(invoice lndrest.Invoice) time.Time {
+	// The timeout runs from when the payment arrived, which is earlier than
+	// now for an invoice resumed after a restart.
+	start := time.Now()
+	for _, htlc := range invoice.Htlcs {
+		if accepted := time.Unix(htlc.AcceptTime, 0); htlc.State == lndrest.InvoiceHTLCState_ACCEPTED && htlc.AcceptTime > 0 && accepted.Before(start) {
+			start = accepted
+		}
+	}
+	deadline := start.Add(w.timeout)
+
+	for _, htlc := range invoice.Htlcs {
+		if htlc.State != lndrest.InvoiceHTLCState_ACCEPTED {
+			continue
+		}
+
+		blocks := htlc.ExpiryHeight - htlc.AcceptHeight - holdSafetyBlocks
+		cltvDeadline := time.Unix(htlc.AcceptTime, 0).Add(time.Duration(blocks) * holdBlockInterval)
+		if cltvDeadline.Before(deadline) {
+			deadline = cltvDeadline
+		}
+	}
+
+	return deadline
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type holdTest struct {
+	lnd      *fakeLND
+	streams  <-chan *fakeStream
+	settled  <-chan map[string][]byte
+	canceled <-chan map[string][]byte
+	logPath  string
+}
+
+func newHoldTest(t *testing.T) *holdTest {
+	lnd := newFakeLND(t)
+	lnd.reply("/v2/invoices/hodl", lndrest.AddHoldInvoiceResponse{PaymentRequest: "lnbc1hold", AddIndex: "1"})
+
+	return &holdTest{
+		lnd:      lnd,
+		streams:  lnd.stream("/v2/invoices/subscribe/"),
+		settled:  lnd.record("/v2/invoices/settle"),
+		canceled: lnd.record("/v2/invoices/cancel"),
+		logPath:  filepath.Join(t.TempDir(), "hold_invoices.jsonl"),
+	}
+}
+
+// workflow opens a workflow on the test's log, like lmt does at startup.
+func (h *holdTest) workflow(t *testing.T, condition HoldConditionFunc, timeout time.Duration) *HoldInvoiceWorkflow {
+	log, err := store.OpenJSONLog(h.logPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { log.Close() })
+
+	w, err := NewHoldInvoiceWorkflow(h.lnd.client, condition, timeout, log)
+	require.NoError(t, err)
+	w.retryInterval = 10 * time.Millisecond
+	return w
+}
+
+func (h *holdTest) create(t *testing.T, w *HoldInvoiceWorkflow) []byte {
+	res, err := w.CreateInvoice(context.Background(), lndrest.CreateInvoiceParams{ValueMsat: 5000}, HoldApprovalRequest{Comment: "hi"})
+	require.NoError(t, err)
+	return res.RHash
+}
+
+func accepted(amountMsat int64) lndrest.Invoice {
+	return lndrest.Invoice{
+		State:       lndrest.InvoiceState_ACCEPTED,
+		AmtPaidMsat: amountMsat,
+		Htlcs: []lndrest.InvoiceHTLC{{
+			State:        lndrest.InvoiceHTLCState_ACCEPTED,
+			AcceptHeight: 100,
+			ExpiryHeight: 180,
+			AcceptTime:   time.Now().Unix(),
+		}},
+	}
+}
+
+func approveAll(context.Context, HoldApprovalRequest) (bool, error) {
+	return true, nil
+}
+
+func TestHoldInvoiceWorkflowApprove(t *testing.T) {
+	h := newHoldTest(t)
+	requests := make(chan HoldApprovalRequest, 1)
+	w := h.workflow(t, func(ctx context.Context, req HoldApprovalRequest) (bool, error) {
+		requests <- req
+		return true, nil
+	}, time.Minute)
+
+	hash := h.create(t, w)
+	receive(t, h.streams).send(accepted(5000))
+
+	req := receive(t, requests)
+	assert.Equal(t, hex.EncodeToString(hash), req.PaymentHash)
+	assert.Equal(t, int64(5000), req.AmountMsat)
+	assert.Equal(t, "hi", req.Comment)
+
+	preimage := receive(t, h.settled)["preimage"]
+	sum := sha256.Sum256(preimage)
+	assert.Equal(t, hash, sum[:])
+
+	// Settled invoices are dropped from the log.
+	require.Eventually(t, func() bool {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		return len(w.pending) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, h.workflow(t, approveAll, time.Minute).pending)
+}
+
+func TestHoldInvoiceWorkflowReject(t *testing.T) {
+	h := newHoldTest(t)
+	w := h.workflow(t, func(context.Context, HoldApprovalRequest) (bool, error) {
+		return false, nil
+	}, time.Minute)
+
+	hash := h.create(t, w)
+	receive(t, h.streams).send(accepted(5000))
+
+	assert.Equal(t, hash, receive(t, h.canceled)["payment_hash"])
+	assert.Empty(t, h.settled)
+}
+
+func TestHoldInvoiceWorkflowDeadline(t *testing.T) {
+	h := newHoldTest(t)
+	w := h.workflow(t, func(ctx context.Context, req HoldApprovalRequest) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}, 50*time.Millisecond)
+
+	hash := h.create(t, w)
+	receive(t, h.streams).send(accepted(5000))
+
+	assert.Equal(t, hash, receive(t, h.canceled)["payment_hash"])
+}
+
+func TestHoldInvoiceWorkflowStreamClosed(t *testing.T) {
+	h := newHoldTest(t)
+	w := h.workflow(t, approveAll, time.Minute)
+
+	hash := h.create(t, w)
+	receive(t, h.streams).close()
+
+	// The workflow subscribes again and still settles the payment.
+	receive(t, h.streams).send(accepted(5000))
+	sum := sha256.Sum256(receive(t, h.settled)["preimage"])
+	assert.Equal(t, hash, sum[:])
+}
+
+func TestHoldInvoiceWorkflowResume(t *testing.T) {
+	h := newHoldTest(t)
+	hash := h.create(t, h.workflow(t, approveAll, time.Minute))
+	receive(t, h.streams)
+
+	// lmt restarts before the payment arrives.
+	w := h.workflow(t, approveAll, time.Minute)
+	require.Len(t, w.pending, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.Resume(ctx)
+
+	receive(t, h.streams).send(accepted(5000))
+	sum := sha256.Sum256(receive(t, h.settled)["preimage"])
+	assert.Equal(t, hash, sum[:])
+}
+
+func TestHoldInvoiceWorkflowResumeDecision(t *testing.T) {
+	h := newHoldTest(t)
+	approved := true
+	record := holdRecord{HoldApprovalRequest: HoldApprovalRequest{PaymentHash: "00"}, Preimage: "01", Approved: &approved, ExpiresAt: time.Now()}
+	require.NoError(t, h.workflow(t, approveAll, time.Minute).save(record))
+
+	// A decision made before a restart is carried out without asking again.
+	w := h.workflow(t, func(context.Context, HoldApprovalRequest) (bool, error) {
+		t.Error("condition asked again")
+		return false, nil
+	}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.Resume(ctx)
+
+	receive(t, h.streams).send(accepted(5000))
+	assert.Equal(t, []byte{0x01}, receive(t, h.settled)["preimage"])
+}
+
+func TestHoldInvoiceWorkflowStopDuringApproval(t *testing.T) {
+	h := newHoldTest(t)
+	hash := h.create(t, h.workflow(t, approveAll, time.Minute))
+	receive(t, h.streams)
+
+	asked := make(chan struct{})
+	w := h.workflow(t, func(ctx context.Context, req HoldApprovalRequest) (bool, error) {
+		close(asked)
+		<-ctx.Done()
+		return false, ctx.Err()
+	}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Resume(ctx)
+
+	receive(t, h.streams).send(accepted(5000))
+	<-asked
+	cancel()
+
+	// lmt stops while waiting for approval; the invoice isn't canceled but
+	// kept to be resolved after the restart.
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, h.canceled)
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	require.Contains(t, w.pending, hex.EncodeToString(hash))
+	assert.Nil(t, w.pending[hex.EncodeToString(hash)].Approved)
+}
+
+func TestHoldInvoiceWorkflowResolveBy(t *testing.T) {
+	w := newHoldTest(t).workflow(t, approveAll, 10*time.Minute)
+	expiresAt := time.Now().Add(5 * time.Minute)
+
+	// A zap paid just before expiry is settled up to the hold timeout later.
+	assert.True(t, w.ResolveBy(expiresAt).After(expiresAt.Add(10*time.Minute)))
+}
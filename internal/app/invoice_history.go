@@ -0,0 +1,223 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatus_OPEN     InvoiceStatus = "OPEN"
+	InvoiceStatus_SETTLED  InvoiceStatus = "SETTLED"
+	InvoiceStatus_CANCELED InvoiceStatus = "CANCELED"
+	// InvoiceStatus_EXPIRED is never stored; open invoices past their expiry are reported as expired.
+	InvoiceStatus_EXPIRED InvoiceStatus = "EXPIRED"
+)
+
+// InvoiceRecord is an issued invoice and what became of it.
+type InvoiceRecord struct {
+	IssuedInvoice
+	Status         InvoiceStatus `json:"status"`
+	AmountPaidMsat int64         `json:"amount_paid_msat,omitempty"`
+	SettledAt      *time.Time    `json:"settled_at,omitempty"`
+}
+
+// InvoiceQuery filters the invoice history. Zero values match everything.
+type InvoiceQuery struct {
+	From     time.Time
+	Until    time.Time
+	Username string
+	Settled  *bool
+	ZapsOnly bool
+
+	Offset int
+	Limit  int
+}
+
+func (q InvoiceQuery) matches(record InvoiceRecord) bool {
+	if !q.From.IsZero() && record.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.Until.IsZero() && !record.CreatedAt.Before(q.Until) {
+		return false
+	}
+	if q.Username != "" && record.Username != q.Username {
+		return false
+	}
+	if q.Settled != nil && (record.Status == InvoiceStatus_SETTLED) != *q.Settled {
+		return false
+	}
+	if q.ZapsOnly && record.ZapRequest == "" {
+		return false
+	}
+	return true
+}
+
+// ParseTime parses a time filter given as RFC 3339 or unix seconds.
+// An empty string is the zero time, which matches everything.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// InvoiceHistory persists every invoice lmt issues, together with its
+// settlement. It is kept up to date as a listener of the PaymentTracker.
+type InvoiceHistory struct {
+	log *store.JSONLog
+
+	mtx     sync.RWMutex
+	records map[string]*InvoiceRecord
+	ordered []*InvoiceRecord // by CreatedAt, oldest first
+}
+
+// NewInvoiceHistory loads the history from log and compacts it.
+func NewInvoiceHistory(log *store.JSONLog) (*InvoiceHistory, error) {
+	h, err := ReadInvoiceHistory(log)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]interface{}, 0, len(h.ordered))
+	for _, record := range h.ordered {
+		records = append(records, record)
+	}
+
+	// Every update appends a record, so compact the log to one line per invoice.
+	if err := log.Rewrite(records); err != nil {
+		return nil, fmt.Errorf("failed to compact invoice history: %w", err)
+	}
+
+	return h, nil
+}
+
+// ReadInvoiceHistory loads the history from log without writing to it, so it
+// is safe to use while lmt is running.
+func ReadInvoiceHistory(log *store.JSONLog) (*InvoiceHistory, error) {
+	h := &InvoiceHistory{
+		log:     log,
+		records: make(map[string]*InvoiceRecord),
+	}
+
+	err := log.Replay(func(line []byte) error {
+		var record InvoiceRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil
+		}
+		h.records[record.PaymentHash] = &record
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice history: %w", err)
+	}
+
+	for _, record := range h.records {
+		h.ordered = append(h.ordered, record)
+	}
+	sort.Slice(h.ordered, func(i, j int) bool {
+		return h.ordered[i].CreatedAt.Before(h.ordered[j].CreatedAt)
+	})
+
+	return h, nil
+}
+
+func (h *InvoiceHistory) OnInvoiceIssued(invoice IssuedInvoice) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	record := &InvoiceRecord{IssuedInvoice: invoice, Status: InvoiceStatus_OPEN}
+	h.records[invoice.PaymentHash] = record
+	h.ordered = append(h.ordered, record)
+	h.persist(record)
+}
+
+func (h *InvoiceHistory) OnPaymentSettled(payment SettledPayment) {
+	h.update(payment.PaymentHash, func(record *InvoiceRecord) {
+		settledAt := payment.SettledAt
+		record.Status = InvoiceStatus_SETTLED
+		record.AmountPaidMsat = payment.AmountPaidMsat
+		record.SettledAt = &settledAt
+	})
+}
+
+func (h *InvoiceHistory) OnInvoiceCanceled(invoice IssuedInvoice) {
+	h.update(invoice.PaymentHash, func(record *InvoiceRecord) {
+		record.Status = InvoiceStatus_CANCELED
+	})
+}
+
+func (h *InvoiceHistory) update(paymentHash string, fn func(record *InvoiceRecord)) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	record, ok := h.records[paymentHash]
+	if !ok {
+		return
+	}
+	fn(record)
+	h.persist(record)
+}
+
+// persist appends the record's current state to the log. It must be called with mtx held.
+func (h *InvoiceHistory) persist(record *InvoiceRecord) {
+	if err := h.log.Append(record); err != nil {
+		slog.Error("Failed to persist invoice record", "payment_hash", record.PaymentHash, "error", err)
+	}
+}
+
+// Open returns the invoices that are neither settled nor canceled, so they
+// can be tracked and reconciled with LND again after a restart. This includes
+// expired ones, which may have been paid while lmt was down.
+func (h *InvoiceHistory) Open() []IssuedInvoice {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	var open []IssuedInvoice
+	for _, record := range h.ordered {
+		if record.Status == InvoiceStatus_OPEN {
+			open = append(open, record.IssuedInvoice)
+		}
+	}
+	return open
+}
+
+// Query returns the matching records, newest first, and the total number of matches.
+func (h *InvoiceHistory) Query(q InvoiceQuery) ([]InvoiceRecord, int) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	now := time.Now()
+	var (
+		records []InvoiceRecord
+		total   int
+	)
+	for i := len(h.ordered) - 1; i >= 0; i-- {
+		record := *h.ordered[i]
+		if !q.matches(record) {
+			continue
+		}
+
+		total++
+		if total <= q.Offset || (q.Limit > 0 && len(records) >= q.Limit) {
+			continue
+		}
+
+		if record.Status == InvoiceStatus_OPEN && now.After(record.ExpiresAt) {
+			record.Status = InvoiceStatus_EXPIRED
+		}
+		records = append(records, record)
+	}
+
+	return records, total
+}
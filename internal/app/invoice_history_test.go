@@ -0,0 +1,80 @@
+package app
+
+import (
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInvoiceHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invoices.jsonl")
+	open := func() *InvoiceHistory {
+		log, err := store.OpenJSONLog(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { log.Close() })
+
+		history, err := NewInvoiceHistory(log)
+		require.NoError(t, err)
+		return history
+	}
+
+	start := time.Now().Add(-time.Hour)
+	invoice := func(hash string, minutes int, zap bool) IssuedInvoice {
+		inv := IssuedInvoice{
+			PaymentHash: hash,
+			Source:      InvoiceSource_HTTP,
+			Username:    "alice",
+			AmountMsat:  1000,
+			CreatedAt:   start.Add(time.Duration(minutes) * time.Minute),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		if zap {
+			inv.ZapRequest = "{}"
+		}
+		return inv
+	}
+
+	history := open()
+	history.OnInvoiceIssued(invoice("a", 1, false))
+	history.OnInvoiceIssued(invoice("b", 2, true))
+	history.OnInvoiceIssued(invoice("c", 3, false))
+	history.OnPaymentSettled(SettledPayment{IssuedInvoice: invoice("b", 2, true), AmountPaidMsat: 1000, SettledAt: time.Now()})
+	history.OnInvoiceCanceled(invoice("c", 3, false))
+
+	// Reload to check that the log round-trips.
+	history = open()
+
+	records, total := history.Query(InvoiceQuery{})
+	assert.Equal(t, 3, total)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"c", "b", "a"}, []string{records[0].PaymentHash, records[1].PaymentHash, records[2].PaymentHash})
+	assert.Equal(t, InvoiceStatus_CANCELED, records[0].Status)
+	assert.Equal(t, InvoiceStatus_SETTLED, records[1].Status)
+	assert.NotNil(t, records[1].SettledAt)
+	assert.Equal(t, InvoiceStatus_OPEN, records[2].Status)
+
+	settled := true
+	records, total = history.Query(InvoiceQuery{Settled: &settled})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "b", records[0].PaymentHash)
+
+	records, _ = history.Query(InvoiceQuery{ZapsOnly: true})
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].PaymentHash)
+
+	records, total = history.Query(InvoiceQuery{From: start.Add(2 * time.Minute), Offset: 1, Limit: 1})
+	assert.Equal(t, 2, total)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].PaymentHash)
+
+	records, total = history.Query(InvoiceQuery{Username: "bob"})
+	assert.Equal(t, 0, total)
+	assert.Empty(t, records)
+
+	openInvoices := history.Open()
+	require.Len(t, openInvoices, 1)
+	assert.Equal(t, "a", openInvoices[0].PaymentHash)
+}
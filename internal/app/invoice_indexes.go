@@ -0,0 +1,67 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"sync"
+)
+
+// InvoiceIndexes remembers the add and settle index of the last invoice
+// update lmt has handled, so the invoice subscription resumes there after a
+// restart and replays the payments that arrived while lmt was down.
+type InvoiceIndexes struct {
+	log *store.JSONLog
+
+	mtx     sync.Mutex
+	current lndrest.SubscribeInvoicesParams
+}
+
+// NewInvoiceIndexes loads the indexes from log.
+func NewInvoiceIndexes(log *store.JSONLog) (*InvoiceIndexes, error) {
+	i := &InvoiceIndexes{log: log}
+
+	err := log.Replay(func(line []byte) error {
+		var indexes lndrest.SubscribeInvoicesParams
+		if err := json.Unmarshal(line, &indexes); err == nil {
+			i.current = indexes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice indexes: %w", err)
+	}
+
+	return i, nil
+}
+
+// Get returns the indexes to resume the subscription from.
+func (i *InvoiceIndexes) Get() lndrest.SubscribeInvoicesParams {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.current
+}
+
+// Advance records that the update has been handled.
+func (i *InvoiceIndexes) Advance(invoice lndrest.Invoice) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	next := i.current
+	next.AddIndex = max(next.AddIndex, invoice.AddIndex)
+	if invoice.State == lndrest.InvoiceState_SETTLED {
+		next.SettleIndex = max(next.SettleIndex, invoice.SettleIndex)
+	}
+	if next == i.current {
+		return
+	}
+
+	// The file holds only the latest indexes.
+	if err := i.log.Rewrite([]interface{}{next}); err != nil {
+		slog.Error("Failed to persist invoice indexes", "error", err)
+		return
+	}
+	i.current = next
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"time"
+)
+
+// InvoiceSource tells where an invoice request came from.
+type InvoiceSource string
+
+const (
+	InvoiceSource_HTTP    InvoiceSource = "http"
+	InvoiceSource_OKSUSU  InvoiceSource = "oksusu"
+	InvoiceSource_PAYPAGE InvoiceSource = "paypage"
+)
+
+const (
+	// zapInvoiceExpiry is the expiry of zap invoices, in seconds.
+	zapInvoiceExpiry = 300
+	// defaultInvoiceExpiry is what LND uses when no expiry is given.
+	defaultInvoiceExpiry = 24 * time.Hour
+)
+
+var (
+	ErrNostrDisabled = errors.New("Nostr functionality is disabled")
+	// ErrTooManyOutstanding is returned when too many issued invoices are still unpaid.
+	ErrTooManyOutstanding = errors.New("too many unpaid invoices, please try again later")
+)
+
+// InvoiceRequest is an LNURL-pay callback, from the HTTP server or Oksusu, or
+// a payment made on the pay page.
+type InvoiceRequest struct {
+	Source     InvoiceSource
+	Username   string
+	AmountMsat int64
+	Comment    string
+	// Memo is the invoice description. It defaults to the comment.
+	Memo       string
+	ZapRequest string
+	PayerData  json.RawMessage
+}
+
+// InvoiceIssuer creates invoices for LNURL-pay callbacks and registers them
+// with everything that acts on their payment.
+type InvoiceIssuer struct {
+	lndService     *lndrest.Client
+	holdWorkflow   *HoldInvoiceWorkflow
+	zapMonitor     ZapMonitor
+	paymentTracker *PaymentTracker
+	nostrPublicKey string
+	maxOutstanding int
+	invoiceExpiry  time.Duration
+}
+
+// NewInvoiceIssuer creates an InvoiceIssuer. Once maxOutstanding invoices are
+// unpaid, no new ones are issued until some are paid or expire; 0 means no limit.
+// Invoices expire after invoiceExpiry, or LND's default if it is 0. Zap
+// invoices expire after five minutes at most.
+func NewInvoiceIssuer(lndService *lndrest.Client, holdWorkflow *HoldInvoiceWorkflow, zapMonitor ZapMonitor, paymentTracker *PaymentTracker, nostrPublicKey string, maxOutstanding int, invoiceExpiry time.Duration) InvoiceIssuer {
+	return InvoiceIssuer{
+		lndService:     lndService,
+		holdWorkflow:   holdWorkflow,
+		zapMonitor:     zapMonitor,
+		paymentTracker: paymentTracker,
+		nostrPublicKey: nostrPublicKey,
+		maxOutstanding: maxOutstanding,
+		invoiceExpiry:  invoiceExpiry,
+	}
+}
+
+// isNostrEnabled checks if Nostr functionality is enabled by checking if public key is set
+func (i InvoiceIssuer) isNostrEnabled() bool {
+	return i.nostrPublicKey != ""
+}
+
+// Issue creates an invoice for the request.
+func (i InvoiceIssuer) Issue(ctx context.Context, req InvoiceRequest) (IssuedInvoice, error) {
+	if i.maxOutstanding > 0 && i.paymentTracker.Outstanding() >= i.maxOutstanding {
+		return IssuedInvoice{}, ErrTooManyOutstanding
+	}
+
+	params := lndrest.CreateInvoiceParams{
+		ValueMsat: req.AmountMsat,
+		Memo:      cmp.Or(req.Memo, req.Comment),
+		Expiry:    int64(i.invoiceExpiry.Seconds()),
+	}
+
+	var zapRequest nostr.Event
+	if req.ZapRequest != "" {
+		if !i.isNostrEnabled() {
+			return IssuedInvoice{}, ErrNostrDisabled
+		}
+
+		if err := json.Unmarshal([]byte(req.ZapRequest), &zapRequest); err != nil {
+			return IssuedInvoice{}, fmt.Errorf("failed to unmarshal nostr event: %w", err)
+		}
+
+		if _, err := nostrpkg.ParseZapRequest(zapRequest, i.nostrPublicKey); err != nil {
+			return IssuedInvoice{}, fmt.Errorf("invalid zap request: %w", err)
+		}
+
+		// As per NIP-57, the description hash for a zap invoice is the sha256 hash of the zap request event.
+		descriptionHash := sha256.Sum256([]byte(req.ZapRequest))
+		params.DescriptionHash = descriptionHash[:]
+		if params.Expiry == 0 || params.Expiry > zapInvoiceExpiry {
+			params.Expiry = zapInvoiceExpiry
+		}
+	}
+
+	if len(req.PayerData) > 0 && !json.Valid(req.PayerData) {
+		return IssuedInvoice{}, fmt.Errorf("invalid payer data")
+	}
+
+	var (
+		res lndrest.CreateInvoiceResponse
+		err error
+	)
+	if i.holdWorkflow != nil {
+		res, err = i.holdWorkflow.CreateInvoice(ctx, params, HoldApprovalRequest{
+			Comment:    req.Comment,
+			ZapRequest: req.ZapRequest,
+		})
+	} else {
+		res, err = i.lndService.CreateInvoice(ctx, params)
+	}
+	if err != nil {
+		return IssuedInvoice{}, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	// A zap carries its comment in the zap request.
+	comment := req.Comment
+	if comment == "" {
+		comment = zapRequest.Content
+	}
+
+	now := time.Now()
+	expiry := defaultInvoiceExpiry
+	if params.Expiry > 0 {
+		expiry = time.Duration(params.Expiry) * time.Second
+	}
+
+	issued := IssuedInvoice{
+		PaymentHash:    hex.EncodeToString(res.RHash),
+		PaymentRequest: res.PaymentRequest,
+		Source:         req.Source,
+		Username:       req.Username,
+		AmountMsat:     req.AmountMsat,
+		Comment:        comment,
+		Memo:           params.Memo,
+		PayerData:      req.PayerData,
+		ZapRequest:     req.ZapRequest,
+		ZapSender:      zapRequest.PubKey,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(expiry),
+	}
+	metrics.InvoicesCreated.WithLabelValues(string(req.Source)).Inc()
+	i.paymentTracker.Track(issued)
+
+	if req.ZapRequest != "" {
+		// A held zap is settled only once it is approved, which can be
+		// well after the invoice expired.
+		deadline := issued.ExpiresAt
+		if i.holdWorkflow != nil {
+			deadline = i.holdWorkflow.ResolveBy(deadline)
+		}
+		go i.zapMonitor.MonitorAndSendZapReceipt(
+			context.Background(),
+			res.RHash,
+			zapRequest,
+			req.ZapRequest,
+			deadline,
+		)
+	}
+
+	return issued, nil
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// InvoiceWatcher shares one resilient invoice subscription between everything
+// in lmt that waits for invoices to be paid. Updates are queued for each
+// watcher, so a slow watcher never loses one.
+type InvoiceWatcher struct {
+	subscription *lndrest.InvoiceSubscription
+
+	mtx      sync.Mutex
+	watchers map[string][]*invoiceQueue
+	all      []*invoiceQueue
+}
+
+// NewInvoiceWatcher creates a watcher that resumes from the indexes the
+// payment tracker has handled, so that updates missed while lmt was down are
+// replayed. indexes may be nil to start at the node's current invoices.
+func NewInvoiceWatcher(lnd *lndrest.Client, indexes *InvoiceIndexes) *InvoiceWatcher {
+	var start lndrest.SubscribeInvoicesParams
+	if indexes != nil {
+		start = indexes.Get()
+	}
+
+	return &InvoiceWatcher{
+		subscription: lndrest.NewInvoiceSubscription(lnd, start),
+		watchers:     make(map[string][]*invoiceQueue),
+	}
+}
+
+// Run runs the subscription and dispatches updates until ctx is canceled.
+func (w *InvoiceWatcher) Run(ctx context.Context) error {
+	go func() {
+		for subErr := range w.subscription.Errors() {
+			slog.Error("Invoice subscription error", "kind", subErr.Kind, "error", subErr.Err)
+		}
+	}()
+
+	go func() {
+		for invoice := range w.subscription.Invoices() {
+			w.dispatch(invoice)
+		}
+	}()
+
+	return w.subscription.Run(ctx)
+}
+
+// State returns the connection state of the underlying subscription.
+func (w *InvoiceWatcher) State() lndrest.SubscriptionState {
+	return w.subscription.State()
+}
+
+// Watch returns a channel of updates for the invoice with the given payment hash.
+// The returned function stops watching and must be called when done.
+func (w *InvoiceWatcher) Watch(paymentHash []byte) (<-chan lndrest.Invoice, func()) {
+	key := hex.EncodeToString(paymentHash)
+	q := newInvoiceQueue()
+
+	w.mtx.Lock()
+	w.watchers[key] = append(w.watchers[key], q)
+	w.mtx.Unlock()
+
+	stop := func() {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+
+		queues := slices.DeleteFunc(w.watchers[key], func(other *invoiceQueue) bool { return other == q })
+		if len(queues) == 0 {
+			delete(w.watchers, key)
+		} else {
+			w.watchers[key] = queues
+		}
+		q.close()
+	}
+
+	return q.out, stop
+}
+
+// WatchAll returns a channel of updates for every invoice.
+// The returned function stops watching and must be called when done.
+func (w *InvoiceWatcher) WatchAll() (<-chan lndrest.Invoice, func()) {
+	q := newInvoiceQueue()
+
+	w.mtx.Lock()
+	w.all = append(w.all, q)
+	w.mtx.Unlock()
+
+	stop := func() {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+
+		w.all = slices.DeleteFunc(w.all, func(other *invoiceQueue) bool { return other == q })
+		q.close()
+	}
+
+	return q.out, stop
+}
+
+func (w *InvoiceWatcher) dispatch(invoice lndrest.Invoice) {
+	key := hex.EncodeToString(invoice.RHash)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, queues := range [][]*invoiceQueue{w.watchers[key], w.all} {
+		for _, q := range queues {
+			q.push(invoice)
+		}
+	}
+}
+
+// invoiceQueue delivers invoice updates to a watcher in order. It has no
+// limit, so pushing never blocks the subscription and never drops an update.
+type invoiceQueue struct {
+	out chan lndrest.Invoice
+
+	mtx     sync.Mutex
+	pending []lndrest.Invoice
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func newInvoiceQueue() *invoiceQueue {
+	q := &invoiceQueue{
+		out:  make(chan lndrest.Invoice),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *invoiceQueue) push(invoice lndrest.Invoice) {
+	q.mtx.Lock()
+	q.pending = append(q.pending, invoice)
+	q.mtx.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *invoiceQueue) run() {
+	for {
+		q.mtx.Lock()
+		var (
+			next lndrest.Invoice
+			ok   bool
+		)
+		if len(q.pending) > 0 {
+			next, ok = q.pending[0], true
+			q.pending[0] = lndrest.Invoice{}
+			q.pending = q.pending[1:]
+		}
+		q.mtx.Unlock()
+
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case q.out <- next:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *invoiceQueue) close() {
+	close(q.done)
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// invoiceLND serves an empty invoice list and hands the invoice stream to the test.
+func invoiceLND(t *testing.T) (*fakeLND, <-chan *fakeStream) {
+	lnd := newFakeLND(t)
+	lnd.reply("/v1/invoices", lndrest.ListInvoicesResponse{})
+	return lnd, lnd.stream("/v1/invoices/subscribe")
+}
+
+func runWatcher(t *testing.T, w *InvoiceWatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestInvoiceWatcherFlood(t *testing.T) {
+	lnd, streams := invoiceLND(t)
+	w := NewInvoiceWatcher(lnd.client, nil)
+
+	updates, stop := w.WatchAll()
+	defer stop()
+	paid, stopPaid := w.Watch([]byte{0xaa})
+	defer stopPaid()
+
+	runWatcher(t, w)
+	stream := receive(t, streams)
+
+	// A burst of new invoices that nobody reads yet, then a payment.
+	const burst = 1000
+	for i := 1; i <= burst; i++ {
+		stream.send(lndrest.Invoice{AddIndex: uint64(i), State: lndrest.InvoiceState_OPEN})
+	}
+	stream.send(lndrest.Invoice{RHash: []byte{0xaa}, AddIndex: 1, SettleIndex: 1, State: lndrest.InvoiceState_SETTLED})
+	require.Eventually(t, func() bool { return w.subscription.Indexes().SettleIndex == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	for i := 1; i <= burst; i++ {
+		invoice := receive(t, updates)
+		require.Equal(t, uint64(i), invoice.AddIndex)
+	}
+	assert.Equal(t, lndrest.InvoiceState_SETTLED, receive(t, updates).State)
+	assert.Equal(t, lndrest.InvoiceState_SETTLED, receive(t, paid).State)
+}
+
+func TestPaymentTrackerResumesFromHandledIndexes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invoice_indexes.jsonl")
+	openIndexes := func() *InvoiceIndexes {
+		log, err := store.OpenJSONLog(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { log.Close() })
+
+		indexes, err := NewInvoiceIndexes(log)
+		require.NoError(t, err)
+		return indexes
+	}
+
+	lnd, streams := invoiceLND(t)
+	indexes := openIndexes()
+	w := NewInvoiceWatcher(lnd.client, indexes)
+	tracker := NewPaymentTracker(nil, w, indexes)
+	history := &recordingListener{settled: make(chan SettledPayment, 1)}
+	tracker.AddListener(history)
+	tracker.Track(IssuedInvoice{PaymentHash: "aa", ExpiresAt: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Run(ctx)
+	runWatcher(t, w)
+
+	stream := receive(t, streams)
+	stream.send(lndrest.Invoice{AddIndex: 7, State: lndrest.InvoiceState_OPEN})
+	stream.send(lndrest.Invoice{RHash: []byte{0xaa}, AddIndex: 6, SettleIndex: 3, State: lndrest.InvoiceState_SETTLED})
+	assert.Equal(t, "aa", receive(t, history.settled).PaymentHash)
+
+	require.Eventually(t, func() bool {
+		return openIndexes().Get() == lndrest.SubscribeInvoicesParams{AddIndex: 7, SettleIndex: 3}
+	}, time.Second, 10*time.Millisecond)
+
+	// After a restart, the subscription resumes where the tracker stopped.
+	restarted := NewInvoiceWatcher(lnd.client, openIndexes())
+	runWatcher(t, restarted)
+	query := receive(t, streams).r.URL.Query()
+	assert.Equal(t, "7", query.Get("add_index"))
+	assert.Equal(t, "3", query.Get("settle_index"))
+}
+
+type recordingListener struct {
+	settled chan SettledPayment
+}
+
+func (l *recordingListener) OnPaymentSettled(payment SettledPayment) {
+	l.settled <- payment
+}
+
+func TestPaymentTrackerReconcilesResumedInvoices(t *testing.T) {
+	log, err := store.OpenJSONLog(filepath.Join(t.TempDir(), "invoices.jsonl"))
+	require.NoError(t, err)
+	defer log.Close()
+	history, err := NewInvoiceHistory(log)
+	require.NoError(t, err)
+
+	// Invoices issued before lmt went down: one was paid meanwhile, one
+	// expired and was deleted by LND, and one is still open.
+	expired := time.Now().Add(-2 * trackingGrace)
+	for _, invoice := range []IssuedInvoice{
+		{PaymentHash: "aa", ExpiresAt: expired},
+		{PaymentHash: "bb", ExpiresAt: expired},
+		{PaymentHash: "cc", ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		history.OnInvoiceIssued(invoice)
+	}
+
+	lnd, _ := invoiceLND(t)
+	lnd.reply("/v1/invoice/aa", lndrest.Invoice{RHash: []byte{0xaa}, State: lndrest.InvoiceState_SETTLED, AmtPaidMsat: 5000, SettleDate: 1700000000})
+	lnd.handle("/v1/invoice/bb", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":5,"message":"there are no existing invoices"}`, http.StatusNotFound)
+	})
+	lnd.reply("/v1/invoice/cc", lndrest.Invoice{RHash: []byte{0xcc}, State: lndrest.InvoiceState_OPEN})
+
+	tracker := NewPaymentTracker(lnd.client, NewInvoiceWatcher(lnd.client, nil), nil)
+	settled := &recordingListener{settled: make(chan SettledPayment, 1)}
+	tracker.AddListener(history)
+	tracker.AddListener(settled)
+	tracker.Resume(history.Open())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Run(ctx)
+
+	payment := receive(t, settled.settled)
+	assert.Equal(t, "aa", payment.PaymentHash)
+	assert.Equal(t, int64(5000), payment.AmountPaidMsat)
+	assert.Equal(t, time.Unix(1700000000, 0), payment.SettledAt)
+
+	require.Eventually(t, func() bool { return len(history.Open()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "cc", history.Open()[0].PaymentHash)
+	records, _ := history.Query(InvoiceQuery{})
+	statuses := map[string]InvoiceStatus{}
+	for _, record := range records {
+		statuses[record.PaymentHash] = record.Status
+	}
+	assert.Equal(t, map[string]InvoiceStatus{"aa": InvoiceStatus_SETTLED, "bb": InvoiceStatus_CANCELED, "cc": InvoiceStatus_OPEN}, statuses)
+	assert.Equal(t, 1, tracker.Outstanding())
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type LedgerFormat string
+
+const (
+	LedgerFormat_CSV  LedgerFormat = "csv"
+	LedgerFormat_JSON LedgerFormat = "json"
+	// LedgerFormat_KOINLY is the Koinly universal CSV, which most tax tools can import.
+	LedgerFormat_KOINLY LedgerFormat = "koinly"
+)
+
+// ParseLedgerFormat checks that s is a known format.
+func ParseLedgerFormat(s string) (LedgerFormat, error) {
+	switch format := LedgerFormat(s); format {
+	case LedgerFormat_CSV, LedgerFormat_JSON, LedgerFormat_KOINLY:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, expected csv, json or koinly", s)
+	}
+}
+
+// LedgerEntry is a settled payment, as income for accounting.
+type LedgerEntry struct {
+	Date         time.Time `json:"date"`
+	PaymentHash  string    `json:"payment_hash"`
+	AmountSat    int64     `json:"amount_sat"`
+	AmountMsat   int64     `json:"amount_msat"`
+	FiatValue    *float64  `json:"fiat_value,omitempty"`
+	FiatCurrency string    `json:"fiat_currency,omitempty"`
+	Memo         string    `json:"memo"`
+	Source       string    `json:"source"`
+	Username     string    `json:"username"`
+	ZapSender    string    `json:"zap_sender,omitempty"`
+}
+
+// SettledBetween returns the records settled in [from, until). A zero from or
+// until leaves that end open. The ledger is dated by settlement, not creation.
+func SettledBetween(records []InvoiceRecord, from, until time.Time) []InvoiceRecord {
+	var settled []InvoiceRecord
+	for _, record := range records {
+		if record.Status != InvoiceStatus_SETTLED || record.SettledAt == nil {
+			continue
+		}
+		if record.SettledAt.Before(from) || (!until.IsZero() && !record.SettledAt.Before(until)) {
+			continue
+		}
+		settled = append(settled, record)
+	}
+	return settled
+}
+
+// BuildLedger turns the settled records, newest first as returned by
+// InvoiceHistory.Query, into ledger entries, oldest first.
+// When prices is set, every entry is valued in currency at its settlement time.
+func BuildLedger(ctx context.Context, records []InvoiceRecord, prices fiat.PriceSource, currency string) ([]LedgerEntry, error) {
+	entries := make([]LedgerEntry, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Status != InvoiceStatus_SETTLED || record.SettledAt == nil {
+			continue
+		}
+
+		// The memo is the invoice description, e.g. with the fiat amount asked
+		// for. Invoices issued before it was kept only have the comment.
+		entry := LedgerEntry{
+			Date:        record.SettledAt.UTC(),
+			PaymentHash: record.PaymentHash,
+			AmountSat:   record.AmountPaidMsat / 1000,
+			AmountMsat:  record.AmountPaidMsat,
+			Memo:        cmp.Or(record.Memo, record.Comment),
+			Source:      string(record.Source),
+			Username:    record.Username,
+			ZapSender:   record.ZapSender,
+		}
+
+		if prices != nil {
+			price, err := prices.Price(ctx, currency, entry.Date)
+			switch {
+			case errors.Is(err, fiat.ErrNoPrice):
+				slog.Warn("No fiat price for payment, leaving its value empty", "payment_hash", entry.PaymentHash, "error", err)
+			case err != nil:
+				return nil, fmt.Errorf("failed to get %s price: %w", currency, err)
+			default:
+				value := fiat.MsatToFiat(entry.AmountMsat, price)
+				entry.FiatValue = &value
+				entry.FiatCurrency = currency
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// WriteLedger writes the entries to w in the given format.
+func WriteLedger(w io.Writer, format LedgerFormat, entries []LedgerEntry) error {
+	switch format {
+	case LedgerFormat_JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case LedgerFormat_KOINLY:
+		return writeKoinlyLedger(w, entries)
+	default:
+		return writeCSVLedger(w, entries)
+	}
+}
+
+func writeCSVLedger(w io.Writer, entries []LedgerEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "payment_hash", "amount_sat", "amount_msat", "fiat_value", "fiat_currency", "memo", "source", "username", "zap_sender"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Date.Format(time.RFC3339),
+			e.PaymentHash,
+			strconv.FormatInt(e.AmountSat, 10),
+			strconv.FormatInt(e.AmountMsat, 10),
+			formatFiat(e.FiatValue),
+			e.FiatCurrency,
+			e.Memo,
+			e.Source,
+			e.Username,
+			e.ZapSender,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeKoinlyLedger(w io.Writer, entries []LedgerEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Date.Format("2006-01-02 15:04:05 UTC"),
+			"", "",
+			formatBTC(e.AmountMsat), "BTC",
+			"", "",
+			formatFiat(e.FiatValue), e.FiatCurrency,
+			"income",
+			e.Memo,
+			e.PaymentHash,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFiat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', 2, 64)
+}
+
+// formatBTC formats msat as BTC without losing precision.
+func formatBTC(msat int64) string {
+	return fmt.Sprintf("%d.%011d", msat/1e11, msat%1e11)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	settledAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []InvoiceRecord{
+		{
+			IssuedInvoice: IssuedInvoice{PaymentHash: "open", AmountMsat: 5000},
+			Status:        InvoiceStatus_OPEN,
+		},
+		{
+			IssuedInvoice:  IssuedInvoice{PaymentHash: "paid", Comment: "thanks, mate", Source: InvoiceSource_HTTP, Username: "alice"},
+			Status:         InvoiceStatus_SETTLED,
+			AmountPaidMsat: 21_000_500,
+			SettledAt:      &settledAt,
+		},
+	}
+
+	settled := SettledBetween(records, settledAt.Add(-time.Hour), settledAt.Add(time.Hour))
+	require.Len(t, settled, 1)
+	assert.Empty(t, SettledBetween(records, settledAt.Add(time.Second), time.Time{}))
+
+	entries, err := BuildLedger(context.Background(), settled, fiat.StaticPrices{"USD": 60000}, "USD")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(21000), entries[0].AmountSat)
+	require.NotNil(t, entries[0].FiatValue)
+	assert.InDelta(t, 12.6003, *entries[0].FiatValue, 1e-9)
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteLedger(&buf, LedgerFormat_CSV, entries))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, []string{"2024-03-01T10:00:00Z", "paid", "21000", "21000500", "12.60", "USD", "thanks, mate", "http", "alice", ""}, rows[1])
+	})
+
+	t.Run("koinly", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteLedger(&buf, LedgerFormat_KOINLY, entries))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "Received Amount", rows[0][3])
+		assert.Equal(t, []string{"2024-03-01 10:00:00 UTC", "", "", "0.00021000500", "BTC", "", "", "12.60", "USD", "income", "thanks, mate", "paid"}, rows[1])
+	})
+
+	t.Run("memo", func(t *testing.T) {
+		record := settled[0]
+		record.Memo = "5.00 USD: thanks, mate"
+		entries, err := BuildLedger(context.Background(), []InvoiceRecord{record}, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "5.00 USD: thanks, mate", entries[0].Memo)
+	})
+
+	t.Run("missing price leaves the value empty", func(t *testing.T) {
+		entries, err := BuildLedger(context.Background(), settled, fiat.StaticPrices{}, "EUR")
+		require.NoError(t, err)
+		assert.Nil(t, entries[0].FiatValue)
+		assert.Empty(t, entries[0].FiatCurrency)
+	})
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeLND serves the parts of the LND REST API a test registers.
+type fakeLND struct {
+	t      *testing.T
+	mux    *http.ServeMux
+	client *lndrest.Client
+}
+
+func newFakeLND(t *testing.T) *fakeLND {
+	t.Helper()
+
+	f := &fakeLND{t: t, mux: http.NewServeMux()}
+	server := httptest.NewServer(f.mux)
+	t.Cleanup(server.Close)
+
+	client, err := lndrest.NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+	f.client = client
+	return f
+}
+
+// handle answers requests to pattern with fn.
+func (f *fakeLND) handle(pattern string, fn http.HandlerFunc) {
+	f.mux.HandleFunc(pattern, fn)
+}
+
+// reply answers requests to pattern with v as JSON.
+func (f *fakeLND) reply(pattern string, v interface{}) {
+	f.handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(v)
+	})
+}
+
+// record answers requests to pattern with an empty object and sends their
+// decoded JSON bodies to the returned channel.
+func (f *fakeLND) record(pattern string) <-chan map[string][]byte {
+	bodies := make(chan map[string][]byte, 16)
+	f.handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]byte
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies <- body
+		w.Write([]byte("{}"))
+	})
+	return bodies
+}
+
+// stream upgrades requests to pattern to websockets and hands them to the
+// test, which writes the stream and closes it when done.
+func (f *fakeLND) stream(pattern string) <-chan *fakeStream {
+	streams := make(chan *fakeStream, 16)
+	f.handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		streams <- &fakeStream{t: f.t, conn: conn, r: r}
+		// Keep the stream open until either side closes it.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	return streams
+}
+
+// fakeStream is the LND side of an invoice stream.
+type fakeStream struct {
+	t    *testing.T
+	conn *websocket.Conn
+	r    *http.Request
+}
+
+func (s *fakeStream) send(invoice lndrest.Invoice) {
+	s.t.Helper()
+	require.NoError(s.t, s.conn.WriteJSON(lndrest.SubscribeInvoicesResponse{Result: &invoice}))
+}
+
+func (s *fakeStream) close() {
+	s.conn.Close()
+}
+
+// receive waits for the next value on ch.
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+		var zero T
+		return zero
+	}
+}
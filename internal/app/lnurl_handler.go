@@ -4,26 +4,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/asheswook/lightning-multitool/pkg/lnurl"
+	"net"
 	"net/http"
+	"strings"
 )
 
 type LNURLHandler struct {
 	username       string
 	domain         string
-	maxSendable    int64
-	minSendable    int64
-	commentAllowed int64
+	maxSendable    MaxSendableProvider
+	settings       *LiveSettings
 	nostrPublicKey string
+	converter      *CurrencyConverter
+	onion          *OnionAddress
 }
 
-func NewLNURLHandler(username, domain, nostrPublicKey string, maxSendable, minSendable, commentAllowed int64) LNURLHandler {
+func NewLNURLHandler(username, domain, nostrPublicKey string, maxSendable MaxSendableProvider, settings *LiveSettings, converter *CurrencyConverter, onion *OnionAddress) LNURLHandler {
 	return LNURLHandler{
 		username:       username,
 		domain:         domain,
 		maxSendable:    maxSendable,
-		minSendable:    minSendable,
-		commentAllowed: commentAllowed,
+		settings:       settings,
 		nostrPublicKey: nostrPublicKey,
+		converter:      converter,
+		onion:          onion,
 	}
 }
 
@@ -32,6 +36,22 @@ func (h LNURLHandler) isNostrEnabled() bool {
 	return h.nostrPublicKey != ""
 }
 
+// callbackURL returns the callback on the domain, or on the onion address
+// when the request came in over our onion service. Onion services are served
+// over plain http, which LUD-01 allows for .onion hosts. Other .onion hosts
+// are not ours, so they get the domain like any other host.
+func (h LNURLHandler) callbackURL(r *http.Request) string {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	if onion := h.onion.Get(); onion != "" && strings.EqualFold(host, onion) {
+		return fmt.Sprintf("http://%s/.well-known/lnurlp/%s/callback", r.Host, h.username)
+	}
+	return fmt.Sprintf("https://%s/.well-known/lnurlp/%s/callback", h.domain, h.username)
+}
+
 func (h LNURLHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	username := r.PathValue("user")
 
@@ -53,17 +73,25 @@ func (h LNURLHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	metadata = append(metadata, []string{"text/identifier", identifier})
 
 	j, _ := json.Marshal(metadata)
+	settings := h.settings.Get()
+	maxSendable := h.maxSendable.MaxSendable(r.Context())
+
+	var currencies []lnurl.Currency
+	if h.converter != nil {
+		currencies = h.converter.Currencies(r.Context(), settings.MinSendable, maxSendable)
+	}
 
 	if h.isNostrEnabled() {
 		response := lnurl.PayParamsWithNostr{
 			PayParams: lnurl.PayParams{
 				Response:        lnurl.Response{Status: "OK"},
-				Callback:        fmt.Sprintf("https://%s/.well-known/lnurlp/%s/callback", h.domain, h.username),
-				MaxSendable:     h.maxSendable,
-				MinSendable:     h.minSendable,
+				Callback:        h.callbackURL(r),
+				MaxSendable:     maxSendable,
+				MinSendable:     settings.MinSendable,
 				EncodedMetadata: string(j),
-				CommentAllowed:  h.commentAllowed,
+				CommentAllowed:  settings.CommentAllowed,
 				Tag:             "payRequest",
+				Currencies:      currencies,
 			},
 			AllowsNostr: true,
 			NostrPubkey: h.nostrPublicKey,
@@ -74,12 +102,13 @@ func (h LNURLHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	} else {
 		response := lnurl.PayParams{
 			Response:        lnurl.Response{Status: "OK"},
-			Callback:        fmt.Sprintf("https://%s/.well-known/lnurlp/%s/callback", h.domain, h.username),
-			MaxSendable:     h.maxSendable,
-			MinSendable:     h.minSendable,
+			Callback:        h.callbackURL(r),
+			MaxSendable:     maxSendable,
+			MinSendable:     settings.MinSendable,
 			EncodedMetadata: string(j),
-			CommentAllowed:  h.commentAllowed,
+			CommentAllowed:  settings.CommentAllowed,
 			Tag:             "payRequest",
+			Currencies:      currencies,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
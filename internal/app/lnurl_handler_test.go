@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/pkg/lnurl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLNURLHandlerCallbackURL(t *testing.T) {
+	onion := NewOnionAddress()
+	handler := NewLNURLHandler("satoshi", "example.com", "", staticMaxSendable(1000000), NewLiveSettings(Settings{MinSendable: 1000, CommentAllowed: 255}), nil, onion)
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/satoshi", nil)
+	r.Host = "abcdef.onion"
+	r.SetPathValue("user", "satoshi")
+	w := httptest.NewRecorder()
+	handler.Handle(w, r)
+	assert.Contains(t, w.Body.String(), `"callback":"https://example.com/`, "no onion service published yet")
+
+	onion.Set("abcdef.onion")
+
+	tests := []struct {
+		host, want string
+	}{
+		{"example.com", "https://example.com/.well-known/lnurlp/satoshi/callback"},
+		{"abcdef.onion", "http://abcdef.onion/.well-known/lnurlp/satoshi/callback"},
+		{"abcdef.onion:80", "http://abcdef.onion:80/.well-known/lnurlp/satoshi/callback"},
+		{"ghijkl.onion", "https://example.com/.well-known/lnurlp/satoshi/callback"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/satoshi", nil)
+		r.Host = tt.host
+		r.SetPathValue("user", "satoshi")
+		w := httptest.NewRecorder()
+
+		handler.Handle(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var params lnurl.PayParams
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &params))
+		assert.Equal(t, tt.want, params.Callback, tt.host)
+	}
+}
+
+func TestLNURLHandlerReloadedSettings(t *testing.T) {
+	settings := NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1000000, CommentAllowed: 255})
+	handler := NewLNURLHandler("satoshi", "example.com", "", settings, settings, nil, nil)
+
+	get := func() lnurl.PayParams {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/satoshi", nil)
+		r.SetPathValue("user", "satoshi")
+		w := httptest.NewRecorder()
+		handler.Handle(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var params lnurl.PayParams
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &params))
+		return params
+	}
+
+	params := get()
+	assert.Equal(t, int64(1000000), params.MaxSendable)
+	assert.Equal(t, int64(255), params.CommentAllowed)
+
+	settings.Set(Settings{MinSendable: 2000, MaxSendable: 5000000, CommentAllowed: 0})
+	params = get()
+	assert.Equal(t, int64(2000), params.MinSendable)
+	assert.Equal(t, int64(5000000), params.MaxSendable)
+	assert.Equal(t, int64(0), params.CommentAllowed)
+}
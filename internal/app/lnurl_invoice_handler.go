@@ -1,39 +1,32 @@
 package app
 
 import (
-	"context"
-	"crypto/sha256"
 	"encoding/json"
-	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"errors"
+	"fmt"
 	"github.com/asheswook/lightning-multitool/pkg/lnurl"
-	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
-	"github.com/nbd-wtf/go-nostr"
 	"log/slog"
 	"net/http"
-	"strconv"
 )
 
 type LNURLInvoiceHandler struct {
-	lndService     *lndrest.Client
-	zapMonitor     ZapMonitor
-	username       string
-	nostrPublicKey string
+	issuer      InvoiceIssuer
+	converter   *CurrencyConverter
+	settings    *LiveSettings
+	maxSendable MaxSendableProvider
+	username    string
 }
 
-func NewLNURLInvoiceHandler(lndService *lndrest.Client, zapMonitor ZapMonitor, username, nostrPublicKey string) LNURLInvoiceHandler {
+func NewLNURLInvoiceHandler(issuer InvoiceIssuer, converter *CurrencyConverter, settings *LiveSettings, maxSendable MaxSendableProvider, username string) LNURLInvoiceHandler {
 	return LNURLInvoiceHandler{
-		lndService:     lndService,
-		zapMonitor:     zapMonitor,
-		username:       username,
-		nostrPublicKey: nostrPublicKey,
+		issuer:      issuer,
+		converter:   converter,
+		settings:    settings,
+		maxSendable: maxSendable,
+		username:    username,
 	}
 }
 
-// isNostrEnabled checks if Nostr functionality is enabled by checking if public key is set
-func (h LNURLInvoiceHandler) isNostrEnabled() bool {
-	return h.nostrPublicKey != ""
-}
-
 func (h LNURLInvoiceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	username := r.PathValue("user")
 	if username != h.username {
@@ -44,8 +37,7 @@ func (h LNURLInvoiceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	amountStr := r.URL.Query().Get("amount")
-	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	amount, currency, err := lnurl.ParseAmount(r.URL.Query().Get("amount"))
 	if err != nil {
 		json.NewEncoder(w).Encode(lnurl.ErrorResponse{
 			Status: "ERROR",
@@ -54,76 +46,86 @@ func (h LNURLInvoiceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := lndrest.CreateInvoiceParams{
-		ValueMsat: amount,
-	}
+	comment := r.URL.Query().Get("comment")
+	memo := comment
 
-	var nostrEvent nostr.Event
-	nostrParam := r.URL.Query().Get("nostr")
-	if nostrParam != "" && h.isNostrEnabled() {
-		if err := json.Unmarshal([]byte(nostrParam), &nostrEvent); err != nil {
+	// LUD-21: the amount may be given in a currency instead of msats.
+	if currency != "" {
+		if h.converter == nil {
+			json.NewEncoder(w).Encode(lnurl.ErrorResponse{
+				Status: "ERROR",
+				Reason: "Currency amounts are not supported",
+			})
+			return
+		}
+
+		var converted string
+		amount, converted, err = h.converter.Convert(r.Context(), amount, currency)
+		if err != nil {
 			json.NewEncoder(w).Encode(lnurl.ErrorResponse{
 				Status: "ERROR",
-				Reason: "Failed to unmarshal nostr nostrEvent: " + err.Error(),
+				Reason: err.Error(),
 			})
 			return
 		}
 
-		if _, err := nostrpkg.ParseZapRequest(nostrEvent, h.nostrPublicKey); err != nil {
+		// The converted amount must be within the limits the payer was
+		// given, the same ones the advertised convertible range is based on.
+		if amount < h.settings.Get().MinSendable || amount > h.maxSendable.MaxSendable(r.Context()) {
 			json.NewEncoder(w).Encode(lnurl.ErrorResponse{
 				Status: "ERROR",
-				Reason: "Invalid zap request: " + err.Error(),
+				Reason: fmt.Sprintf("%s is outside the sendable range", converted),
 			})
 			return
 		}
 
-		// As per NIP-57, the description hash for a zap invoice is the sha256 hash of the zap request nostrEvent.
-		descriptionHash := sha256.Sum256([]byte(nostrParam))
-		params.DescriptionHash = descriptionHash[:]
-		params.Expiry = 300 // 5 minutes
-	} else if nostrParam != "" && !h.isNostrEnabled() {
-		// Return error if Nostr parameter is provided but Nostr is disabled
+		memo = converted
+		if comment != "" {
+			memo += ": " + comment
+		}
+	}
+
+	nostrParam := r.URL.Query().Get("nostr")
+	invoice, err := h.issuer.Issue(r.Context(), InvoiceRequest{
+		Source:     InvoiceSource_HTTP,
+		Username:   username,
+		AmountMsat: amount,
+		Comment:    comment,
+		Memo:       memo,
+		ZapRequest: nostrParam,
+		// LUD-18 payer identity, sent as a JSON object.
+		PayerData: json.RawMessage(r.URL.Query().Get("payerdata")),
+	})
+	if errors.Is(err, ErrTooManyOutstanding) {
+		slog.Warn("Refusing invoice request, too many unpaid invoices")
+		w.Header().Set("Retry-After", "60")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
 		json.NewEncoder(w).Encode(lnurl.ErrorResponse{
 			Status: "ERROR",
-			Reason: "Nostr functionality is disabled",
+			Reason: "Too many unpaid invoices, please try again later",
 		})
 		return
 	}
-
-	commentParam := r.URL.Query().Get("comment")
-	if commentParam != "" {
-		params.Memo = commentParam
-	}
-
-	res, err := h.lndService.CreateInvoice(r.Context(), params)
 	if err != nil {
 		slog.Error("Failed to create invoice", "error", err)
 		json.NewEncoder(w).Encode(lnurl.ErrorResponse{
 			Status: "ERROR",
-			Reason: "Failed to create invoice: " + err.Error(),
+			Reason: err.Error(),
 		})
 		return
 	}
 
-	if nostrParam != "" && h.isNostrEnabled() {
-		go h.zapMonitor.MonitorAndSendZapReceipt(
-			context.Background(),
-			res.RHash,
-			nostrEvent,
-			nostrParam,
-		)
-	}
-
 	// According to LUD-06, the success response must be a JSON object
 	// with a payment request (`pr`) and an empty `routes` array.
 	response := lnurl.PayResponse{
 		Response:   lnurl.Response{Status: "OK"},
-		PR:         res.PaymentRequest,
+		PR:         invoice.PaymentRequest,
 		Routes:     []interface{}{},
 		Disposable: false,
 	}
 
-	slog.Info("Responding with invoice", "amount", amount, "has_zap", nostrParam != "" && h.isNostrEnabled())
+	slog.Info("Responding with invoice", "amount", amount, "currency", currency, "has_zap", nostrParam != "")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
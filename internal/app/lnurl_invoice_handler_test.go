@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/asheswook/lightning-multitool/pkg/lnurl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLNURLInvoiceHandlerCurrencyLimits(t *testing.T) {
+	lnd := newFakeLND(t)
+	created := make(chan int64, 1)
+	lnd.handle("/v1/invoices", func(w http.ResponseWriter, r *http.Request) {
+		var params lndrest.CreateInvoiceParams
+		json.NewDecoder(r.Body).Decode(&params)
+		created <- params.ValueMsat
+		json.NewEncoder(w).Encode(lndrest.CreateInvoiceResponse{RHash: []byte{0x01}, PaymentRequest: "lnbc1test"})
+	})
+
+	issuer := NewInvoiceIssuer(lnd.client, nil, ZapMonitor{}, NewPaymentTracker(nil, nil, nil), "", 0, 0)
+	// 1 BTC = 1,000,000 USD, so a cent is 1,000 msat.
+	converter := NewCurrencyConverter(fiat.StaticPrices{"USD": 1_000_000}, []string{"USD"}, 0, 0)
+	settings := NewLiveSettings(Settings{MinSendable: 10_000, MaxSendable: 1_000_000})
+	handler := NewLNURLInvoiceHandler(issuer, converter, settings, staticMaxSendable(500_000), "satoshi")
+
+	callback := func(amount string) lnurl.ErrorResponse {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/satoshi/callback?amount="+amount, nil)
+		r.SetPathValue("user", "satoshi")
+		w := httptest.NewRecorder()
+		handler.Handle(w, r)
+
+		var res lnurl.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res
+	}
+
+	tests := []struct {
+		amount string
+		ok     bool
+	}{
+		{"9.USD", false},   // 9,000 msat, below min-sendable
+		{"10.USD", true},   // exactly min-sendable
+		{"500.USD", true},  // exactly max-sendable
+		{"501.USD", false}, // above max-sendable
+	}
+	for _, tt := range tests {
+		res := callback(tt.amount)
+		if tt.ok {
+			assert.Equal(t, "OK", res.Status, tt.amount)
+			receive(t, created)
+		} else {
+			assert.Equal(t, "ERROR", res.Status, tt.amount)
+			assert.Contains(t, res.Reason, "outside the sendable range", tt.amount)
+		}
+	}
+	assert.Empty(t, created)
+}
+
+func TestLNURLInvoiceHandlerInvoiceExpiry(t *testing.T) {
+	lnd := newFakeLND(t)
+	expiry := make(chan int64, 1)
+	lnd.handle("/v1/invoices", func(w http.ResponseWriter, r *http.Request) {
+		var params lndrest.CreateInvoiceParams
+		json.NewDecoder(r.Body).Decode(&params)
+		expiry <- params.Expiry
+		json.NewEncoder(w).Encode(lndrest.CreateInvoiceResponse{RHash: []byte{0x01}, PaymentRequest: "lnbc1test"})
+	})
+
+	issuer := NewInvoiceIssuer(lnd.client, nil, ZapMonitor{}, NewPaymentTracker(nil, nil, nil), "", 0, 10*time.Minute)
+	settings := NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1_000_000})
+	handler := NewLNURLInvoiceHandler(issuer, nil, settings, staticMaxSendable(1_000_000), "satoshi")
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/satoshi/callback?amount=1000", nil)
+	r.SetPathValue("user", "satoshi")
+	w := httptest.NewRecorder()
+	handler.Handle(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(600), receive(t, expiry))
+}
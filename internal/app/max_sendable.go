@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// MaxSendableProvider returns the maxSendable amount, in msats, advertised in pay requests.
+type MaxSendableProvider interface {
+	MaxSendable(ctx context.Context) int64
+}
+
+// LiquidityMaxSendable advertises what the node can currently receive over its
+// channels, clamped between the configured min and max sendable amounts.
+// The inbound liquidity is cached for ttl to keep LND out of the request path.
+type LiquidityMaxSendable struct {
+	lndService *lndrest.Client
+	settings   *LiveSettings
+	ttl        time.Duration
+	// retryInterval is how long a failed lookup isn't retried, so requests
+	// don't wait for LND while it's down.
+	retryInterval time.Duration
+
+	mtx       sync.Mutex
+	cached    int64
+	hasCached bool
+	expiresAt time.Time
+	// refreshing is closed when the lookup in progress finishes. It's nil
+	// when there's none, so concurrent requests share one lookup.
+	refreshing chan struct{}
+}
+
+func NewLiquidityMaxSendable(lnd *lndrest.Client, settings *LiveSettings, ttl time.Duration) *LiquidityMaxSendable {
+	return &LiquidityMaxSendable{
+		lndService:    lnd,
+		settings:      settings,
+		ttl:           ttl,
+		retryInterval: 30 * time.Second,
+	}
+}
+
+func (l *LiquidityMaxSendable) MaxSendable(ctx context.Context) int64 {
+	l.mtx.Lock()
+	if time.Now().Before(l.expiresAt) {
+		cached, hasCached := l.cached, l.hasCached
+		l.mtx.Unlock()
+		return l.clampCached(cached, hasCached)
+	}
+
+	done := l.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		l.refreshing = done
+		go l.refresh(done)
+	}
+	l.mtx.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	l.mtx.Lock()
+	cached, hasCached := l.cached, l.hasCached
+	l.mtx.Unlock()
+	return l.clampCached(cached, hasCached)
+}
+
+// refresh looks up the inbound liquidity and closes done. A failed lookup
+// keeps the previous liquidity.
+func (l *LiquidityMaxSendable) refresh(done chan struct{}) {
+	lookupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	liquidity, err := l.lndService.InboundLiquidity(lookupCtx)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if err != nil {
+		slog.Warn("Failed to get inbound liquidity, using previous maxSendable", "error", err, "retry_in", l.retryInterval)
+		l.expiresAt = time.Now().Add(l.retryInterval)
+	} else {
+		l.cached, l.hasCached = liquidity.TotalMsat, true
+		l.expiresAt = time.Now().Add(l.ttl)
+	}
+	l.refreshing = nil
+	close(done)
+}
+
+// clampCached clamps the cached liquidity, or advertises the configured
+// maxSendable if it was never looked up successfully.
+func (l *LiquidityMaxSendable) clampCached(cached int64, hasCached bool) int64 {
+	if !hasCached {
+		return l.settings.Get().MaxSendable
+	}
+	// The limits may have been reloaded since the liquidity was cached.
+	return l.clamp(cached)
+}
+
+// clamp keeps the advertised amount within the configured limits. LNURL-pay
+// requires maxSendable >= minSendable, so minSendable wins when liquidity is
+// lower than that.
+func (l *LiquidityMaxSendable) clamp(inboundMsat int64) int64 {
+	settings := l.settings.Get()
+	maxSendable := min(inboundMsat, settings.MaxSendable)
+	return max(maxSendable, settings.MinSendable)
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// staticMaxSendable always advertises the same amount.
+type staticMaxSendable int64
+
+func (s staticMaxSendable) MaxSendable(context.Context) int64 {
+	return int64(s)
+}
+
+// liquidityLND serves channels with the given inbound liquidity in sats, or
+// fails while down is set.
+type liquidityLND struct {
+	*fakeLND
+	lookups atomic.Int32
+	inbound atomic.Int64
+	down    atomic.Bool
+	delay   time.Duration
+}
+
+func newLiquidityLND(t *testing.T, inboundSat int64) *liquidityLND {
+	l := &liquidityLND{fakeLND: newFakeLND(t)}
+	l.inbound.Store(inboundSat)
+	l.handle("/v1/channels", func(w http.ResponseWriter, r *http.Request) {
+		l.lookups.Add(1)
+		time.Sleep(l.delay)
+		if l.down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		channels := []lndrest.Channel{{Active: true, RemoteBalance: l.inbound.Load()}}
+		json.NewEncoder(w).Encode(map[string]interface{}{"channels": channels})
+	})
+	return l
+}
+
+func TestLiquidityMaxSendableClamp(t *testing.T) {
+	settings := NewLiveSettings(Settings{MinSendable: 10_000, MaxSendable: 1_000_000})
+
+	tests := []struct {
+		inboundSat int64
+		want       int64
+	}{
+		{500, 500_000},     // within the limits
+		{5_000, 1_000_000}, // capped at max-sendable
+		{1, 10_000},        // never below min-sendable
+		{0, 10_000},
+	}
+
+	for _, tt := range tests {
+		lnd := newLiquidityLND(t, tt.inboundSat)
+		maxSendable := NewLiquidityMaxSendable(lnd.client, settings, time.Minute)
+		assert.Equal(t, tt.want, maxSendable.MaxSendable(context.Background()), "inbound %d sat", tt.inboundSat)
+	}
+}
+
+func TestLiquidityMaxSendableCache(t *testing.T) {
+	lnd := newLiquidityLND(t, 500)
+	settings := NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1_000_000})
+	maxSendable := NewLiquidityMaxSendable(lnd.client, settings, time.Minute)
+
+	assert.Equal(t, int64(500_000), maxSendable.MaxSendable(context.Background()))
+	lnd.inbound.Store(700)
+	assert.Equal(t, int64(500_000), maxSendable.MaxSendable(context.Background()))
+	assert.Equal(t, int32(1), lnd.lookups.Load())
+
+	// A reload applies to the cached liquidity right away.
+	settings.Set(Settings{MinSendable: 1000, MaxSendable: 200_000})
+	assert.Equal(t, int64(200_000), maxSendable.MaxSendable(context.Background()))
+
+	maxSendable.ttl = 0
+	maxSendable.expiresAt = time.Time{}
+	settings.Set(Settings{MinSendable: 1000, MaxSendable: 1_000_000})
+	assert.Equal(t, int64(700_000), maxSendable.MaxSendable(context.Background()))
+	assert.Equal(t, int32(2), lnd.lookups.Load())
+}
+
+func TestLiquidityMaxSendableSharesLookups(t *testing.T) {
+	lnd := newLiquidityLND(t, 500)
+	lnd.delay = 100 * time.Millisecond
+	maxSendable := NewLiquidityMaxSendable(lnd.client, NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1_000_000}), time.Minute)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, int64(500_000), maxSendable.MaxSendable(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), lnd.lookups.Load())
+}
+
+func TestLiquidityMaxSendableLNDDown(t *testing.T) {
+	lnd := newLiquidityLND(t, 500)
+	settings := NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1_000_000})
+	maxSendable := NewLiquidityMaxSendable(lnd.client, settings, time.Minute)
+
+	// Without a previous lookup, the configured maxSendable is advertised.
+	lnd.down.Store(true)
+	assert.Equal(t, int64(1_000_000), maxSendable.MaxSendable(context.Background()))
+
+	// Requests while LND is down don't wait for it again until the retry interval passed.
+	assert.Equal(t, int64(1_000_000), maxSendable.MaxSendable(context.Background()))
+	assert.Equal(t, int32(1), lnd.lookups.Load())
+
+	// After a successful lookup, a failure keeps the previous liquidity.
+	lnd.down.Store(false)
+	maxSendable.expiresAt = time.Time{}
+	assert.Equal(t, int64(500_000), maxSendable.MaxSendable(context.Background()))
+
+	lnd.down.Store(true)
+	maxSendable.expiresAt = time.Time{}
+	assert.Equal(t, int64(500_000), maxSendable.MaxSendable(context.Background()))
+	assert.Equal(t, int32(3), lnd.lookups.Load())
+	assert.WithinDuration(t, time.Now().Add(maxSendable.retryInterval), maxSendable.expiresAt, time.Second)
+}
+
+func TestLiquidityMaxSendableRequestCanceled(t *testing.T) {
+	lnd := newLiquidityLND(t, 500)
+	lnd.delay = time.Second
+	maxSendable := NewLiquidityMaxSendable(lnd.client, NewLiveSettings(Settings{MinSendable: 1000, MaxSendable: 1_000_000}), time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Equal(t, int64(1_000_000), maxSendable.MaxSendable(ctx))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -2,14 +2,9 @@ package app
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"github.com/asheswook/lightning-multitool/pkg/lndrest"
-	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
 	"github.com/asheswook/lightning-multitool/pkg/oksusu" // The package we defined earlier
-
-	"github.com/nbd-wtf/go-nostr"
 )
 
 type OksusuHandler struct {
@@ -17,25 +12,21 @@ type OksusuHandler struct {
 	host           string
 	nostrPublicKey string
 
-	maxSendable    int64
-	minSendable    int64
-	commentAllowed int64
+	maxSendable MaxSendableProvider
+	settings    *LiveSettings
 
-	lndService *lndrest.Client
-	zapMonitor ZapMonitor
+	issuer InvoiceIssuer
 }
 
 // NewOksusuHandler creates a new OksusuHandler.
-func NewOksusuHandler(username, host, nostrPublicKey string, maxSendable, minSendable, commentAllowed int64, lndService *lndrest.Client, zapMonitor ZapMonitor) OksusuHandler {
+func NewOksusuHandler(username, host, nostrPublicKey string, maxSendable MaxSendableProvider, settings *LiveSettings, issuer InvoiceIssuer) OksusuHandler {
 	return OksusuHandler{
 		username:       username,
 		host:           host,
 		nostrPublicKey: nostrPublicKey,
 		maxSendable:    maxSendable,
-		minSendable:    minSendable,
-		commentAllowed: commentAllowed,
-		lndService:     lndService,
-		zapMonitor:     zapMonitor,
+		settings:       settings,
+		issuer:         issuer,
 	}
 }
 
@@ -46,6 +37,7 @@ func (h OksusuHandler) isNostrEnabled() bool {
 
 // OnLNURLPRequest handles the LNURL pay-request forwarded from the Oksusu server.
 func (h OksusuHandler) OnLNURLPRequest(ctx context.Context, _ *oksusu.LNURLRequestPayload) (*oksusu.LNURLResponsePayload, error) {
+	settings := h.settings.Get()
 	identifier := fmt.Sprintf("%s@%s", h.username, h.host)
 	description := "Pay to " + identifier
 
@@ -69,10 +61,10 @@ func (h OksusuHandler) OnLNURLPRequest(ctx context.Context, _ *oksusu.LNURLReque
 
 	return &oksusu.LNURLResponsePayload{
 		Callback:        callbackURL,
-		MaxSendable:     h.maxSendable,
-		MinSendable:     h.minSendable,
+		MaxSendable:     h.maxSendable.MaxSendable(ctx),
+		MinSendable:     settings.MinSendable,
 		EncodedMetadata: string(encodedMetadata),
-		CommentAllowed:  h.commentAllowed,
+		CommentAllowed:  settings.CommentAllowed,
 		Tag:             "payRequest",
 		AllowsNostr:     allowsNostr,
 		NostrPubkey:     h.nostrPublicKey,
@@ -81,49 +73,20 @@ func (h OksusuHandler) OnLNURLPRequest(ctx context.Context, _ *oksusu.LNURLReque
 
 // OnInvoiceRequest handles the invoice creation request forwarded from the Oksu server.
 func (h OksusuHandler) OnInvoiceRequest(ctx context.Context, payload *oksusu.InvoiceRequestPayload) (*oksusu.InvoiceResponsePayload, error) {
-	params := lndrest.CreateInvoiceParams{
-		ValueMsat: payload.AmountMsat,
-	}
-
-	// Handle Nostr Zap request if present.
-	var nostrEvent nostr.Event
-	if payload.NostrZap != "" && h.isNostrEnabled() {
-		if err := json.Unmarshal([]byte(payload.NostrZap), &nostrEvent); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal nostr event: %w", err)
-		}
-
-		if _, err := nostrpkg.ParseZapRequest(nostrEvent, h.nostrPublicKey); err != nil {
-			return nil, fmt.Errorf("invalid zap request: %w", err)
-		}
-
-		descriptionHash := sha256.Sum256([]byte(payload.NostrZap))
-		params.DescriptionHash = descriptionHash[:]
-		params.Expiry = 300 // 5 minutes for zap invoices
-	} else if payload.NostrZap != "" && !h.isNostrEnabled() {
-		return nil, fmt.Errorf("Nostr functionality is disabled")
-	}
-
-	if payload.Comment != "" {
-		params.Memo = payload.Comment
-	}
-
-	res, err := h.lndService.CreateInvoice(ctx, params)
+	invoice, err := h.issuer.Issue(ctx, InvoiceRequest{
+		Source:     InvoiceSource_OKSUSU,
+		Username:   h.username,
+		AmountMsat: payload.AmountMsat,
+		Comment:    payload.Comment,
+		ZapRequest: payload.NostrZap,
+		PayerData:  payload.PayerData,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create invoice: %w", err)
-	}
-
-	// If it was a zap, start monitoring for payment to send a receipt.
-	if payload.NostrZap != "" && h.isNostrEnabled() {
-		go h.zapMonitor.MonitorAndSendZapReceipt(
-			context.Background(), // Run in background
-			res.RHash,
-			nostrEvent,
-			payload.NostrZap,
-		)
+		return nil, err
 	}
 
 	return &oksusu.InvoiceResponsePayload{
-		PR:     res.PaymentRequest,
+		PR:     invoice.PaymentRequest,
 		Routes: []interface{}{}, // Must be empty per LNURL spec
 	}, nil
 }
@@ -0,0 +1,29 @@
+package app
+
+import "sync/atomic"
+
+// OnionAddress holds the address of the onion service once it is published,
+// which happens after the handlers are created.
+type OnionAddress struct {
+	address atomic.Pointer[string]
+}
+
+func NewOnionAddress() *OnionAddress {
+	return &OnionAddress{}
+}
+
+// Get returns the onion address, or "" while none is published.
+func (o *OnionAddress) Get() string {
+	if o == nil {
+		return ""
+	}
+	if address := o.address.Load(); address != nil {
+		return *address
+	}
+	return ""
+}
+
+// Set sets the published onion address, e.g. "abcdef.onion".
+func (o *OnionAddress) Set(address string) {
+	o.address.Store(&address)
+}
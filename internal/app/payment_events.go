@@ -0,0 +1,78 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// settledRetention is how long PaymentEvents remembers settled payments, so
+// a subscriber that comes in just after a payment still hears about it.
+const settledRetention = time.Hour
+
+// PaymentEvents lets callers wait for a single invoice to be paid, e.g. to
+// confirm a payment live on the pay page. Register it with the
+// PaymentTracker as a listener.
+type PaymentEvents struct {
+	mtx         sync.Mutex
+	subscribers map[string][]chan SettledPayment
+	settled     map[string]SettledPayment
+}
+
+func NewPaymentEvents() *PaymentEvents {
+	return &PaymentEvents{
+		subscribers: make(map[string][]chan SettledPayment),
+		settled:     make(map[string]SettledPayment),
+	}
+}
+
+// Subscribe returns a channel that receives the payment once the invoice
+// with paymentHash settles, right away if it already has. Call cancel when
+// no longer interested.
+func (e *PaymentEvents) Subscribe(paymentHash string) (payments <-chan SettledPayment, cancel func()) {
+	ch := make(chan SettledPayment, 1)
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if payment, ok := e.settled[paymentHash]; ok {
+		ch <- payment
+		return ch, func() {}
+	}
+
+	e.subscribers[paymentHash] = append(e.subscribers[paymentHash], ch)
+	return ch, func() {
+		e.mtx.Lock()
+		defer e.mtx.Unlock()
+
+		subs := e.subscribers[paymentHash]
+		for i, sub := range subs {
+			if sub == ch {
+				subs = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(subs) == 0 {
+			delete(e.subscribers, paymentHash)
+		} else {
+			e.subscribers[paymentHash] = subs
+		}
+	}
+}
+
+func (e *PaymentEvents) OnPaymentSettled(payment SettledPayment) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	for hash, p := range e.settled {
+		if time.Since(p.SettledAt) > settledRetention {
+			delete(e.settled, hash)
+		}
+	}
+	e.settled[payment.PaymentHash] = payment
+
+	// Every channel has room for the one payment it will ever receive.
+	for _, ch := range e.subscribers[payment.PaymentHash] {
+		ch <- payment
+	}
+	delete(e.subscribers, payment.PaymentHash)
+}
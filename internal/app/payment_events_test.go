@@ -0,0 +1,38 @@
+package app
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPaymentEvents(t *testing.T) {
+	events := NewPaymentEvents()
+	payment := SettledPayment{
+		IssuedInvoice:  IssuedInvoice{PaymentHash: "aa"},
+		AmountPaidMsat: 1000,
+		SettledAt:      time.Now(),
+	}
+
+	payments, cancel := events.Subscribe("aa")
+	defer cancel()
+	other, cancelOther := events.Subscribe("bb")
+
+	events.OnPaymentSettled(payment)
+
+	select {
+	case got := <-payments:
+		assert.Equal(t, payment, got)
+	default:
+		t.Fatal("subscriber was not notified")
+	}
+	assert.Empty(t, other, "only subscribers of the paid invoice are notified")
+
+	cancelOther()
+	assert.Empty(t, events.subscribers)
+
+	// A late subscriber hears about the payment right away.
+	late, cancelLate := events.Subscribe("aa")
+	defer cancelLate()
+	assert.Len(t, late, 1)
+}
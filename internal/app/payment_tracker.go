@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// trackingGrace keeps expired invoices around a little longer, since an HTLC
+// accepted just before expiry, or held by a hold invoice, can settle later.
+const trackingGrace = time.Hour
+
+// lookupTimeout bounds each invoice lookup when reconciling after a restart.
+const lookupTimeout = 10 * time.Second
+
+// IssuedInvoice is an invoice lmt created for an LNURL-pay callback.
+type IssuedInvoice struct {
+	PaymentHash    string          `json:"payment_hash"`
+	PaymentRequest string          `json:"payment_request"`
+	Source         InvoiceSource   `json:"source"`
+	Username       string          `json:"username"`
+	AmountMsat     int64           `json:"amount_msat"`
+	Comment        string          `json:"comment,omitempty"`
+	Memo           string          `json:"memo,omitempty"`
+	PayerData      json.RawMessage `json:"payer_data,omitempty"`
+	ZapRequest     string          `json:"zap_request,omitempty"`
+	ZapSender      string          `json:"zap_sender,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+}
+
+// SettledPayment is an issued invoice that has been paid.
+type SettledPayment struct {
+	IssuedInvoice
+	AmountPaidMsat int64     `json:"amount_paid_msat"`
+	SettledAt      time.Time `json:"settled_at"`
+}
+
+// PaymentListener is notified when an issued invoice is paid.
+// OnPaymentSettled is called from the tracker's goroutine and must not block.
+type PaymentListener interface {
+	OnPaymentSettled(payment SettledPayment)
+}
+
+// InvoiceListener is optionally implemented by a PaymentListener that also
+// wants to know about invoices being issued and canceled.
+type InvoiceListener interface {
+	OnInvoiceIssued(invoice IssuedInvoice)
+	OnInvoiceCanceled(invoice IssuedInvoice)
+}
+
+// PaymentTracker follows issued invoices until they are paid or expire and
+// tells its listeners about payments.
+type PaymentTracker struct {
+	lnd            *lndrest.Client
+	invoiceWatcher *InvoiceWatcher
+	indexes        *InvoiceIndexes
+
+	mtx       sync.Mutex
+	issued    map[string]IssuedInvoice
+	listeners []PaymentListener
+}
+
+// NewPaymentTracker creates a tracker that records every update it has
+// handled in indexes, which may be nil. Invoices resumed after a restart are
+// looked up in lnd, if not nil, when Run starts.
+func NewPaymentTracker(lnd *lndrest.Client, invoiceWatcher *InvoiceWatcher, indexes *InvoiceIndexes) *PaymentTracker {
+	return &PaymentTracker{
+		lnd:            lnd,
+		invoiceWatcher: invoiceWatcher,
+		indexes:        indexes,
+		issued:         make(map[string]IssuedInvoice),
+	}
+}
+
+// AddListener registers a listener. It should be called before Run.
+func (t *PaymentTracker) AddListener(listener PaymentListener) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.listeners = append(t.listeners, listener)
+}
+
+// Track starts following a newly issued invoice.
+func (t *PaymentTracker) Track(invoice IssuedInvoice) {
+	t.mtx.Lock()
+	t.issued[invoice.PaymentHash] = invoice
+	listeners := t.listeners
+	t.mtx.Unlock()
+
+	for _, listener := range listeners {
+		if l, ok := listener.(InvoiceListener); ok {
+			l.OnInvoiceIssued(invoice)
+		}
+	}
+}
+
+// Outstanding returns the number of issued invoices that are unpaid and not yet expired.
+func (t *PaymentTracker) Outstanding() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := time.Now()
+	n := 0
+	for _, invoice := range t.issued {
+		if invoice.ExpiresAt.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Resume follows invoices issued before a restart, without announcing them
+// again. Run first looks them up in LND, since they may have been paid or
+// canceled while lmt was down.
+func (t *PaymentTracker) Resume(invoices []IssuedInvoice) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, invoice := range invoices {
+		t.issued[invoice.PaymentHash] = invoice
+	}
+}
+
+// Run follows invoice updates until ctx is canceled.
+func (t *PaymentTracker) Run(ctx context.Context) {
+	updates, stop := t.invoiceWatcher.WatchAll()
+	defer stop()
+
+	// Updates arriving meanwhile are queued by the watcher and handled after.
+	t.reconcile(ctx)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.prune(now)
+		case invoice := <-updates:
+			t.handle(invoice)
+			// Only once the listeners have the update is it safe to skip
+			// it after a restart.
+			if t.indexes != nil {
+				t.indexes.Advance(invoice)
+			}
+		}
+	}
+}
+
+// reconcile looks up every tracked invoice in LND and handles those that were
+// settled or canceled without lmt seeing the update. It gives up at the first
+// failed lookup; the remaining invoices are still tracked and reconciled at
+// the next start.
+func (t *PaymentTracker) reconcile(ctx context.Context) {
+	if t.lnd == nil {
+		return
+	}
+
+	t.mtx.Lock()
+	hashes := make([]string, 0, len(t.issued))
+	for key := range t.issued {
+		hashes = append(hashes, key)
+	}
+	t.mtx.Unlock()
+
+	for _, key := range hashes {
+		paymentHash, err := hex.DecodeString(key)
+		if err != nil {
+			continue
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		invoice, err := t.lnd.LookupInvoice(lookupCtx, paymentHash)
+		cancel()
+		if errors.Is(err, lndrest.ErrInvoiceNotFound) {
+			// LND deletes canceled invoices if configured to.
+			invoice = lndrest.Invoice{RHash: paymentHash, State: lndrest.InvoiceState_CANCELED}
+		} else if err != nil {
+			slog.Warn("Failed to reconcile open invoices with LND", "payment_hash", key, "error", err)
+			return
+		}
+		t.handle(invoice)
+	}
+}
+
+func (t *PaymentTracker) handle(invoice lndrest.Invoice) {
+	if invoice.State != lndrest.InvoiceState_SETTLED && invoice.State != lndrest.InvoiceState_CANCELED {
+		return
+	}
+
+	key := hex.EncodeToString(invoice.RHash)
+
+	t.mtx.Lock()
+	issued, ok := t.issued[key]
+	delete(t.issued, key)
+	listeners := t.listeners
+	t.mtx.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if invoice.State == lndrest.InvoiceState_CANCELED {
+		for _, listener := range listeners {
+			if l, ok := listener.(InvoiceListener); ok {
+				l.OnInvoiceCanceled(issued)
+			}
+		}
+		return
+	}
+
+	payment := SettledPayment{
+		IssuedInvoice:  issued,
+		AmountPaidMsat: invoice.AmtPaidMsat,
+		SettledAt:      time.Now(),
+	}
+	if invoice.SettleDate > 0 {
+		payment.SettledAt = time.Unix(invoice.SettleDate, 0)
+	}
+
+	metrics.InvoicesSettled.WithLabelValues(string(issued.Source)).Inc()
+	metrics.ReceivedMsat.WithLabelValues(string(issued.Source)).Add(float64(payment.AmountPaidMsat))
+
+	slog.Info("Payment received", "payment_hash", key, "amount_msat", payment.AmountPaidMsat, "source", issued.Source)
+	for _, listener := range listeners {
+		listener.OnPaymentSettled(payment)
+	}
+}
+
+func (t *PaymentTracker) prune(now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for key, invoice := range t.issued {
+		if now.After(invoice.ExpiresAt.Add(trackingGrace)) {
+			delete(t.issued, key)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Settings are the settings that can be changed by reloading the config
+// while lmt runs.
+type Settings struct {
+	MinSendable    int64 // msat
+	MaxSendable    int64 // msat
+	CommentAllowed int64
+	Relays         []string
+}
+
+// LiveSettings holds the current Settings. Everything that uses them reads
+// them through it on every request, so a reload is one atomic swap.
+type LiveSettings struct {
+	current atomic.Pointer[Settings]
+}
+
+func NewLiveSettings(settings Settings) *LiveSettings {
+	l := &LiveSettings{}
+	l.Set(settings)
+	return l
+}
+
+// Get returns the current settings. They must not be modified.
+func (l *LiveSettings) Get() Settings {
+	return *l.current.Load()
+}
+
+// Set replaces the current settings.
+func (l *LiveSettings) Set(settings Settings) {
+	l.current.Store(&settings)
+}
+
+// Relays returns the current Nostr relays.
+func (l *LiveSettings) Relays() []string {
+	return l.Get().Relays
+}
+
+// MaxSendable makes LiveSettings the MaxSendableProvider that advertises the
+// configured amount.
+func (l *LiveSettings) MaxSendable(context.Context) int64 {
+	return l.Get().MaxSendable
+}
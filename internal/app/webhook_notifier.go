@@ -0,0 +1,31 @@
+package app
+
+import "github.com/asheswook/lightning-multitool/internal/webhook"
+
+// WebhookNotifier publishes settled payments to webhook endpoints.
+type WebhookNotifier struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookNotifier(dispatcher *webhook.Dispatcher) WebhookNotifier {
+	return WebhookNotifier{dispatcher: dispatcher}
+}
+
+func (n WebhookNotifier) OnPaymentSettled(payment SettledPayment) {
+	data := webhook.PaymentData{
+		PaymentHash: payment.PaymentHash,
+		AmountMsat:  payment.AmountPaidMsat,
+		AmountSat:   payment.AmountPaidMsat / 1000,
+		Comment:     payment.Comment,
+		PayerData:   payment.PayerData,
+		ZapSender:   payment.ZapSender,
+		Username:    payment.Username,
+		Source:      string(payment.Source),
+		SettledAt:   payment.SettledAt.Unix(),
+	}
+
+	n.dispatcher.Publish(webhook.EventPaymentReceived, data)
+	if payment.ZapRequest != "" {
+		n.dispatcher.Publish(webhook.EventZapReceived, data)
+	}
+}
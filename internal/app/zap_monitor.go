@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"encoding/hex"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
 	"github.com/asheswook/lightning-multitool/internal/nostrutil"
 	"github.com/asheswook/lightning-multitool/pkg/lndrest"
 	nostrspec "github.com/asheswook/lightning-multitool/pkg/nostr"
@@ -12,49 +13,69 @@ import (
 	"github.com/nbd-wtf/go-nostr"
 )
 
+const (
+	// zapReceiptRetryDelay is how often a zap receipt is retried while the
+	// remote signer is not connected.
+	zapReceiptRetryDelay = 30 * time.Second
+	// zapReceiptMaxDelay is how long a zap receipt is held back for the
+	// remote signer before it is dropped.
+	zapReceiptMaxDelay = 24 * time.Hour
+)
+
 type ZapMonitor struct {
-	lndService      *lndrest.Client
-	nostrPrivateKey string
-	nostrPublicKey  string
-	relays          []string
+	invoiceWatcher *InvoiceWatcher
+	signer         nostrspec.Signer
+	settings       *LiveSettings
+	retryDelay     time.Duration // while the remote signer is not connected
+
+	// publish sends the receipt to the relays; tests replace it.
+	publish func(ctx context.Context, event nostr.Event, relays []string)
 }
 
-func NewZapMonitor(lnd *lndrest.Client, pubkey, privKey string, relays []string) ZapMonitor {
+// NewZapMonitor creates a ZapMonitor that signs zap receipts with signer.
+// Without a signer, Nostr is disabled and no receipts are sent. While a
+// remote signer is not connected, receipts are held back until it is.
+func NewZapMonitor(invoiceWatcher *InvoiceWatcher, signer nostrspec.Signer, settings *LiveSettings) ZapMonitor {
 	return ZapMonitor{
-		lndService:      lnd,
-		nostrPublicKey:  pubkey,
-		nostrPrivateKey: privKey,
-		relays:          relays,
+		invoiceWatcher: invoiceWatcher,
+		signer:         signer,
+		settings:       settings,
+		retryDelay:     zapReceiptRetryDelay,
+		publish:        nostrutil.PublishEvent,
 	}
 }
 
-// isNostrEnabled checks if Nostr functionality is enabled by checking if public key is set
+// isNostrEnabled checks if Nostr functionality is enabled by checking if there is a signer
 func (zm ZapMonitor) isNostrEnabled() bool {
-	return zm.nostrPublicKey != ""
+	return zm.signer != nil
 }
 
+// MonitorAndSendZapReceipt waits for the zap invoice to be paid and
+// publishes its receipt. It gives up at deadline, when the invoice can no
+// longer be settled.
 func (zm ZapMonitor) MonitorAndSendZapReceipt(
 	ctx context.Context,
 	paymentHash []byte,
 	originalZapRequest nostr.Event,
 	zapRequestRaw string,
+	deadline time.Time,
 ) {
 	// Early return if Nostr is disabled
 	if !zm.isNostrEnabled() {
 		return
 	}
 
+	metrics.ZapMonitorsActive.Inc()
+	defer metrics.ZapMonitorsActive.Dec()
+
 	paymentHashHex := hex.EncodeToString(paymentHash)
 	logger := slog.With("payment_hash", paymentHashHex, "zap_request_id", originalZapRequest.ID)
 
-	monitoringCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	monitoringCtx, cancel := context.WithDeadline(ctx, deadline)
 	defer cancel()
 
-	invoiceChan, err := zm.lndService.SubscribeInvoices(monitoringCtx, lndrest.SubscribeInvoicesParams{})
-	if err != nil {
-		logger.Error("Failed to subscribe to invoices", "error", err)
-		return
-	}
+	invoiceChan, stop := zm.invoiceWatcher.Watch(paymentHash)
+	defer stop()
 	logger.Info("Started monitoring invoice payment for ZAP")
 
 	for {
@@ -62,20 +83,15 @@ func (zm ZapMonitor) MonitorAndSendZapReceipt(
 		case <-monitoringCtx.Done():
 			logger.Warn("Stopped monitoring due to timeout or cancellation", "reason", monitoringCtx.Err())
 			return
-		case invoice, ok := <-invoiceChan:
-			if !ok {
-				logger.Warn("Invoice subscription channel closed unexpectedly")
+		case invoice := <-invoiceChan:
+			logger.Info("Received invoice update", "state", invoice.State)
+
+			if invoice.State == lndrest.InvoiceState_CANCELED {
+				logger.Info("Invoice canceled, no zap receipt to send")
 				return
 			}
 
-			logger.Info("Received invoice",
-				"amount_msat", invoice.AmtPaidMsat,
-				"state", invoice.State,
-				"invoice", invoice,
-				"rhash", hex.EncodeToString(invoice.RHash),
-			)
-
-			if invoice.State == lndrest.InvoiceState_SETTLED && hex.EncodeToString(invoice.RHash) == paymentHashHex {
+			if invoice.State == lndrest.InvoiceState_SETTLED {
 				logger.Info("Invoice paid for ZAP", "amount_msat", invoice.AmtPaidMsat)
 				zm.publishZapReceipt(invoice, originalZapRequest, zapRequestRaw)
 				return // 임무 완료, 고루틴 종료
@@ -89,16 +105,32 @@ func (zm ZapMonitor) publishZapReceipt(
 	zapRequest nostr.Event,
 	zapRequestRaw string,
 ) {
+	// The payment is unaffected, only its receipt can't be signed yet.
+	if !zm.signer.Ready() {
+		slog.Warn("Remote signer is not connected, delaying zap receipt", "zap_request_id", zapRequest.ID)
+		giveUp := time.Now().Add(zapReceiptMaxDelay)
+		for !zm.signer.Ready() {
+			if time.Now().After(giveUp) {
+				slog.Error("Remote signer did not connect, dropping zap receipt", "zap_request_id", zapRequest.ID)
+				return
+			}
+			time.Sleep(zm.retryDelay)
+		}
+	}
+
 	preimageHex := hex.EncodeToString(paidInvoice.RPreimage)
 
 	// pkg/nostr/zap.go에 정의된 NewZapReceipt 함수를 사용하여 Receipt를 생성합니다.
-	receipt, err := nostrspec.NewZapReceipt(nostrspec.ZapReceiptParams{
-		ZapRequest:       nostrspec.ZapRequest(zapRequest),
-		ZapRequestRaw:    zapRequestRaw,
-		Bolt11:           paidInvoice.PaymentRequest,
-		Preimage:         preimageHex,
-		RecipientPubkey:  zm.nostrPublicKey,
-		RecipientPrivkey: zm.nostrPrivateKey,
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	receipt, err := nostrspec.NewZapReceipt(ctx, nostrspec.ZapReceiptParams{
+		ZapRequest:      nostrspec.ZapRequest(zapRequest),
+		ZapRequestRaw:   zapRequestRaw,
+		Bolt11:          paidInvoice.PaymentRequest,
+		Preimage:        preimageHex,
+		RecipientPubkey: zm.signer.PublicKey(),
+		Signer:          zm.signer,
 	})
 	if err != nil {
 		slog.Error("Failed to create zap receipt", "error", err, "zap_request_id", zapRequest.ID)
@@ -108,5 +140,5 @@ func (zm ZapMonitor) publishZapReceipt(
 	logger := slog.With("zap_receipt_id", receipt.ID, "zap_request_id", zapRequest.ID)
 	logger.Info("Successfully created zap receipt, attempting to publish")
 
-	nostrutil.PublishEvent(context.Background(), nostr.Event(receipt), zm.relays)
+	zm.publish(ctx, nostr.Event(receipt), zm.settings.Relays())
 }
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestZapMonitorWaitsForSigner(t *testing.T) {
+	local, err := nostrpkg.NewLocalSigner(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	signer := &connectingSigner{LocalSigner: local}
+
+	sent := make(chan nostr.Event, 1)
+	zm := NewZapMonitor(nil, signer, NewLiveSettings(Settings{Relays: defaultRelays}))
+	zm.retryDelay = 10 * time.Millisecond
+	zm.publish = func(_ context.Context, event nostr.Event, relays []string) {
+		assert.Equal(t, defaultRelays, relays)
+		sent <- event
+	}
+
+	zapRequest := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", local.PublicKey()}, {"amount", "21000"}, {"relays", defaultRelays[0]}},
+	}
+	require.NoError(t, zapRequest.Sign(nostr.GeneratePrivateKey()))
+
+	go zm.publishZapReceipt(lndrest.Invoice{PaymentRequest: "lnbc210n1", RPreimage: []byte{1, 2, 3}}, zapRequest, zapRequest.String())
+	time.Sleep(5 * zm.retryDelay)
+	assert.Empty(t, sent, "the receipt is held back until the signer connects")
+
+	signer.connected.Store(true)
+	receipt := receive(t, sent)
+	assert.Equal(t, nostr.KindZap, receipt.Kind)
+	assert.Equal(t, local.PublicKey(), receipt.PubKey)
+	assert.Equal(t, "lnbc210n1", receipt.Tags.GetFirst([]string{"bolt11", ""}).Value())
+}
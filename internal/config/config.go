@@ -1,9 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"github.com/jessevdk/go-flags"
 	"log/slog"
 	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 )
 
 // pathOptions is a helper struct to only parse the config file path
@@ -14,6 +20,12 @@ type pathOptions struct {
 
 // NewConfig 함수는 설정 파일을 읽고, 환경 변수를 적용한 후, 명령행 인자를 파싱하여 최종 설정을 반환합니다.
 func NewConfig() (*Config, error) {
+	return Load(os.Args[1:])
+}
+
+// Load reads the config file, environment variables and the command line
+// arguments args, like NewConfig.
+func Load(args []string) (*Config, error) {
 	// 1단계: 기본값으로 Config 구조체 초기화
 	cfg := &Config{}
 	parser := flags.NewParser(cfg, flags.Default)
@@ -24,7 +36,7 @@ func NewConfig() (*Config, error) {
 		ConfigFile string `short:"c" long:"config" description:"Path to config file" default:"lmt.conf" env:"LMT_CONFIG_FILE"`
 	}
 	pathParser := flags.NewParser(&pathOpts, flags.IgnoreUnknown)
-	_, err := pathParser.ParseArgs(os.Args[1:])
+	_, err := pathParser.ParseArgs(args)
 	if err != nil {
 		// 심각한 오류가 아니면 무시하고 진행
 		slog.Debug("could not parse config path, using defaults", "error", err)
@@ -34,15 +46,16 @@ func NewConfig() (*Config, error) {
 	iniParser := flags.NewIniParser(parser)
 	if err := iniParser.ParseFile(pathOpts.ConfigFile); err != nil {
 		// 설정 파일이 없는 경우는 에러가 아님 (명령행으로만 설정 가능)
-		// 단, 기본 경로가 아닌 사용자가 명시적으로 지정한 파일이 없을 때는 경고를 표시할 수 있음
+		// 파일이 있는데 읽을 수 없으면 기본값으로 조용히 진행하지 않고 에러를 반환
 		if !os.IsNotExist(err) {
-			slog.Warn("failed to parse config file", "path", pathOpts.ConfigFile, "error", err)
+			return nil, fmt.Errorf("failed to parse config file %s: %w", pathOpts.ConfigFile, err)
 		}
 	}
+	fromFile := setOptions(parser)
 
 	// 4단계: 전체 명령행 파싱 (INI 값을 덮어씀)
 	// 이 단계에서 최종적으로 모든 설정이 적용되고 유효성 검사가 이루어짐
-	_, err = parser.ParseArgs(os.Args[1:])
+	_, err = parser.ParseArgs(args)
 	if err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			os.Exit(0)
@@ -51,54 +64,254 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// 5단계: 각 값의 출처를 기록하고 목록 값을 정리
+	cfg.sources = sources(parser, args, pathOpts.ConfigFile, fromFile)
+	splitLists(cfg)
+
 	return cfg, nil
 }
 
+// Source describes where the value of key came from: the config file, an
+// environment variable, a command line flag, or the default.
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+// sources maps every key to where its value came from. Options set while
+// parsing the config file come from the file unless a flag overrides them.
+// go-flags only looks at environment variables for options the file doesn't
+// set, so the file takes precedence over them.
+func sources(parser *flags.Parser, args []string, configFile string, fromFile map[string]bool) map[string]string {
+	// Parse the arguments again on their own to tell flags from file values.
+	flagParser := flags.NewParser(&Config{}, flags.IgnoreUnknown)
+	_, _ = flagParser.ParseArgs(args)
+	fromFlag := setOptions(flagParser)
+
+	sources := make(map[string]string)
+	eachOption(parser.Group, func(o *flags.Option) {
+		key := o.LongNameWithNamespace()
+		env := o.EnvKeyWithNamespace()
+		_, envSet := os.LookupEnv(env)
+		envSet = envSet && env != ""
+
+		switch {
+		case fromFlag[key]:
+			sources[key] = "flag --" + key
+		case fromFile[key] && envSet:
+			sources[key] = "file " + configFile + ", which overrides env " + env
+		case fromFile[key]:
+			sources[key] = "file " + configFile
+		case envSet:
+			sources[key] = "env " + env
+		}
+	})
+	return sources
+}
+
+// setOptions returns the keys of the options parser has set so far, other
+// than to their defaults.
+func setOptions(parser *flags.Parser) map[string]bool {
+	set := make(map[string]bool)
+	eachOption(parser.Group, func(o *flags.Option) {
+		if o.IsSet() && !o.IsSetDefault() {
+			set[o.LongNameWithNamespace()] = true
+		}
+	})
+	return set
+}
+
+func eachOption(group *flags.Group, fn func(*flags.Option)) {
+	for _, o := range group.Options() {
+		fn(o)
+	}
+	for _, g := range group.Groups() {
+		eachOption(g, fn)
+	}
+}
+
+// splitLists splits the entries of list options on their env-delim. go-flags
+// only does that for environment variables, so "a,b" in the config file or a
+// default would otherwise be a single entry. Empty entries, like the one an
+// empty "key=" line gives, are dropped.
+func splitLists(cfg *Config) {
+	groups := reflect.ValueOf(cfg).Elem()
+	for i := range groups.NumField() {
+		group := groups.Field(i)
+		if group.Kind() != reflect.Struct {
+			continue
+		}
+
+		for j := range group.NumField() {
+			field := group.Type().Field(j)
+			delim := field.Tag.Get("env-delim")
+			list, ok := group.Field(j).Interface().([]string)
+			if !ok || delim == "" {
+				continue
+			}
+
+			var split []string
+			for _, entry := range list {
+				for _, s := range strings.Split(entry, delim) {
+					if s = strings.TrimSpace(s); s != "" {
+						split = append(split, s)
+					}
+				}
+			}
+			group.Field(j).Set(reflect.ValueOf(split))
+		}
+	}
+}
+
+// ExpandPath expands a leading ~/ in path to the current user's home directory.
+func ExpandPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	return filepath.Join(usr.HomeDir, path[2:]), nil
+}
+
 type Config struct {
-	ConfigFile string        `short:"c" long:"config" description:"Path to config file" default:"lmt.conf" env:"LMT_CONFIG_FILE"`
-	General    GeneralConfig `group:"General" namespace:"general"`
-	Server     ServerConfig  `group:"Server" namespace:"server"`
-	API        APIConfig     `group:"API" namespace:"api"`
-	LND        LNDConfig     `group:"LND" namespace:"lnd"`
-	Nostr      NostrConfig   `group:"Nostr" namespace:"nostr"`
-	LNURL      LNURLConfig   `group:"LNURL" namespace:"lnurl"`
-	Oksusu     OksusuConfig  `group:"Oksusu" namespace:"oksusu"`
+	ConfigFile string          `short:"c" long:"config" description:"Path to config file" default:"lmt.conf" env:"LMT_CONFIG_FILE"`
+	General    GeneralConfig   `group:"General" namespace:"general"`
+	Server     ServerConfig    `group:"Server" namespace:"server"`
+	API        APIConfig       `group:"API" namespace:"api"`
+	LND        LNDConfig       `group:"LND" namespace:"lnd"`
+	Nostr      NostrConfig     `group:"Nostr" namespace:"nostr"`
+	LNURL      LNURLConfig     `group:"LNURL" namespace:"lnurl"`
+	Oksusu     OksusuConfig    `group:"Oksusu" namespace:"oksusu"`
+	Hold       HoldConfig      `group:"Hold" namespace:"hold"`
+	Webhook    WebhookConfig   `group:"Webhook" namespace:"webhook"`
+	Fiat       FiatConfig      `group:"Fiat" namespace:"fiat"`
+	RateLimit  RateLimitConfig `group:"RateLimit" namespace:"ratelimit"`
+	Tor        TorConfig       `group:"Tor" namespace:"tor"`
+	PayPage    PayPageConfig   `group:"PayPage" namespace:"paypage"`
+
+	sources   map[string]string
+	nostrKeys *NostrKeys
 }
 
 type GeneralConfig struct {
 	Username string `long:"username" env:"USERNAME" description:"Username for the Lightning Address" required:"true"`
+	DataDir  string `long:"datadir" env:"DATA_DIR" description:"Directory for lmt's own data, like the webhook delivery log" default:"~/.lmt"`
 }
 
 type ServerConfig struct {
-	Host string `long:"host" env:"SERVER_HOST" description:"Server host"`
-	Port string `long:"port" env:"SERVER_PORT" description:"Server port"`
+	Enabled bool   `long:"enable" env:"SERVER_ENABLE" description:"Run the standalone web server alongside Oksu Connect"`
+	Host    string `long:"host" env:"SERVER_HOST" description:"Server host"`
+	Port    string `long:"port" env:"SERVER_PORT" description:"Server port"`
+
+	TLSCert          string `long:"tls-cert" env:"SERVER_TLS_CERT" description:"Serve HTTPS with this certificate file"`
+	TLSKey           string `long:"tls-key" env:"SERVER_TLS_KEY" description:"Private key file for tls-cert"`
+	ACME             bool   `long:"acme" env:"SERVER_ACME" description:"Serve HTTPS with certificates obtained automatically for lnurl.domain"`
+	ACMEEmail        string `long:"acme-email" env:"SERVER_ACME_EMAIL" description:"Contact email for the ACME account"`
+	ACMEDirectoryURL string `long:"acme-directory-url" env:"SERVER_ACME_DIRECTORY_URL" description:"ACME directory URL (default: Let's Encrypt)"`
+	ACMECache        string `long:"acme-cache" env:"SERVER_ACME_CACHE" description:"Directory where ACME certificates are cached (default: <datadir>/acme)"`
+	RedirectPort     string `long:"redirect-port" env:"SERVER_REDIRECT_PORT" description:"Redirect plain HTTP on this port to HTTPS; also answers ACME HTTP-01 challenges"`
+}
+
+// TLSEnabled reports whether the standalone server serves HTTPS.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.ACME || c.TLSCert != "" || c.TLSKey != ""
 }
 
 type APIConfig struct {
-	Port string `long:"api_port" env:"API_PORT" description:"API port" default:"5051"`
+	Port  string `long:"api_port" env:"API_PORT" description:"API port" default:"5051"`
+	Token string `long:"token" env:"API_TOKEN" description:"Bearer token required by the admin API"`
 }
 
 type LNURLConfig struct {
-	Domain          string `long:"domain" env:"DOMAIN" description:"Domain for the LNURL" required:"true"`
-	MinSendableMsat int64  `long:"min-sendable" env:"MIN_SENDABLE_MSAT" description:"Minimum sendable amount in msats" default:"1000"`
-	MaxSendableMsat int64  `long:"max-sendable" env:"MAX_SENDABLE_MSAT" description:"Maximum sendable amount in msats" default:"1000000000"`
-	CommentAllowed  int64  `long:"comment-allowed" env:"COMMENT_ALLOWED" description:"Maximum comment length" default:"255"`
+	Domain          string        `long:"domain" env:"DOMAIN" description:"Domain for the LNURL" required:"true"`
+	MinSendableMsat int64         `long:"min-sendable" env:"MIN_SENDABLE_MSAT" description:"Minimum sendable amount in msats" default:"1000"`
+	MaxSendableMsat int64         `long:"max-sendable" env:"MAX_SENDABLE_MSAT" description:"Maximum sendable amount in msats" default:"1000000000"`
+	CommentAllowed  int64         `long:"comment-allowed" env:"COMMENT_ALLOWED" description:"Maximum comment length" default:"255"`
+	InvoiceExpiry   time.Duration `long:"invoice-expiry" env:"LNURL_INVOICE_EXPIRY" description:"How long invoices for LNURL-pay callbacks and the pay page can be paid" default:"10m"`
+
+	DynamicMaxSendable bool          `long:"dynamic-max-sendable" env:"DYNAMIC_MAX_SENDABLE" description:"Limit maxSendable to the node's current inbound liquidity"`
+	LiquidityCacheTTL  time.Duration `long:"liquidity-cache-ttl" env:"LIQUIDITY_CACHE_TTL" description:"How long to cache the inbound liquidity" default:"30s"`
+
+	Currencies     []string      `long:"currencies" env:"LNURL_CURRENCIES" env-delim:"," description:"Comma-separated fiat currencies payers can pay in (LUD-21). Rates come from fiat.price-file"`
+	CurrencySpread float64       `long:"currency-spread" env:"LNURL_CURRENCY_SPREAD" description:"Percentage added to the exchange rate for currency amounts" default:"0"`
+	MaxPriceAge    time.Duration `long:"max-price-age" env:"LNURL_MAX_PRICE_AGE" description:"Stop offering a currency when its latest rate in fiat.price-file is older than this. 0 allows any age" default:"48h"`
 }
 
 type LNDConfig struct {
-	Host         string `long:"host" env:"LND_HOST" description:"LND REST host" default:"localhost:8080"`
-	MacaroonPath string `long:"macaroonpath" env:"LND_MACAROON_PATH" description:"Path to LND admin.macaroon" default:"~/.lnd/data/chain/bitcoin/mainnet/admin.macaroon"`
+	Host               string `long:"host" env:"LND_HOST" description:"LND REST host" default:"localhost:8080"`
+	MacaroonPath       string `long:"macaroonpath" env:"LND_MACAROON_PATH" description:"Path to LND admin.macaroon" default:"~/.lnd/data/chain/bitcoin/mainnet/admin.macaroon"`
+	Macaroon           string `long:"macaroon" env:"LND_MACAROON" description:"LND macaroon, hex or base64 encoded. Used instead of macaroonpath when set"`
+	TLSCertPath        string `long:"tlscertpath" env:"LND_TLS_CERT_PATH" description:"Path to LND tls.cert"`
+	TLSCertFingerprint string `long:"tlscert-fingerprint" env:"LND_TLS_CERT_FINGERPRINT" description:"SHA-256 fingerprint of the LND TLS certificate to pin"`
+	InsecureSkipVerify bool   `long:"insecure-skip-verify" env:"LND_INSECURE_SKIP_VERIFY" description:"Connect to LND without verifying its TLS certificate when neither tlscertpath nor tlscert-fingerprint is set"`
+	LNDConnect         string `long:"lndconnect" env:"LND_CONNECT" description:"lndconnect:// URI. Used instead of the other LND settings when set"`
+	SocksProxy         string `long:"socks-proxy" env:"LND_SOCKS_PROXY" description:"Connect to LND through this SOCKS5 proxy, e.g. tor at 127.0.0.1:9050"`
 }
 
 type NostrConfig struct {
 	Enabled    bool     `long:"enable" env:"NOSTR_ENABLE"`
-	PrivateKey string   `long:"privatekey" env:"NOSTR_PRIVATE_KEY" description:"Nostr private key (nsec format)"`
-	PublicKey  string   `long:"publickey" env:"NOSTR_PUBLIC_KEY" description:"Nostr public key (npub format)"`
+	PrivateKey string   `long:"privatekey" env:"NOSTR_PRIVATE_KEY" description:"Nostr private key: nsec, hex, or ncryptsec (NIP-49) with nostr.passphrase"`
+	PublicKey  string   `long:"publickey" env:"NOSTR_PUBLIC_KEY" description:"Nostr public key (npub or hex). Derived from the private key when empty"`
 	Relays     []string `long:"relays" env:"NOSTR_RELAYS" env-delim:"," description:"Comma-separated list of Nostr relays" default:"wss://relay.damus.io,wss://relay.primal.net"`
+
+	PrivateKeyFile string `long:"privatekey-file" env:"NOSTR_PRIVATE_KEY_FILE" description:"Read the private key from this file instead, e.g. a Docker secret"`
+	Passphrase     string `long:"passphrase" env:"NOSTR_KEY_PASSPHRASE" description:"Passphrase to decrypt an ncryptsec private key"`
+	PassphraseFile string `long:"passphrase-file" env:"NOSTR_KEY_PASSPHRASE_FILE" description:"Read the ncryptsec passphrase from this file"`
+	Bunker         string `long:"bunker" env:"NOSTR_BUNKER_URI" description:"bunker:// URI of a NIP-46 remote signer to sign with instead of a private key"`
+
+	Notify            []string      `long:"notify" env:"NOSTR_NOTIFY" env-delim:"," description:"npub to send a DM about incoming payments. May be given more than once"`
+	NotifyProtocol    string        `long:"notify-protocol" env:"NOSTR_NOTIFY_PROTOCOL" description:"DM protocol: nip17, nip04, or auto to use NIP-17 when the recipient publishes DM relays" choice:"auto" choice:"nip17" choice:"nip04" default:"auto"`
+	NotifyMinAmount   int64         `long:"notify-min-amount" env:"NOSTR_NOTIFY_MIN_AMOUNT_MSAT" description:"Only send DMs about payments of at least this many msats" default:"0"`
+	NotifyBatchWindow time.Duration `long:"notify-batch-window" env:"NOSTR_NOTIFY_BATCH_WINDOW" description:"Collect payments for this long and send them as one DM" default:"30s"`
 }
 
 type OksusuConfig struct {
 	Enabled bool   `long:"enable" env:"OKSUSU_ENABLE" description:"Enable Oksusu integration"`
 	Server  string `long:"server" env:"OKSUSU_SERVER" description:"Oksusu server" default:"oksu.su"`
 	Token   string `long:"token" env:"OKSUSU_TOKEN" description:"Your Oksu Connect authentication token"`
+
+	MaxConcurrent int `long:"max-concurrent" env:"OKSUSU_MAX_CONCURRENT" description:"Maximum number of Oksu requests handled at once" default:"16"`
+}
+
+type HoldConfig struct {
+	ApprovalURL string        `long:"approval-url" env:"HOLD_APPROVAL_URL" description:"Issue hold invoices and settle them only after this URL approves the payment"`
+	Timeout     time.Duration `long:"timeout" env:"HOLD_TIMEOUT" description:"How long to wait for approval before canceling an accepted payment" default:"10m"`
+}
+
+type WebhookConfig struct {
+	Endpoints   []string `long:"endpoint" env:"WEBHOOK_ENDPOINTS" env-delim:" " description:"Webhook endpoint as url;secret=...;events=a|b. May be given more than once"`
+	MaxAttempts int      `long:"max-attempts" env:"WEBHOOK_MAX_ATTEMPTS" description:"Give up on a webhook delivery after this many attempts" default:"10"`
+}
+
+type FiatConfig struct {
+	PriceFile string `long:"price-file" env:"FIAT_PRICE_FILE" description:"CSV file of BTC prices (date,currency,price) used to value payments in fiat"`
+	Currency  string `long:"currency" env:"FIAT_CURRENCY" description:"Fiat currency payments are valued in" default:"USD"`
+}
+
+type RateLimitConfig struct {
+	IPRate         float64  `long:"ip-rate" env:"RATELIMIT_IP_RATE" description:"LNURL callbacks per minute allowed from one IP. 0 disables the limit" default:"30"`
+	IPBurst        int      `long:"ip-burst" env:"RATELIMIT_IP_BURST" description:"LNURL callbacks one IP may make at once" default:"10"`
+	UserRate       float64  `long:"user-rate" env:"RATELIMIT_USER_RATE" description:"LNURL callbacks per minute allowed for one user. 0 disables the limit" default:"120"`
+	UserBurst      int      `long:"user-burst" env:"RATELIMIT_USER_BURST" description:"LNURL callbacks one user may receive at once" default:"30"`
+	TrustedProxies []string `long:"trusted-proxy" env:"RATELIMIT_TRUSTED_PROXIES" env-delim:"," description:"IP or CIDR of a reverse proxy whose X-Forwarded-For is honored. May be given more than once"`
+	MaxOutstanding int      `long:"max-outstanding" env:"RATELIMIT_MAX_OUTSTANDING" description:"Stop issuing invoices while this many are unpaid. 0 disables the limit" default:"1000"`
+}
+
+type TorConfig struct {
+	Enabled  bool   `long:"enable" env:"TOR_ENABLE" description:"Publish the LNURL and NIP-05 endpoints as a Tor onion service"`
+	Control  string `long:"control" env:"TOR_CONTROL" description:"Address of the tor control port" default:"127.0.0.1:9051"`
+	Password string `long:"password" env:"TOR_CONTROL_PASSWORD" description:"Tor control port password. Cookie authentication is used when empty"`
+	KeyFile  string `long:"keyfile" env:"TOR_KEY_FILE" description:"Where the onion service key is kept (default: <datadir>/onion_key)"`
+}
+
+type PayPageConfig struct {
+	Enabled     bool   `long:"enable" env:"PAYPAGE_ENABLE" description:"Serve a payment page at /pay/<username>"`
+	Description string `long:"description" env:"PAYPAGE_DESCRIPTION" description:"Text shown under your Lightning Address on the payment page"`
+	Avatar      string `long:"avatar" env:"PAYPAGE_AVATAR" description:"Image file shown on the payment page"`
+	Amounts     string `long:"amounts" env:"PAYPAGE_AMOUNTS" description:"Comma separated preset amounts in sats" default:"1000,5000,21000"`
 }
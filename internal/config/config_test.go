@@ -0,0 +1,49 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lmt.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`
+general.username=satoshi
+lnurl.domain=example.com
+lnurl.currencies=
+nostr.relays=wss://relay.damus.io, wss://nostr.mom
+nostr.privatekey=
+`), 0o600))
+
+	t.Setenv("NOSTR_PRIVATE_KEY", "nsec1...")
+	t.Setenv("MIN_SENDABLE_MSAT", "2000")
+	cfg, err := Load([]string{"-c", path, "--lnurl.max-sendable=5000"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"wss://relay.damus.io", "wss://nostr.mom"}, cfg.Nostr.Relays, "lists are split")
+	assert.Empty(t, cfg.LNURL.Currencies, "an empty line is an empty list")
+	assert.Equal(t, []string{"wss://relay.damus.io", "wss://relay.primal.net"}, defaultRelays(t), "defaults are split too")
+
+	assert.Equal(t, "file "+path, cfg.Source("lnurl.domain"))
+	assert.Equal(t, "env MIN_SENDABLE_MSAT", cfg.Source("lnurl.min-sendable"))
+	assert.Equal(t, "flag --lnurl.max-sendable", cfg.Source("lnurl.max-sendable"))
+	assert.Equal(t, "default", cfg.Source("lnurl.comment-allowed"))
+	assert.Equal(t, "file "+path+", which overrides env NOSTR_PRIVATE_KEY", cfg.Source("nostr.privatekey"))
+}
+
+func defaultRelays(t *testing.T) []string {
+	cfg, err := Load([]string{"-c", filepath.Join(t.TempDir(), "missing.conf"), "--general.username=satoshi", "--lnurl.domain=example.com"})
+	require.NoError(t, err)
+	return cfg.Nostr.Relays
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lmt.conf")
+	require.NoError(t, os.WriteFile(path, []byte("lnurl.unknown=1\n"), 0o600))
+
+	_, err := Load([]string{"-c", path})
+	assert.ErrorContains(t, err, path)
+}
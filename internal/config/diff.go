@@ -0,0 +1,28 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Diff returns the keys, as written in the config file (namespace.long),
+// whose values differ between a and b.
+func Diff(a, b *Config) []string {
+	var changed []string
+
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := range va.NumField() {
+		group := va.Type().Field(i)
+		namespace := group.Tag.Get("namespace")
+		if namespace == "" {
+			continue
+		}
+
+		ga, gb := va.Field(i), vb.Field(i)
+		for j := range ga.NumField() {
+			if !reflect.DeepEqual(ga.Field(j).Interface(), gb.Field(j).Interface()) {
+				changed = append(changed, namespace+"."+ga.Type().Field(j).Tag.Get("long"))
+			}
+		}
+	}
+	return changed
+}
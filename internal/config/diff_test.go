@@ -0,0 +1,20 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := &Config{}
+	a.LNURL.MaxSendableMsat = 1000
+	a.Nostr.Relays = []string{"wss://relay.damus.io"}
+
+	b := *a
+	assert.Empty(t, Diff(a, &b))
+
+	b.LNURL.MaxSendableMsat = 2000
+	b.Nostr.Relays = []string{"wss://relay.damus.io", "wss://relay.primal.net"}
+	b.Server.Port = "8080"
+	assert.Equal(t, []string{"server.port", "nostr.relays", "lnurl.max-sendable"}, Diff(a, &b))
+}
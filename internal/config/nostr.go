@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"os"
+	"strings"
+)
+
+// NostrKeys are the hex keys lmt signs Nostr events with.
+type NostrKeys struct {
+	PrivateKey string
+	PublicKey  string
+}
+
+// NostrKeys loads the private key from nostr.privatekey or
+// nostr.privatekey-file, decrypts it when it's an ncryptsec, and derives the
+// public key. nostr.publickey is optional, but must match when set. Problems
+// are returned as a FieldError.
+//
+// The keys are cached, so an encrypted key is only decrypted once.
+func (c *Config) NostrKeys() (NostrKeys, error) {
+	if c.nostrKeys != nil {
+		return *c.nostrKeys, nil
+	}
+
+	keys, err := c.loadNostrKeys()
+	if err != nil {
+		return NostrKeys{}, err
+	}
+	c.nostrKeys = &keys
+	return keys, nil
+}
+
+func (c *Config) loadNostrKeys() (NostrKeys, error) {
+	cfg := c.Nostr
+	fieldError := func(key, problem, hint string) error {
+		return FieldError{Key: key, Source: c.Source(key), Problem: problem, Hint: hint}
+	}
+
+	key, keyName := cfg.PrivateKey, "nostr.privatekey"
+	if cfg.PrivateKeyFile != "" {
+		if cfg.PrivateKey != "" {
+			return NostrKeys{}, fieldError("nostr.privatekey-file", "is set together with nostr.privatekey", "set only one of them")
+		}
+
+		keyName = "nostr.privatekey-file"
+		b, err := readSecretFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return NostrKeys{}, fieldError(keyName, err.Error(), "e.g. a Docker secret at /run/secrets/<name>")
+		}
+		key = b
+	}
+	if key == "" {
+		return NostrKeys{}, fieldError("nostr.privatekey", "must be set when nostr.enable is true",
+			"the key of the account that signs zap receipts; or read it from a file with nostr.privatekey-file, or sign with nostr.bunker")
+	}
+
+	passphrase := cfg.Passphrase
+	if cfg.PassphraseFile != "" {
+		b, err := readSecretFile(cfg.PassphraseFile)
+		if err != nil {
+			return NostrKeys{}, fieldError("nostr.passphrase-file", err.Error(), "")
+		}
+		passphrase = b
+	}
+
+	privateKey, err := nostrpkg.ParsePrivateKey(key, passphrase)
+	if errors.Is(err, nostrpkg.ErrPassphraseRequired) {
+		return NostrKeys{}, fieldError("nostr.passphrase", err.Error(), "set NOSTR_KEY_PASSPHRASE or nostr.passphrase-file")
+	}
+	if err != nil {
+		return NostrKeys{}, fieldError(keyName, err.Error(), "the private key starts with nsec1 or ncryptsec1, or is 64 hex characters")
+	}
+
+	publicKey, err := nostrpkg.PublicKey(privateKey)
+	if err != nil {
+		return NostrKeys{}, fieldError(keyName, "is not a valid private key", "")
+	}
+
+	if cfg.PublicKey != "" {
+		configured, err := nostrpkg.ParsePublicKey(cfg.PublicKey)
+		if err != nil {
+			return NostrKeys{}, fieldError("nostr.publickey", err.Error(), "it can be left empty, it's derived from the private key")
+		}
+		if configured != publicKey {
+			npub, _ := nip19.EncodePublicKey(publicKey)
+			return NostrKeys{}, fieldError("nostr.publickey", "does not belong to "+keyName,
+				fmt.Sprintf("the npub of the private key is %s; leave nostr.publickey empty to use it", npub))
+		}
+	}
+
+	return NostrKeys{PrivateKey: privateKey, PublicKey: publicKey}, nil
+}
+
+// readSecretFile reads a key or passphrase from a file, without the trailing
+// newline most editors and `echo` add.
+func readSecretFile(path string) (string, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
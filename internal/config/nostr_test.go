@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNostrKeys(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	nsec, _ := nip19.EncodePrivateKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+	ncryptsec, err := nip49.Encrypt(sk, "correct horse", 16, nip49.ClientDoesNotTrackThisData)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	keyFile := writeFile("nsec", nsec+"\n")
+	encryptedKeyFile := writeFile("ncryptsec", ncryptsec+"\n")
+	passphraseFile := writeFile("passphrase", "correct horse\n")
+
+	otherPK, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	tests := []struct {
+		name    string
+		nostr   NostrConfig
+		wantKey string // the key of the FieldError, empty when the keys load
+	}{
+		{"nsec, derived npub", NostrConfig{PrivateKey: nsec}, ""},
+		{"hex keys", NostrConfig{PrivateKey: sk, PublicKey: pk}, ""},
+		{"matching npub", NostrConfig{PrivateKey: nsec, PublicKey: npub}, ""},
+		{"key file", NostrConfig{PrivateKeyFile: keyFile}, ""},
+		{"ncryptsec", NostrConfig{PrivateKey: ncryptsec, Passphrase: "correct horse"}, ""},
+		{"ncryptsec file with passphrase file", NostrConfig{PrivateKeyFile: encryptedKeyFile, PassphraseFile: passphraseFile}, ""},
+		{"missing key", NostrConfig{}, "nostr.privatekey"},
+		{"key and key file", NostrConfig{PrivateKey: nsec, PrivateKeyFile: keyFile}, "nostr.privatekey-file"},
+		{"missing key file", NostrConfig{PrivateKeyFile: filepath.Join(dir, "missing")}, "nostr.privatekey-file"},
+		{"ncryptsec without passphrase", NostrConfig{PrivateKey: ncryptsec}, "nostr.passphrase"},
+		{"ncryptsec with wrong passphrase", NostrConfig{PrivateKey: ncryptsec, Passphrase: "wrong"}, "nostr.privatekey"},
+		{"npub as private key", NostrConfig{PrivateKey: npub}, "nostr.privatekey"},
+		{"other public key", NostrConfig{PrivateKey: nsec, PublicKey: otherPK}, "nostr.publickey"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Nostr: tt.nostr}
+			keys, err := cfg.NostrKeys()
+			if tt.wantKey == "" {
+				require.NoError(t, err)
+				assert.Equal(t, NostrKeys{PrivateKey: sk, PublicKey: pk}, keys)
+				return
+			}
+
+			var fe FieldError
+			require.True(t, errors.As(err, &fe), "got %v", err)
+			assert.Equal(t, tt.wantKey, fe.Key)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// FieldError is a problem with one config key.
+type FieldError struct {
+	Key     string
+	Source  string // where the value came from, see Config.Source
+	Problem string
+	Hint    string // how to fix it, may be empty
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", e.Key, e.Source, e.Problem)
+}
+
+// ValidationError lists every problem Validate found.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d problem(s):", len(e))
+	for _, fe := range e {
+		b.WriteString("\n  " + fe.Error())
+		if fe.Hint != "" {
+			b.WriteString("\n    hint: " + fe.Hint)
+		}
+	}
+	return b.String()
+}
+
+var (
+	// usernamePattern is the local part of a Lightning Address (LUD-16).
+	usernamePattern = regexp.MustCompile(`^[a-z0-9\-_.+]+$`)
+	hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+)
+
+// Validate checks the config for mistakes that would otherwise only show up
+// while lmt runs. It returns every problem at once, as a ValidationError.
+func (c *Config) Validate() error {
+	v := &validator{cfg: c}
+	v.general()
+	v.lnurl()
+	v.lnd()
+	v.tls()
+	if c.Oksusu.Enabled && c.Oksusu.Token == "" {
+		v.add("oksusu.token", "must be set when oksusu.enable is true", "copy your token from your Oksu Connect account")
+	}
+	if c.Nostr.Enabled {
+		v.nostr()
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	cfg  *Config
+	errs ValidationError
+}
+
+func (v *validator) add(key, problem, hint string) {
+	v.errs = append(v.errs, FieldError{Key: key, Source: v.cfg.Source(key), Problem: problem, Hint: hint})
+}
+
+func (v *validator) general() {
+	username := v.cfg.General.Username
+	switch {
+	case username == "":
+		v.add("general.username", "must be set", "the part of your Lightning Address before the @, e.g. general.username=satoshi")
+	case !usernamePattern.MatchString(username):
+		v.add("general.username", fmt.Sprintf("%q is not a valid Lightning Address name", username),
+			"use only lowercase letters, digits and - _ . +")
+	}
+}
+
+func (v *validator) lnurl() {
+	cfg := v.cfg.LNURL
+
+	if problem, hint := checkDomain(cfg.Domain); problem != "" {
+		v.add("lnurl.domain", problem, hint)
+	}
+
+	if cfg.MinSendableMsat <= 0 {
+		v.add("lnurl.min-sendable", "must be positive", "amounts are in msats, 1000 is 1 sat")
+	}
+	if cfg.MaxSendableMsat < cfg.MinSendableMsat {
+		v.add("lnurl.max-sendable", fmt.Sprintf("%d is less than lnurl.min-sendable (%d)", cfg.MaxSendableMsat, cfg.MinSendableMsat),
+			"amounts are in msats, 1000 is 1 sat")
+	}
+	if cfg.CommentAllowed < 0 {
+		v.add("lnurl.comment-allowed", "must not be negative", "set it to 0 to disable comments")
+	}
+}
+
+// checkDomain returns why domain isn't a bare host name, with a hint.
+func checkDomain(domain string) (problem, hint string) {
+	if domain == "" {
+		return "must be set", "the domain of your Lightning Address, e.g. lnurl.domain=example.com"
+	}
+	if u, err := url.Parse(domain); err == nil && u.Scheme != "" && u.Host != "" {
+		return fmt.Sprintf("%q is a URL, not a domain", domain), "remove the scheme, e.g. lnurl.domain=" + u.Host
+	}
+	if strings.Contains(domain, "/") {
+		return fmt.Sprintf("%q contains a path", domain), "set only the domain, e.g. lnurl.domain=" + domain[:strings.Index(domain, "/")]
+	}
+
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if host != "localhost" && !hostnamePattern.MatchString(strings.ToLower(host)) {
+		return fmt.Sprintf("%q is not a valid domain", domain), "e.g. lnurl.domain=example.com"
+	}
+	return "", ""
+}
+
+func (v *validator) lnd() {
+	cfg := v.cfg.LND
+
+	if cfg.LNDConnect != "" {
+		if _, err := lndrest.ParseLNDConnectURI(cfg.LNDConnect); err != nil {
+			v.add("lnd.lndconnect", err.Error(), "run `lmt lndconnect <uri>` to test the URI")
+		}
+		return
+	}
+
+	if cfg.Macaroon != "" {
+		if _, err := lndrest.DecodeMacaroon(cfg.Macaroon); err != nil {
+			v.add("lnd.macaroon", err.Error(), "paste the macaroon hex or base64 encoded, e.g. the output of `xxd -p -c 1000 admin.macaroon`")
+		}
+	} else {
+		v.readable("lnd.macaroonpath", cfg.MacaroonPath,
+			"point it at a macaroon of your LND node, e.g. invoice.macaroon, or set lnd.macaroon or lnd.lndconnect")
+	}
+
+	switch {
+	case cfg.TLSCertPath != "":
+		v.readable("lnd.tlscertpath", cfg.TLSCertPath, "point it at tls.cert in your LND directory, or set lnd.tlscert-fingerprint")
+	case cfg.TLSCertFingerprint == "" && !cfg.InsecureSkipVerify:
+		v.add("lnd.tlscertpath", "must be set to verify LND's TLS certificate",
+			"point it at tls.cert in your LND directory, set lnd.tlscert-fingerprint, or set lnd.insecure-skip-verify to connect without verifying it")
+	}
+}
+
+func (v *validator) tls() {
+	cfg := v.cfg.Server
+
+	switch {
+	case cfg.ACME:
+		if cfg.TLSCert != "" || cfg.TLSKey != "" {
+			v.add("server.acme", "is set together with server.tls-cert or server.tls-key", "use either ACME or your own certificate")
+		}
+		// The certificate is issued for the domain of the Lightning Address.
+		host := v.cfg.LNURL.Domain
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			v.add("server.acme", fmt.Sprintf("needs lnurl.domain without a port, not %q", host), "ACME validates the domain on the default ports 80 and 443")
+			host = h
+		}
+		switch {
+		case host == "":
+			v.add("server.acme", "needs lnurl.domain", "set lnurl.domain to the public domain the certificate is for")
+		case host == "localhost" || strings.HasSuffix(host, ".onion"):
+			v.add("server.acme", fmt.Sprintf("can't get a certificate for %q", host), "ACME only works for public domains, use server.tls-cert or plain HTTP instead")
+		}
+	case cfg.TLSCert != "" && cfg.TLSKey == "":
+		v.add("server.tls-key", "must be set together with server.tls-cert", "point it at the private key of the certificate")
+	case cfg.TLSKey != "" && cfg.TLSCert == "":
+		v.add("server.tls-cert", "must be set together with server.tls-key", "point it at the certificate of the private key")
+	case cfg.TLSCert != "":
+		v.readable("server.tls-cert", cfg.TLSCert, "point it at the certificate file, e.g. fullchain.pem")
+		v.readable("server.tls-key", cfg.TLSKey, "point it at the private key file, e.g. privkey.pem")
+	}
+}
+
+// readable checks that the file at path can be read.
+func (v *validator) readable(key, path, hint string) {
+	expanded, err := ExpandPath(path)
+	if err == nil {
+		var f *os.File
+		if f, err = os.Open(expanded); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		v.add(key, fmt.Sprintf("can't read %s: %v", path, err), hint)
+	}
+}
+
+func (v *validator) nostr() {
+	cfg := v.cfg.Nostr
+
+	if cfg.Bunker != "" {
+		if _, _, _, err := nostrpkg.ParseBunkerURI(cfg.Bunker); err != nil {
+			v.add("nostr.bunker", err.Error(), "copy the bunker:// URI from your remote signer, e.g. nsec.app or Amber")
+		}
+		if cfg.PrivateKey != "" || cfg.PrivateKeyFile != "" {
+			v.add("nostr.bunker", "is set together with a private key", "remove nostr.privatekey and nostr.privatekey-file, the remote signer holds the key")
+		}
+		// lmt advertises the key before the remote signer is reachable.
+		if cfg.PublicKey == "" {
+			v.add("nostr.publickey", "must be set with nostr.bunker", "the npub the remote signer signs as")
+		} else if _, err := nostrpkg.ParsePublicKey(cfg.PublicKey); err != nil {
+			v.add("nostr.publickey", err.Error(), "the public key starts with npub1")
+		}
+	} else if _, err := v.cfg.NostrKeys(); err != nil {
+		var fe FieldError
+		if !errors.As(err, &fe) {
+			fe = FieldError{Key: "nostr.privatekey", Source: v.cfg.Source("nostr.privatekey"), Problem: err.Error()}
+		}
+		v.errs = append(v.errs, fe)
+	}
+
+	if len(cfg.Relays) == 0 {
+		v.add("nostr.relays", "must list at least one relay", "e.g. nostr.relays=wss://relay.damus.io,wss://relay.primal.net")
+	}
+	for _, relay := range cfg.Relays {
+		if u, err := url.Parse(relay); err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Host == "" {
+			v.add("nostr.relays", fmt.Sprintf("%q is not a relay URL", relay), "relay URLs start with wss://")
+		}
+	}
+
+	for _, npub := range cfg.Notify {
+		if _, err := nostrpkg.ParsePublicKey(npub); err != nil {
+			v.add("nostr.notify", fmt.Sprintf("%q: %v", npub, err), "the public key starts with npub1")
+		}
+	}
+}
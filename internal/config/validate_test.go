@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func validConfig(t *testing.T) *Config {
+	macaroon := filepath.Join(t.TempDir(), "invoice.macaroon")
+	require.NoError(t, os.WriteFile(macaroon, []byte{0x02}, 0o600))
+
+	privateKey := nostr.GeneratePrivateKey()
+	publicKey, err := nostr.GetPublicKey(privateKey)
+	require.NoError(t, err)
+	nsec, _ := nip19.EncodePrivateKey(privateKey)
+	npub, _ := nip19.EncodePublicKey(publicKey)
+
+	cfg := &Config{}
+	cfg.General.Username = "satoshi"
+	cfg.LNURL.Domain = "example.com"
+	cfg.LNURL.MinSendableMsat = 1000
+	cfg.LNURL.MaxSendableMsat = 1000000
+	cfg.LND.MacaroonPath = macaroon
+	cfg.LND.TLSCertFingerprint = "5f0c8b8e3a6f1f3c2b0e8a4d9c7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e"
+	cfg.API.Token = "s3cr3t"
+	cfg.Nostr.Enabled = true
+	cfg.Nostr.PrivateKey = nsec
+	cfg.Nostr.PublicKey = npub
+	cfg.Nostr.Relays = []string{"wss://relay.damus.io"}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, validConfig(t).Validate())
+
+	otherPublicKey, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	otherNpub, _ := nip19.EncodePublicKey(otherPublicKey)
+	bunker := "bunker://" + otherPublicKey + "?relay=wss://relay.nsec.app"
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		keys   []string
+	}{
+		{"empty username", func(c *Config) { c.General.Username = "" }, []string{"general.username"}},
+		{"uppercase username", func(c *Config) { c.General.Username = "Satoshi" }, []string{"general.username"}},
+		{"domain with scheme", func(c *Config) { c.LNURL.Domain = "https://example.com" }, []string{"lnurl.domain"}},
+		{"domain with path", func(c *Config) { c.LNURL.Domain = "example.com/pay" }, []string{"lnurl.domain"}},
+		{"domain with port", func(c *Config) { c.LNURL.Domain = "example.com:8443" }, nil},
+		{"onion domain", func(c *Config) { c.LNURL.Domain = "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx.onion" }, nil},
+		{"min above max", func(c *Config) { c.LNURL.MinSendableMsat = 2000000 }, []string{"lnurl.max-sendable"}},
+		{"negative comment", func(c *Config) { c.LNURL.CommentAllowed = -1 }, []string{"lnurl.comment-allowed"}},
+		{"unreadable macaroon", func(c *Config) { c.LND.MacaroonPath = "/nonexistent/admin.macaroon" }, []string{"lnd.macaroonpath"}},
+		{"inline macaroon", func(c *Config) { c.LND.MacaroonPath, c.LND.Macaroon = "/nonexistent", "0201036c6e6402" }, nil},
+		{"unverified lnd certificate", func(c *Config) { c.LND.TLSCertFingerprint = "" }, []string{"lnd.tlscertpath"}},
+		{"insecure lnd certificate", func(c *Config) { c.LND.TLSCertFingerprint, c.LND.InsecureSkipVerify = "", true }, nil},
+		{"no api token", func(c *Config) { c.API.Token = "" }, nil},
+		{"oksusu without token", func(c *Config) { c.Oksusu.Enabled = true }, []string{"oksusu.token"}},
+		{"oksusu", func(c *Config) { c.Oksusu.Enabled, c.Oksusu.Token = true, "token" }, nil},
+		{"tls cert without key", func(c *Config) { c.Server.TLSCert = "/etc/lmt/cert.pem" }, []string{"server.tls-key"}},
+		{"tls key without cert", func(c *Config) { c.Server.TLSKey = "/etc/lmt/key.pem" }, []string{"server.tls-cert"}},
+		{"unreadable tls files", func(c *Config) { c.Server.TLSCert, c.Server.TLSKey = "/nonexistent/cert.pem", "/nonexistent/key.pem" }, []string{"server.tls-cert", "server.tls-key"}},
+		{"acme", func(c *Config) { c.Server.ACME = true }, nil},
+		{"acme without domain", func(c *Config) { c.Server.ACME, c.LNURL.Domain = true, "" }, []string{"lnurl.domain", "server.acme"}},
+		{"acme with port", func(c *Config) { c.Server.ACME, c.LNURL.Domain = true, "example.com:8443" }, []string{"server.acme"}},
+		{"acme on onion", func(c *Config) {
+			c.Server.ACME, c.LNURL.Domain = true, "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx.onion"
+		}, []string{"server.acme"}},
+		{"acme with certificate", func(c *Config) { c.Server.ACME, c.Server.TLSCert = true, "/etc/lmt/cert.pem" }, []string{"server.acme"}},
+		{"mismatched keys", func(c *Config) { c.Nostr.PublicKey = otherNpub }, []string{"nostr.publickey"}},
+		{"npub as private key", func(c *Config) { c.Nostr.PrivateKey = otherNpub }, []string{"nostr.privatekey"}},
+		{"https relay", func(c *Config) { c.Nostr.Relays = []string{"https://relay.damus.io"} }, []string{"nostr.relays"}},
+		{"bunker", func(c *Config) { c.Nostr.PrivateKey, c.Nostr.Bunker = "", bunker }, nil},
+		{"bunker with private key", func(c *Config) { c.Nostr.Bunker = bunker }, []string{"nostr.bunker"}},
+		{"bunker without public key", func(c *Config) { c.Nostr.PrivateKey, c.Nostr.PublicKey, c.Nostr.Bunker = "", "", bunker }, []string{"nostr.publickey"}},
+		{"bunker without relay", func(c *Config) { c.Nostr.PrivateKey, c.Nostr.Bunker = "", "bunker://"+otherPublicKey }, []string{"nostr.bunker"}},
+		{"nostr disabled", func(c *Config) { c.Nostr.Enabled, c.Nostr.PublicKey = false, "" }, nil},
+		{"several problems", func(c *Config) { c.General.Username, c.LNURL.Domain = "", "" }, []string{"general.username", "lnurl.domain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.keys == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var verr ValidationError
+			require.True(t, errors.As(err, &verr), "got %v", err)
+			var keys []string
+			for _, fe := range verr {
+				keys = append(keys, fe.Key)
+				assert.Equal(t, "default", fe.Source)
+			}
+			assert.Equal(t, tt.keys, keys)
+		})
+	}
+}
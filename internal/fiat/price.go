@@ -0,0 +1,162 @@
+package fiat
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoPrice = errors.New("no price available")
+
+// ErrStalePrice is returned for a price quoted longer ago than allowed.
+var ErrStalePrice = errors.New("price is too old")
+
+// PriceSource gives the price of one bitcoin in a fiat currency.
+type PriceSource interface {
+	// Price returns the price at the given time. Currencies are ISO 4217 codes.
+	Price(ctx context.Context, currency string, at time.Time) (float64, error)
+}
+
+// Quoter is implemented by price sources that know when a price was quoted.
+type Quoter interface {
+	// Quote returns the price at the given time and the time it was quoted at.
+	Quote(ctx context.Context, currency string, at time.Time) (price float64, quotedAt time.Time, err error)
+}
+
+// CurrentPrice returns the price now, or ErrStalePrice if source is a Quoter
+// and its latest price is older than maxAge. A maxAge of 0 accepts any price.
+func CurrentPrice(ctx context.Context, source PriceSource, currency string, maxAge time.Duration) (float64, error) {
+	now := time.Now()
+	quoter, ok := source.(Quoter)
+	if !ok || maxAge <= 0 {
+		return source.Price(ctx, currency, now)
+	}
+
+	price, quotedAt, err := quoter.Quote(ctx, currency, now)
+	if err != nil {
+		return 0, err
+	}
+	if age := now.Sub(quotedAt); age > maxAge {
+		return 0, fmt.Errorf("%w: %s price is from %s", ErrStalePrice, currency, quotedAt.Format(time.RFC3339))
+	}
+	return price, nil
+}
+
+// MsatToFiat converts an amount in msat to fiat at the given price per bitcoin.
+func MsatToFiat(msat int64, price float64) float64 {
+	return float64(msat) / 1e11 * price
+}
+
+// StaticPrices is a PriceSource with one fixed price per currency.
+type StaticPrices map[string]float64
+
+func (s StaticPrices) Price(_ context.Context, currency string, _ time.Time) (float64, error) {
+	price, ok := s[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s", ErrNoPrice, currency)
+	}
+	return price, nil
+}
+
+type pricePoint struct {
+	at    time.Time
+	price float64
+}
+
+// PriceFile is a PriceSource backed by historical prices, for offline use.
+// The price at a given time is the most recent one at or before it.
+type PriceFile struct {
+	prices map[string][]pricePoint
+}
+
+// LoadPriceFile reads a price file. See ParsePrices for the format.
+func LoadPriceFile(path string) (*PriceFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open price file: %w", err)
+	}
+	defer f.Close()
+
+	prices, err := ParsePrices(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price file %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// ParsePrices parses CSV lines of date,currency,price, e.g.
+//
+//	2024-01-01,USD,42280.23
+//	2024-01-01T12:00:00Z,EUR,38300
+//
+// Dates are YYYY-MM-DD (midnight UTC) or RFC 3339. A header line and lines
+// starting with # are ignored.
+func ParsePrices(r io.Reader) (*PriceFile, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	p := &PriceFile{prices: make(map[string][]pricePoint)}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+
+		at, err := parseDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, record[0])
+		}
+
+		price, err := strconv.ParseFloat(record[2], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, record[2])
+		}
+
+		currency := strings.ToUpper(record[1])
+		p.prices[currency] = append(p.prices[currency], pricePoint{at: at, price: price})
+	}
+
+	for _, points := range p.prices {
+		sort.Slice(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	}
+	return p, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func (p *PriceFile) Price(ctx context.Context, currency string, at time.Time) (float64, error) {
+	price, _, err := p.Quote(ctx, currency, at)
+	return price, err
+}
+
+func (p *PriceFile) Quote(_ context.Context, currency string, at time.Time) (float64, time.Time, error) {
+	points := p.prices[strings.ToUpper(currency)]
+
+	// Index of the first point after at; the one before it is the price at that time.
+	i := sort.Search(len(points), func(i int) bool { return points[i].at.After(at) })
+	if i == 0 {
+		return 0, time.Time{}, fmt.Errorf("%w for %s at %s", ErrNoPrice, currency, at.Format(time.RFC3339))
+	}
+	return points[i-1].price, points[i-1].at, nil
+}
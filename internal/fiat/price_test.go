@@ -0,0 +1,85 @@
+package fiat
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPriceFile(t *testing.T) {
+	prices, err := ParsePrices(strings.NewReader(`date,currency,price
+# daily closes
+2024-01-02,USD,45000
+2024-01-01,USD,42000.5
+2024-01-01T12:00:00Z,eur,38000
+`))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	day := func(d int, h int) time.Time { return time.Date(2024, 1, d, h, 0, 0, 0, time.UTC) }
+
+	price, err := prices.Price(ctx, "USD", day(1, 18))
+	require.NoError(t, err)
+	assert.Equal(t, 42000.5, price)
+
+	price, err = prices.Price(ctx, "usd", day(5, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 45000.0, price)
+
+	price, err = prices.Price(ctx, "EUR", day(1, 12))
+	require.NoError(t, err)
+	assert.Equal(t, 38000.0, price)
+
+	_, err = prices.Price(ctx, "EUR", day(1, 11))
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	_, err = prices.Price(ctx, "KRW", day(1, 12))
+	assert.ErrorIs(t, err, ErrNoPrice)
+}
+
+func TestCurrentPrice(t *testing.T) {
+	ctx := context.Background()
+	yesterday := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	prices, err := ParsePrices(strings.NewReader(yesterday + ",USD,60000\n" + lastWeek + ",EUR,55000\n"))
+	require.NoError(t, err)
+
+	price, err := CurrentPrice(ctx, prices, "USD", 48*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 60000.0, price)
+
+	_, err = CurrentPrice(ctx, prices, "EUR", 48*time.Hour)
+	assert.ErrorIs(t, err, ErrStalePrice)
+
+	price, err = CurrentPrice(ctx, prices, "EUR", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 55000.0, price, "0 accepts any age")
+
+	_, err = CurrentPrice(ctx, prices, "KRW", 48*time.Hour)
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	// Static prices have no age.
+	price, err = CurrentPrice(ctx, StaticPrices{"USD": 1}, "USD", time.Nanosecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, price)
+}
+
+func TestParsePricesErrors(t *testing.T) {
+	for _, input := range []string{
+		"2024-13-01,USD,1",
+		"2024-01-01,USD,abc",
+		"2024-01-01,USD,-1",
+		"2024-01-01,USD",
+	} {
+		_, err := ParsePrices(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMsatToFiat(t *testing.T) {
+	assert.InDelta(t, 50.0, MsatToFiat(100_000_000, 50000), 1e-9)
+	assert.InDelta(t, 0.5, MsatToFiat(1_000_000, 50000), 1e-9)
+}
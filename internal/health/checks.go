@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"sync"
+)
+
+// LNDCheck checks that LND answers and is synced to the chain. The sync
+// state needs info:read, which the invoice macaroon lacks; without it, LND is
+// only checked with an invoice call.
+func LNDCheck(lnd *lndrest.Client) CheckFunc {
+	return func(ctx context.Context) Component {
+		info, err := lnd.GetInfo(ctx)
+		if err != nil {
+			if _, listErr := lnd.ListInvoices(ctx, lndrest.ListInvoicesParams{NumMaxInvoices: 1, Reversed: true}); listErr == nil {
+				return Component{Status: StatusUp, Message: "the macaroon lacks info:read, chain sync is not checked"}
+			}
+			return Component{Status: StatusDown, Message: err.Error()}
+		}
+
+		details := map[string]any{
+			"alias":           info.Alias,
+			"block_height":    info.BlockHeight,
+			"synced_to_chain": info.SyncedToChain,
+		}
+		if !info.SyncedToChain {
+			return Component{Status: StatusDegraded, Message: "LND is not synced to the chain", Details: details}
+		}
+		return Component{Status: StatusUp, Details: details}
+	}
+}
+
+// SubscriptionCheck checks the invoice subscription that payments are tracked with.
+func SubscriptionCheck(state func() lndrest.SubscriptionState) CheckFunc {
+	return func(context.Context) Component {
+		switch s := state(); s {
+		case lndrest.SubscriptionState_CONNECTED:
+			return Component{Status: StatusUp}
+		case lndrest.SubscriptionState_STOPPED:
+			return Component{Status: StatusDown, Message: "invoice subscription stopped"}
+		default:
+			return Component{Status: StatusDegraded, Message: fmt.Sprintf("invoice subscription is %s", s)}
+		}
+	}
+}
+
+// ConnectionCheck reports a connection's state, e.g. Oksu Connect's.
+func ConnectionCheck(connected func() bool, downMessage string) CheckFunc {
+	return func(context.Context) Component {
+		if connected() {
+			return Component{Status: StatusUp}
+		}
+		return Component{Status: StatusDown, Message: downMessage}
+	}
+}
+
+// SignerCheck reports whether the Nostr signer can sign. A remote signer
+// that will never be used, e.g. because it signs as another key, is reported
+// with the reason instead of as not connected.
+func SignerCheck(signer nostrpkg.Signer) CheckFunc {
+	return func(context.Context) Component {
+		if failed, ok := signer.(interface{ Err() error }); ok {
+			if err := failed.Err(); err != nil {
+				return Component{Status: StatusDown, Message: err.Error()}
+			}
+		}
+		if !signer.Ready() {
+			return Component{Status: StatusDown, Message: "the remote signer is not connected"}
+		}
+		return Component{Status: StatusUp}
+	}
+}
+
+// RelayCheck connects to every relay returned by relays. Unreachable relays
+// degrade lmt, since zap receipts and DMs may not arrive, but never take it
+// down.
+func RelayCheck(relays func() []string) CheckFunc {
+	return func(ctx context.Context) Component {
+		relays := relays()
+		var (
+			mtx         sync.Mutex
+			wg          sync.WaitGroup
+			unreachable = map[string]any{}
+		)
+		for _, url := range relays {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				relay, err := nostr.RelayConnect(ctx, url)
+				if err != nil {
+					mtx.Lock()
+					unreachable[url] = err.Error()
+					mtx.Unlock()
+					return
+				}
+				relay.Close()
+			}()
+		}
+		wg.Wait()
+
+		if len(unreachable) > 0 {
+			return Component{
+				Status:  StatusDegraded,
+				Message: fmt.Sprintf("%d of %d relays unreachable", len(unreachable), len(relays)),
+				Details: unreachable,
+			}
+		}
+		return Component{Status: StatusUp, Details: map[string]any{"relays": len(relays)}}
+	}
+}
@@ -0,0 +1,121 @@
+// Package health reports whether lmt and the services it depends on are working.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp Status = "up"
+	// StatusDegraded means lmt still serves payments, but something is not working.
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Component is the result of a single check.
+type Component struct {
+	Status  Status         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// CheckFunc checks one component.
+type CheckFunc func(ctx context.Context) Component
+
+// Report is the combined result of all checks.
+type Report struct {
+	Status     Status               `json:"status"`
+	Components map[string]Component `json:"components"`
+	CheckedAt  time.Time            `json:"checked_at"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker runs the registered checks. A critical component that is down takes
+// lmt down; anything else that is not up only degrades it.
+type Checker struct {
+	timeout time.Duration
+
+	mtx    sync.Mutex
+	checks []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check.
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Check runs all checks concurrently.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mtx.Lock()
+	checks := append([]check(nil), c.checks...)
+	c.mtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Component, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = chk.fn(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]Component, len(checks)),
+		CheckedAt:  time.Now().UTC(),
+	}
+	for i, chk := range checks {
+		result := results[i]
+		report.Components[chk.name] = result
+
+		switch {
+		case result.Status == StatusDown && chk.critical:
+			report.Status = StatusDown
+		case result.Status != StatusUp && report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// Cached wraps fn so it runs at most once per ttl, for checks that are too
+// expensive to run on every probe.
+func Cached(ttl time.Duration, fn CheckFunc) CheckFunc {
+	var (
+		mtx       sync.Mutex
+		last      Component
+		checkedAt time.Time
+	)
+
+	return func(ctx context.Context) Component {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if time.Since(checkedAt) < ttl {
+			return last
+		}
+		last = fn(ctx)
+		checkedAt = time.Now()
+		return last
+	}
+}
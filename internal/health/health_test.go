@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func status(s Status) CheckFunc {
+	return func(context.Context) Component { return Component{Status: s} }
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all up", func(t *testing.T) {
+		c := NewChecker(time.Second)
+		c.Add("lnd", true, status(StatusUp))
+		c.Add("relays", false, status(StatusUp))
+
+		report := c.Check(ctx)
+		assert.Equal(t, StatusUp, report.Status)
+		assert.Len(t, report.Components, 2)
+	})
+
+	t.Run("non-critical down degrades", func(t *testing.T) {
+		c := NewChecker(time.Second)
+		c.Add("lnd", true, status(StatusUp))
+		c.Add("relays", false, status(StatusDown))
+
+		report := c.Check(ctx)
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.Equal(t, StatusDown, report.Components["relays"].Status)
+	})
+
+	t.Run("critical degraded degrades", func(t *testing.T) {
+		c := NewChecker(time.Second)
+		c.Add("lnd", true, status(StatusDegraded))
+
+		assert.Equal(t, StatusDegraded, c.Check(ctx).Status)
+	})
+
+	t.Run("critical down is down", func(t *testing.T) {
+		c := NewChecker(time.Second)
+		c.Add("relays", false, status(StatusDegraded))
+		c.Add("lnd", true, status(StatusDown))
+
+		assert.Equal(t, StatusDown, c.Check(ctx).Status)
+	})
+
+	t.Run("checks are bounded by the timeout", func(t *testing.T) {
+		c := NewChecker(10 * time.Millisecond)
+		c.Add("slow", true, func(ctx context.Context) Component {
+			<-ctx.Done()
+			return Component{Status: StatusDown, Message: ctx.Err().Error()}
+		})
+
+		assert.Equal(t, StatusDown, c.Check(ctx).Status)
+	})
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	fn := Cached(time.Hour, func(context.Context) Component {
+		calls++
+		return Component{Status: StatusUp}
+	})
+
+	fn(context.Background())
+	fn(context.Background())
+	assert.Equal(t, 1, calls)
+}
+
+func TestLNDCheck(t *testing.T) {
+	var infoAllowed, invoicesAllowed atomic.Bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := invoicesAllowed.Load()
+		if r.URL.Path == "/v1/getinfo" {
+			allowed = infoAllowed.Load()
+		}
+		if !allowed {
+			http.Error(w, `{"code":2,"message":"permission denied"}`, http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/v1/getinfo" {
+			w.Write([]byte(`{"alias":"node","synced_to_chain":true}`))
+			return
+		}
+		w.Write([]byte(`{"invoices":[]}`))
+	}))
+	defer server.Close()
+
+	lnd, err := lndrest.NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+	check := LNDCheck(lnd)
+
+	infoAllowed.Store(true)
+	invoicesAllowed.Store(true)
+	assert.Equal(t, StatusUp, check(context.Background()).Status)
+	assert.Empty(t, check(context.Background()).Message)
+
+	// An invoice macaroon can't read the node info.
+	infoAllowed.Store(false)
+	component := check(context.Background())
+	assert.Equal(t, StatusUp, component.Status)
+	assert.Contains(t, component.Message, "info:read")
+
+	invoicesAllowed.Store(false)
+	assert.Equal(t, StatusDown, check(context.Background()).Status)
+}
+
+// failingSigner is a remote signer that may have stopped connecting for good.
+type failingSigner struct {
+	*nostrpkg.LocalSigner
+	ready bool
+	err   error
+}
+
+func (s *failingSigner) Ready() bool { return s.ready }
+func (s *failingSigner) Err() error  { return s.err }
+
+func TestSignerCheck(t *testing.T) {
+	local, err := nostrpkg.NewLocalSigner(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	assert.Equal(t, StatusUp, SignerCheck(local)(context.Background()).Status)
+
+	signer := &failingSigner{LocalSigner: local}
+	component := SignerCheck(signer)(context.Background())
+	assert.Equal(t, StatusDown, component.Status)
+	assert.Equal(t, "the remote signer is not connected", component.Message)
+
+	signer.err = errors.New("the remote signer signs as abcd instead of ef01")
+	component = SignerCheck(signer)(context.Background())
+	assert.Equal(t, StatusDown, component.Status)
+	assert.Equal(t, signer.err.Error(), component.Message)
+}
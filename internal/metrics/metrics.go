@@ -0,0 +1,141 @@
+// Package metrics holds lmt's Prometheus metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const namespace = "lmt"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	LNURLRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lnurl_requests_total",
+		Help:      "LNURL and NIP-05 requests served, by route and HTTP status.",
+	}, []string{"route", "status"})
+
+	LNURLRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lnurl_request_duration_seconds",
+		Help:      "Time to serve LNURL and NIP-05 requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	InvoicesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoices_created_total",
+		Help:      "Invoices created, by source (http or oksusu).",
+	}, []string{"source"})
+
+	InvoicesSettled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoices_settled_total",
+		Help:      "Invoices paid, by source.",
+	}, []string{"source"})
+
+	ReceivedMsat = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "received_msat_total",
+		Help:      "Msats received through paid invoices, by source.",
+	}, []string{"source"})
+
+	NostrEventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nostr_events_published_total",
+		Help:      "Nostr events sent to relays, by event kind, relay and result (ok or error). Zap receipts are kind 9735.",
+	}, []string{"kind", "relay", "result"})
+
+	ZapMonitorsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "zap_monitors_active",
+		Help:      "Zap requests waiting for their invoice to be paid.",
+	})
+
+	OksusuConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "oksusu_connected",
+		Help:      "1 if lmt is connected and authenticated to the Oksu server.",
+	})
+
+	OksusuReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oksusu_reconnects_total",
+		Help:      "Reconnection attempts to the Oksu server.",
+	})
+
+	LNDRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lnd_request_duration_seconds",
+		Help:      "Latency of LND REST calls, by method and path.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	LNDRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lnd_request_errors_total",
+		Help:      "Failed LND REST calls, by method and path.",
+	}, []string{"method", "path"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		LNURLRequests,
+		LNURLRequestDuration,
+		InvoicesCreated,
+		InvoicesSettled,
+		ReceivedMsat,
+		NostrEventsPublished,
+		ZapMonitorsActive,
+		OksusuConnected,
+		OksusuReconnects,
+		LNDRequestDuration,
+		LNDRequestErrors,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveLNDRequest records an LND REST call. It matches lndrest.RequestObserver.
+func ObserveLNDRequest(method, path string, duration time.Duration, err error) {
+	path = normalizePath(path)
+	LNDRequestDuration.WithLabelValues(method, path).Observe(duration.Seconds())
+	if err != nil {
+		LNDRequestErrors.WithLabelValues(method, path).Inc()
+	}
+}
+
+// normalizePath replaces hashes and other IDs in a REST path, so every
+// invoice doesn't get its own label, and drops the query.
+func normalizePath(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) >= 20 {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ObserveNostrPublish records an attempt to publish an event to a relay.
+func ObserveNostrPublish(kind int, relay string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	NostrEventsPublished.WithLabelValues(strconv.Itoa(kind), relay, result).Inc()
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"/v1/getinfo", "/v1/getinfo"},
+		{"/v1/invoices?pending_only=true&num_max_invoices=100", "/v1/invoices"},
+		{"/v1/invoice/5d8e1b3a6f0c4e2d9b7a1c3e5f7092a4b6c8d0e2f4a6b8c0d2e4f6a8b0c2d4e6", "/v1/invoice/:id"},
+		{"/v2/invoices/subscribe/XY4bOm8MTi2bdBw+X3CSpLbI0OL0prjA0uT2qLDC1OY=", "/v2/invoices/subscribe/:id"},
+		{"/v2/router/track/00112233445566778899aabbccddeeff?no_inflight_updates=true", "/v2/router/track/:id"},
+		{"/v1/channels", "/v1/channels"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, normalizePath(tt.path), tt.path)
+	}
+}
+
+func TestObserveLNDRequest(t *testing.T) {
+	const hash = "5d8e1b3a6f0c4e2d9b7a1c3e5f7092a4b6c8d0e2f4a6b8c0d2e4f6a8b0c2d4e6"
+	errors0 := testutil.ToFloat64(LNDRequestErrors.WithLabelValues("GET", "/v1/invoice/:id"))
+
+	ObserveLNDRequest("GET", "/v1/invoice/"+hash, time.Millisecond, nil)
+	ObserveLNDRequest("GET", "/v1/invoice/"+hash[1:]+"0", time.Millisecond, errors.New("unavailable"))
+
+	// Both invoices share one series.
+	assert.Equal(t, 1, testutil.CollectAndCount(LNDRequestDuration, "lmt_lnd_request_duration_seconds"))
+	assert.Equal(t, errors0+1, testutil.ToFloat64(LNDRequestErrors.WithLabelValues("GET", "/v1/invoice/:id")))
+}
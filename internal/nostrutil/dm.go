@@ -0,0 +1,49 @@
+package nostrutil
+
+import (
+	"context"
+	"fmt"
+	nostrpkg "github.com/asheswook/lightning-multitool/pkg/nostr"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"time"
+)
+
+// FetchDMRelays looks up the NIP-17 DM relays (kind 10050) of pubkey on relays.
+// It returns nil if the user has not published any.
+func FetchDMRelays(ctx context.Context, pubkey string, relays []string) []string {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pool := nostr.NewSimplePool(ctx)
+	return nip17.GetDMRelays(ctx, pubkey, pool, relays)
+}
+
+// NewGiftWrappedDM creates a NIP-17 direct message from signer to
+// recipient, sealed and gift wrapped as per NIP-59.
+func NewGiftWrappedDM(ctx context.Context, signer nostrpkg.Signer, recipient, content string) (nostr.Event, error) {
+	_, toThem, err := nip17.PrepareMessage(ctx, content, nostr.Tags{}, signer, recipient, nil)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to gift wrap message: %w", err)
+	}
+	return toThem, nil
+}
+
+// NewNIP04DM creates a legacy NIP-04 encrypted direct message (kind 4).
+func NewNIP04DM(ctx context.Context, signer nostrpkg.Signer, recipient, content string) (nostr.Event, error) {
+	ciphertext, err := signer.NIP04Encrypt(ctx, content, recipient)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encrypt message: %w", err)
+	}
+
+	event := nostr.Event{
+		Kind:      nostr.KindEncryptedDirectMessage,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", recipient}},
+		Content:   ciphertext,
+	}
+	if err := signer.SignEvent(ctx, &event); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign message: %w", err)
+	}
+	return event, nil
+}
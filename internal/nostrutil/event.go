@@ -2,6 +2,7 @@ package nostrutil
 
 import (
 	"context"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
 	"github.com/nbd-wtf/go-nostr"
 	"log/slog"
 	"sync"
@@ -23,11 +24,14 @@ func PublishEvent(ctx context.Context, event nostr.Event, relays []string) {
 			relay, err := nostr.RelayConnect(publishCtx, relayURL)
 			if err != nil {
 				slog.Error("Error connecting to relay", "relay", relayURL, "error", err)
+				metrics.ObserveNostrPublish(event.Kind, relayURL, err)
 				return
 			}
 			defer relay.Close()
 
-			if err = relay.Publish(publishCtx, event); err != nil {
+			err = relay.Publish(publishCtx, event)
+			metrics.ObserveNostrPublish(event.Kind, relayURL, err)
+			if err != nil {
 				slog.Error("Error publishing event to relay", "event_id", event.ID, "relay", relayURL, "error", err)
 				return
 			}
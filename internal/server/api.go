@@ -1,26 +1,144 @@
 package server
 
 import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/internal/fiat"
+	"github.com/asheswook/lightning-multitool/internal/health"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
+	"github.com/asheswook/lightning-multitool/internal/webhook"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 // API provides an HTTP server for administrative tasks, like stopping the application.
-type API struct{}
+type API struct {
+	token    string
+	health   *health.Checker
+	webhooks *webhook.Dispatcher
+	history  *app.InvoiceHistory
+	reloader Reloader
 
-// NewAPI creates a new API server instance.
-func NewAPI() *API {
-	return &API{}
+	prices   fiat.PriceSource
+	currency string
+
+	// sessions maps the IDs of logged in dashboard sessions to their expiry.
+	mtx      sync.Mutex
+	sessions map[string]time.Time
+}
+
+// NewAPI creates a new API server instance. When token is set, every request
+// must carry it as a bearer token. Without one, only health probes, metrics
+// and /api/stop from the local host are served.
+func NewAPI(token string, checker *health.Checker, webhooks *webhook.Dispatcher, history *app.InvoiceHistory, reloader Reloader, prices fiat.PriceSource, currency string) *API {
+	return &API{
+		token:    token,
+		health:   checker,
+		webhooks: webhooks,
+		history:  history,
+		reloader: reloader,
+		prices:   prices,
+		currency: currency,
+		sessions: make(map[string]time.Time),
+	}
 }
 
 // ListenAndServe starts the API server on the given address.
 func (a *API) ListenAndServe(addr string) error {
+	slog.Info("Starting API server", "addr", addr)
+	return http.ListenAndServe(addr, a.withAuth(a.mux()))
+}
+
+func (a *API) mux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/stop", a.stop)
-	slog.Info("Starting API server", "addr", addr)
-	return http.ListenAndServe(addr, mux)
+	mux.HandleFunc("POST /api/reload", a.reload)
+	mux.HandleFunc("GET /healthz", a.healthz)
+	mux.HandleFunc("GET /readyz", a.readyz)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /api/invoices", a.listInvoices)
+	mux.HandleFunc("GET /api/export", a.export)
+	mux.HandleFunc("GET /api/webhooks/deliveries", a.listDeliveries)
+	mux.HandleFunc("POST /api/webhooks/deliveries/{id}/replay", a.replayDelivery)
+	mux.HandleFunc("GET /api/webhooks/endpoints", a.listEndpoints)
+	mux.HandleFunc("POST /api/webhooks/endpoints", a.addEndpoint)
+	mux.HandleFunc("DELETE /api/webhooks/endpoints/{id}", a.removeEndpoint)
+	mux.HandleFunc("GET /api/stats/daily", a.dailyStats)
+	mux.HandleFunc("GET /admin", a.dashboard)
+	mux.HandleFunc("GET /admin/app.js", a.dashboardScript)
+	mux.HandleFunc("POST /admin/login", a.login)
+	mux.HandleFunc("POST /admin/logout", a.logout)
+	return mux
+}
+
+// withAuth rejects requests without the configured bearer token, or the
+// session cookie set by the dashboard login. Health probes and the
+// dashboard page itself, which holds no data, are always allowed.
+//
+// The API listens on all interfaces, so without a token it refuses the
+// dashboard and everything that shows payer data or changes something. Only
+// health probes, metrics and stopping lmt from the local host are served.
+func (a *API) withAuth(next http.Handler) http.Handler {
+	if a.token == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				next.ServeHTTP(w, r)
+				return
+			case "/api/stop":
+				if isLoopback(r.RemoteAddr) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "forbidden, set api.token to use this endpoint", http.StatusForbidden)
+		})
+	}
+
+	expected := []byte("Bearer " + a.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/admin", "/admin/app.js", "/admin/login", "/admin/logout":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Browsers attach the cookie to any request, so changes made with
+		// it need a header that other sites can't send without CORS.
+		if a.validSession(r) && (r.Method == http.MethodGet || r.Method == http.MethodHead || r.Header.Get(csrfHeader) != "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// isLoopback reports whether the request came from the local host.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 // stop handles the /api/stop request, shutting down the application.
@@ -34,3 +152,260 @@ func (a *API) stop(w http.ResponseWriter, req *http.Request) {
 		os.Exit(0)
 	}()
 }
+
+// healthz reports that the process is alive.
+func (a *API) healthz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]health.Status{"status": health.StatusUp})
+}
+
+// readyz checks LND and everything else lmt depends on. It answers 503 only
+// when lmt can't receive payments; a degraded lmt is still ready.
+func (a *API) readyz(w http.ResponseWriter, req *http.Request) {
+	report := a.health.Check(req.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// invoicesResponse is a page of the invoice history.
+type invoicesResponse struct {
+	Total    int                 `json:"total"`
+	Offset   int                 `json:"offset"`
+	Limit    int                 `json:"limit"`
+	Invoices []app.InvoiceRecord `json:"invoices"`
+}
+
+// listInvoices returns the invoice history, newest first. It accepts the
+// filters from, until (RFC 3339 or unix seconds), user, settled and zaps,
+// and paginates with offset and limit.
+func (a *API) listInvoices(w http.ResponseWriter, req *http.Request) {
+	query, err := parseInvoiceQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invoices, total := a.history.Query(query)
+	if invoices == nil {
+		invoices = []app.InvoiceRecord{}
+	}
+
+	writeJSON(w, http.StatusOK, invoicesResponse{
+		Total:    total,
+		Offset:   query.Offset,
+		Limit:    query.Limit,
+		Invoices: invoices,
+	})
+}
+
+func parseInvoiceQuery(values url.Values) (app.InvoiceQuery, error) {
+	query := app.InvoiceQuery{
+		Username: values.Get("user"),
+		Limit:    100,
+	}
+
+	var err error
+	if query.From, err = app.ParseTime(values.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %w", err)
+	}
+	if query.Until, err = app.ParseTime(values.Get("until")); err != nil {
+		return query, fmt.Errorf("invalid until: %w", err)
+	}
+
+	if v := values.Get("settled"); v != "" {
+		settled, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid settled: %w", err)
+		}
+		query.Settled = &settled
+	}
+
+	if v := values.Get("zaps"); v != "" {
+		if query.ZapsOnly, err = strconv.ParseBool(v); err != nil {
+			return query, fmt.Errorf("invalid zaps: %w", err)
+		}
+	}
+
+	if v := values.Get("offset"); v != "" {
+		if query.Offset, err = strconv.Atoi(v); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset")
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 || query.Limit > 1000 {
+			return query, fmt.Errorf("invalid limit, must be between 1 and 1000")
+		}
+	}
+
+	return query, nil
+}
+
+// export returns the settled payments as a ledger for accounting. It accepts
+// format (csv, json or koinly), currency, and the time filters of listInvoices.
+func (a *API) export(w http.ResponseWriter, req *http.Request) {
+	values := req.URL.Query()
+
+	format, err := app.ParseLedgerFormat(cmp.Or(values.Get("format"), string(app.LedgerFormat_CSV)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseInvoiceQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The ledger is dated by settlement, so the time filters apply to that.
+	from, until := query.From, query.Until
+	query.From, query.Until = time.Time{}, time.Time{}
+	query.Offset, query.Limit = 0, 0
+
+	all, _ := a.history.Query(query)
+	records := app.SettledBetween(all, from, until)
+	currency := strings.ToUpper(cmp.Or(values.Get("currency"), a.currency))
+	entries, err := app.BuildLedger(req.Context(), records, a.prices, currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	contentType, ext := "text/csv", "csv"
+	if format == app.LedgerFormat_JSON {
+		contentType, ext = "application/json", "json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lmt-%s.%s"`, format, ext))
+	if err := app.WriteLedger(w, format, entries); err != nil {
+		slog.Error("Failed to write export", "error", err)
+	}
+}
+
+// listDeliveries returns the most recent webhook deliveries, newest first.
+func (a *API) listDeliveries(w http.ResponseWriter, req *http.Request) {
+	limit := 100
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	writeJSON(w, http.StatusOK, a.webhooks.Deliveries(limit))
+}
+
+// replayDelivery sends the event of a delivery again.
+func (a *API) replayDelivery(w http.ResponseWriter, req *http.Request) {
+	delivery, err := a.webhooks.Replay(req.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Replaying webhook delivery", "delivery_id", req.PathValue("id"), "new_delivery_id", delivery.ID)
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// endpointResponse is a webhook endpoint as shown by the API. Its secret is
+// only shown once, when lmt generated it for a new endpoint.
+type endpointResponse struct {
+	ID         string              `json:"id"`
+	URL        string              `json:"url"`
+	Events     []webhook.EventType `json:"events"`
+	HasSecret  bool                `json:"has_secret"`
+	Secret     string              `json:"secret,omitempty"`
+	Configured bool                `json:"configured"`
+}
+
+// listEndpoints returns the webhook endpoints. Endpoints from the config file
+// are marked configured and can't be changed through the API.
+func (a *API) listEndpoints(w http.ResponseWriter, req *http.Request) {
+	endpoints := a.webhooks.Endpoints()
+	response := make([]endpointResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		response[i] = endpointResponse{
+			ID:         endpoint.ID,
+			URL:        endpoint.URL,
+			Events:     endpoint.Events,
+			HasSecret:  endpoint.Secret != "",
+			Configured: a.webhooks.IsConfigured(endpoint.ID),
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// addEndpoint adds a webhook endpoint from a JSON body with url, secret and
+// events, or replaces the one with the same URL. Without a secret, one is
+// generated and returned.
+func (a *API) addEndpoint(w http.ResponseWriter, req *http.Request) {
+	var endpoint webhook.Endpoint
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 64*1024)).Decode(&endpoint); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	generated := endpoint.Secret == ""
+	endpoint, err := a.webhooks.AddEndpoint(endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Added webhook endpoint", "endpoint_id", endpoint.ID, "url", endpoint.URL)
+	response := endpointResponse{
+		ID:        endpoint.ID,
+		URL:       endpoint.URL,
+		Events:    endpoint.Events,
+		HasSecret: true,
+	}
+	if generated {
+		response.Secret = endpoint.Secret
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// removeEndpoint removes a webhook endpoint added through the API.
+func (a *API) removeEndpoint(w http.ResponseWriter, req *http.Request) {
+	err := a.webhooks.RemoveEndpoint(req.PathValue("id"))
+	if errors.Is(err, webhook.ErrEndpointNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Removed webhook endpoint", "endpoint_id", req.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dailyStats returns what was received per day over the last days days
+// (default 30, at most 366), in UTC.
+func (a *API) dailyStats(w http.ResponseWriter, req *http.Request) {
+	days := 30
+	if v := req.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 366 {
+			http.Error(w, "invalid days, must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	settled := true
+	records, _ := a.history.Query(app.InvoiceQuery{Settled: &settled})
+	writeJSON(w, http.StatusOK, app.DailyTotals(records, days, time.Now().UTC()))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
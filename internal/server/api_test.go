@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/internal/health"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/asheswook/lightning-multitool/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeReloader struct {
+	result ReloadResult
+	err    error
+}
+
+func (f fakeReloader) Reload() (ReloadResult, error) {
+	return f.result, f.err
+}
+
+func newTestAPI(t *testing.T, token string) (http.Handler, *app.InvoiceHistory) {
+	return newTestAPIWithReloader(t, token, fakeReloader{})
+}
+
+func newTestAPIWithReloader(t *testing.T, token string, reloader Reloader) (http.Handler, *app.InvoiceHistory) {
+	dir := t.TempDir()
+	openLog := func(name string) *store.JSONLog {
+		log, err := store.OpenJSONLog(filepath.Join(dir, name))
+		require.NoError(t, err)
+		t.Cleanup(func() { log.Close() })
+		return log
+	}
+
+	dispatcher, err := webhook.NewDispatcher(nil, openLog("webhooks.jsonl"), 3)
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.ManageEndpoints(openLog("webhook_endpoints.jsonl")))
+
+	history, err := app.NewInvoiceHistory(openLog("invoices.jsonl"))
+	require.NoError(t, err)
+
+	api := NewAPI(token, health.NewChecker(time.Second), dispatcher, history, reloader, nil, "USD")
+	return api.withAuth(api.mux()), history
+}
+
+func TestAPIAuth(t *testing.T) {
+	handler, _ := newTestAPI(t, "s3cr3t")
+
+	do := func(method, path string, header http.Header, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/invoices", nil).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/invoices", http.Header{"Authorization": {"Bearer s3cr3t"}}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/admin", nil).Code, "the dashboard page holds no data")
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/readyz", nil).Code)
+
+	// A wrong token doesn't log in.
+	r := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(url.Values{"token": {"wrong"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "/admin?error=login", w.Header().Get("Location"))
+	assert.Empty(t, w.Result().Cookies())
+
+	r = httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(url.Values{"token": {"s3cr3t"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	session := cookies[0]
+	assert.True(t, session.HttpOnly)
+	assert.NotContains(t, session.Value, "s3cr3t")
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/invoices", nil, session).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodDelete, "/api/webhooks/endpoints/x", nil, session).Code,
+		"changes with the cookie need the CSRF header")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/webhooks/endpoints/x", http.Header{csrfHeader: {"lmt-dashboard"}}, session).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/invoices", nil, &http.Cookie{Name: sessionCookie, Value: "forged"}).Code)
+
+	// After logging out, the old cookie is no longer accepted.
+	r = httptest.NewRequest(http.MethodPost, "/admin/logout", nil)
+	r.AddCookie(session)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/invoices", nil, session).Code)
+}
+
+func TestAPIWithoutToken(t *testing.T) {
+	handler, _ := newTestAPI(t, "")
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/readyz", http.StatusOK},
+		{http.MethodGet, "/metrics", http.StatusOK},
+		{http.MethodGet, "/api/invoices", http.StatusForbidden},
+		{http.MethodGet, "/api/export", http.StatusForbidden},
+		{http.MethodGet, "/api/stats/daily", http.StatusForbidden},
+		{http.MethodGet, "/api/webhooks/endpoints", http.StatusForbidden},
+		{http.MethodGet, "/admin", http.StatusForbidden},
+		{http.MethodGet, "/admin/app.js", http.StatusForbidden},
+		{http.MethodGet, "/api/stop", http.StatusForbidden},
+		{http.MethodPost, "/api/reload", http.StatusForbidden},
+		{http.MethodPost, "/api/webhooks/endpoints", http.StatusForbidden},
+		{http.MethodDelete, "/api/webhooks/endpoints/x", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.want, w.Code, "%s %s", tt.method, tt.path)
+	}
+
+	// /api/stop is still served to the local host.
+	assert.True(t, isLoopback("127.0.0.1:40000"))
+	assert.True(t, isLoopback("[::1]:40000"))
+	assert.False(t, isLoopback("192.0.2.1:1234"))
+}
+
+// authorized adds the bearer token of the test API to r.
+func authorized(r *http.Request) *http.Request {
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	return r
+}
+
+func TestAPIWebhookEndpoints(t *testing.T) {
+	handler, _ := newTestAPI(t, "s3cr3t")
+
+	r := httptest.NewRequest(http.MethodPost, "/api/webhooks/endpoints", strings.NewReader(`{"url":"https://example.com/hook","secret":"s3cr3t","events":["zap.received"]}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(r))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+
+	r = httptest.NewRequest(http.MethodPost, "/api/webhooks/endpoints", strings.NewReader(`{"url":"https://example.com/hook","events":["unknown"]}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(r))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodGet, "/api/webhooks/endpoints", nil)))
+	var endpoints []endpointResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &endpoints))
+	require.Len(t, endpoints, 1)
+	assert.True(t, endpoints[0].HasSecret)
+	assert.Empty(t, endpoints[0].Secret)
+	assert.False(t, endpoints[0].Configured)
+
+	// Without a secret, one is generated and shown once.
+	r = httptest.NewRequest(http.MethodPost, "/api/webhooks/endpoints", strings.NewReader(`{"url":"https://example.com/other"}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(r))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var generated endpointResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &generated))
+	assert.Len(t, generated.Secret, 64)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodDelete, "/api/webhooks/endpoints/"+endpoints[0].ID, nil)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAPIDailyStats(t *testing.T) {
+	handler, history := newTestAPI(t, "s3cr3t")
+
+	invoice := app.IssuedInvoice{PaymentHash: "aa", AmountMsat: 21000, CreatedAt: time.Now()}
+	history.OnInvoiceIssued(invoice)
+	history.OnPaymentSettled(app.SettledPayment{IssuedInvoice: invoice, AmountPaidMsat: 21000, SettledAt: time.Now()})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodGet, "/api/stats/daily?days=7", nil)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var days []app.DailyTotal
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &days))
+	require.Len(t, days, 7)
+	assert.Equal(t, time.Now().UTC().Format(time.DateOnly), days[6].Date)
+	assert.Equal(t, int64(21000), days[6].AmountMsat)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodGet, "/api/stats/daily?days=0", nil)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIReload(t *testing.T) {
+	handler, _ := newTestAPIWithReloader(t, "s3cr3t", fakeReloader{result: ReloadResult{
+		Applied:         []string{"lnurl.max-sendable"},
+		RestartRequired: []string{"server.port"},
+	}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodPost, "/api/reload", nil)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"applied":["lnurl.max-sendable"],"restart_required":["server.port"]}`, w.Body.String())
+
+	handler, _ = newTestAPIWithReloader(t, "s3cr3t", fakeReloader{err: errors.New("lnurl.min-sendable is larger than lnurl.max-sendable")})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authorized(httptest.NewRequest(http.MethodPost, "/api/reload", nil)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "min-sendable")
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	sessionCookie = "lmt_session"
+	sessionMaxAge = 7 * 24 * time.Hour
+
+	// csrfHeader must be sent with changes authenticated by the session
+	// cookie. The dashboard sets it on every request.
+	csrfHeader = "X-Requested-With"
+)
+
+var (
+	//go:embed templates/admin.html
+	dashboardPage []byte
+	//go:embed templates/admin.js
+	dashboardScript []byte
+)
+
+// The dashboard is a static page; everything it shows comes from the JSON
+// admin endpoints, with the session cookie for authentication.
+const dashboardCSP = "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; connect-src 'self'; img-src 'self' data:; form-action 'self'; frame-ancestors 'none'"
+
+// dashboard serves the admin web UI.
+func (a *API) dashboard(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", dashboardCSP)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(dashboardPage)
+}
+
+func (a *API) dashboardScript(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(dashboardScript)
+}
+
+// newSession starts a dashboard session and returns its ID. Sessions are only
+// kept in memory, so a restart logs everyone out.
+func (a *API) newSession(now time.Time) string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for other, expiresAt := range a.sessions {
+		if now.After(expiresAt) {
+			delete(a.sessions, other)
+		}
+	}
+	a.sessions[id] = now.Add(sessionMaxAge)
+	return id
+}
+
+func (a *API) validSession(r *http.Request) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	expiresAt, ok := a.sessions[cookie.Value]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// login checks the API token posted by the dashboard's login form and sets
+// the session cookie.
+func (a *API) login(w http.ResponseWriter, req *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(req.PostFormValue("token")), []byte(a.token)) != 1 {
+		slog.Warn("Failed dashboard login", "remote_addr", req.RemoteAddr)
+		// Slow down guessing.
+		time.Sleep(time.Second)
+		http.Redirect(w, req, "/admin?error=login", http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    a.newSession(time.Now()),
+		Path:     "/",
+		MaxAge:   int(sessionMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, req, "/admin", http.StatusSeeOther)
+}
+
+// logout ends the session, so its cookie is no longer accepted even if it
+// was copied.
+func (a *API) logout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		a.mtx.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mtx.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, req, "/admin", http.StatusSeeOther)
+}
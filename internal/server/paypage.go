@@ -0,0 +1,268 @@
+package server
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/skip2/go-qrcode"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed templates/pay.html
+var templates embed.FS
+
+var payTemplate = template.Must(template.ParseFS(templates, "templates/pay.html"))
+
+// PayPageOptions describes what the pay page shows.
+type PayPageOptions struct {
+	Username    string
+	Domain      string
+	Description string
+	// AvatarFile is an image served with the page, so it doesn't depend on
+	// any other host.
+	AvatarFile string
+	Amounts    []int64 // preset amounts, in sats
+}
+
+// PayPage serves a page at /pay/{user} where visitors without a Lightning
+// Address aware wallet can pick an amount, scan an invoice QR code, and see
+// their payment confirmed live.
+type PayPage struct {
+	opts        PayPageOptions
+	issuer      app.InvoiceIssuer
+	maxSendable app.MaxSendableProvider
+	settings    *app.LiveSettings
+	events      *app.PaymentEvents
+}
+
+func NewPayPage(opts PayPageOptions, issuer app.InvoiceIssuer, maxSendable app.MaxSendableProvider, settings *app.LiveSettings, events *app.PaymentEvents) *PayPage {
+	return &PayPage{
+		opts:        opts,
+		issuer:      issuer,
+		maxSendable: maxSendable,
+		settings:    settings,
+		events:      events,
+	}
+}
+
+// payView is what the template renders.
+type payView struct {
+	Nonce          string
+	Identifier     string
+	Description    string
+	AvatarURL      string
+	Amounts        []int64
+	MinSat         int64
+	MaxSat         int64
+	CommentAllowed int64
+	Error          string
+
+	// Set once an invoice is issued.
+	Invoice   *app.IssuedInvoice
+	AmountSat int64
+	QRCode    template.HTML
+	WalletURL template.URL
+	EventsURL string
+}
+
+func (p *PayPage) view(r *http.Request) payView {
+	settings := p.settings.Get()
+	view := payView{
+		Identifier:     p.opts.Username + "@" + p.opts.Domain,
+		Description:    p.opts.Description,
+		Amounts:        p.opts.Amounts,
+		MinSat:         (settings.MinSendable + 999) / 1000,
+		MaxSat:         p.maxSendable.MaxSendable(r.Context()) / 1000,
+		CommentAllowed: settings.CommentAllowed,
+	}
+	if p.opts.AvatarFile != "" {
+		view.AvatarURL = "/pay/" + p.opts.Username + "/avatar"
+	}
+	return view
+}
+
+// HandlePage renders the amount form.
+func (p *PayPage) HandlePage(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("user") != p.opts.Username {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.render(w, http.StatusOK, p.view(r))
+}
+
+// HandleInvoice issues an invoice for the submitted form and renders it.
+func (p *PayPage) HandleInvoice(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("user") != p.opts.Username {
+		http.NotFound(w, r)
+		return
+	}
+
+	view := p.view(r)
+
+	// A custom amount takes precedence over the preset buttons.
+	amount, err := strconv.ParseInt(strings.TrimSpace(r.FormValue("custom")), 10, 64)
+	if err != nil {
+		amount, err = strconv.ParseInt(r.FormValue("amount"), 10, 64)
+	}
+	if err != nil || amount < view.MinSat || amount > view.MaxSat {
+		view.Error = fmt.Sprintf("Please choose an amount between %d and %d sats.", view.MinSat, view.MaxSat)
+		p.render(w, http.StatusBadRequest, view)
+		return
+	}
+
+	comment := strings.TrimSpace(r.FormValue("comment"))
+	if int64(utf8.RuneCountInString(comment)) > view.CommentAllowed {
+		view.Error = fmt.Sprintf("The comment can be at most %d characters.", view.CommentAllowed)
+		p.render(w, http.StatusBadRequest, view)
+		return
+	}
+
+	memo := "Payment to " + view.Identifier
+	if comment != "" {
+		memo += ": " + comment
+	}
+
+	invoice, err := p.issuer.Issue(r.Context(), app.InvoiceRequest{
+		Source:     app.InvoiceSource_PAYPAGE,
+		Username:   p.opts.Username,
+		AmountMsat: amount * 1000,
+		Comment:    comment,
+		Memo:       memo,
+	})
+	if errors.Is(err, app.ErrTooManyOutstanding) {
+		w.Header().Set("Retry-After", "60")
+		view.Error = "Too many unpaid invoices, please try again later."
+		p.render(w, http.StatusTooManyRequests, view)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to create invoice for the pay page", "error", err)
+		view.Error = "Could not create an invoice, please try again later."
+		p.render(w, http.StatusInternalServerError, view)
+		return
+	}
+
+	qr, err := qrSVG("lightning:" + invoice.PaymentRequest)
+	if err != nil {
+		slog.Error("Failed to render invoice QR code", "error", err)
+	}
+
+	view.Invoice = &invoice
+	view.AmountSat = amount
+	view.QRCode = qr
+	// html/template would otherwise reject the lightning: scheme.
+	view.WalletURL = template.URL("lightning:" + invoice.PaymentRequest)
+	view.EventsURL = "/pay/" + p.opts.Username + "/events/" + invoice.PaymentHash
+	p.render(w, http.StatusOK, view)
+}
+
+// HandleAvatar serves the avatar image.
+func (p *PayPage) HandleAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("user") != p.opts.Username || p.opts.AvatarFile == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeFile(w, r, p.opts.AvatarFile)
+}
+
+// HandleEvents streams a "settled" server-sent event once the invoice is paid.
+func (p *PayPage) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	payments, cancel := p.events.Subscribe(hash)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case payment := <-payments:
+			data, _ := json.Marshal(map[string]interface{}{
+				"amount_paid_msat": payment.AmountPaidMsat,
+				"settled_at":       payment.SettledAt,
+			})
+			fmt.Fprintf(w, "event: settled\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// render writes the page with a content security policy that only allows
+// its own inline script and style, so it can't load anything from elsewhere.
+// It may be framed by any site.
+func (p *PayPage) render(w http.ResponseWriter, status int, view payView) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	view.Nonce = base64.StdEncoding.EncodeToString(nonce)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(
+		"default-src 'none'; img-src 'self' data:; style-src 'nonce-%[1]s'; script-src 'nonce-%[1]s'; connect-src 'self'; form-action 'self'",
+		view.Nonce))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := payTemplate.Execute(w, view); err != nil {
+		slog.Error("Failed to render pay page", "error", err)
+	}
+}
+
+// qrSVG renders content as an inline SVG QR code.
+func qrSVG(content string) (template.HTML, error) {
+	// Uppercase invoices fit the denser alphanumeric QR mode.
+	qr, err := qrcode.New(strings.ToUpper(content), qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+
+	bitmap := qr.Bitmap()
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	return template.HTML(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %[1]d %[1]d" shape-rendering="crispEdges" role="img" aria-label="Invoice QR code"><rect width="100%%" height="100%%" fill="#fff"/><path d="%[2]s" fill="#000"/></svg>`,
+		len(bitmap), path.String())), nil
+}
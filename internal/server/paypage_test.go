@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/pkg/lndrest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPaymentHash = "0101010101010101010101010101010101010101010101010101010101010101"
+
+func newTestPayPage(t *testing.T) (*PayPage, *app.PaymentEvents, *int64) {
+	var createdMsat int64
+	lnd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			ValueMsat int64 `json:"value_msat"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		createdMsat = params.ValueMsat
+
+		json.NewEncoder(w).Encode(lndrest.CreateInvoiceResponse{
+			RHash:          []byte(strings.Repeat("\x01", 32)),
+			PaymentRequest: "lnbc21u1ptest",
+		})
+	}))
+	t.Cleanup(lnd.Close)
+
+	client, err := lndrest.NewClient(lnd.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	events := app.NewPaymentEvents()
+	issuer := app.NewInvoiceIssuer(client, nil, app.ZapMonitor{}, app.NewPaymentTracker(nil, nil, nil), "", 0, 0)
+	settings := app.NewLiveSettings(app.Settings{MinSendable: 1000, MaxSendable: 100000000, CommentAllowed: 10})
+	page := NewPayPage(PayPageOptions{
+		Username:    "satoshi",
+		Domain:      "example.com",
+		Description: "Tips welcome",
+		Amounts:     []int64{1000, 5000},
+	}, issuer, settings, settings, events)
+	return page, events, &createdMsat
+}
+
+func TestPayPageForm(t *testing.T) {
+	page, _, _ := newTestPayPage(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/pay/satoshi", nil)
+	r.SetPathValue("user", "satoshi")
+	w := httptest.NewRecorder()
+	page.HandlePage(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "satoshi@example.com")
+	assert.Contains(t, w.Body.String(), "Tips welcome")
+	assert.Contains(t, w.Body.String(), `value="5000"`)
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "default-src 'none'")
+
+	r = httptest.NewRequest(http.MethodGet, "/pay/hal", nil)
+	r.SetPathValue("user", "hal")
+	w = httptest.NewRecorder()
+	page.HandlePage(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPayPageInvoice(t *testing.T) {
+	page, _, createdMsat := newTestPayPage(t)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/pay/satoshi", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetPathValue("user", "satoshi")
+		w := httptest.NewRecorder()
+		page.HandleInvoice(w, r)
+		return w
+	}
+
+	w := post(url.Values{"amount": {"1000"}, "custom": {"2100"}, "comment": {"thanks"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2100000), *createdMsat, "the custom amount wins over the preset")
+	assert.Contains(t, w.Body.String(), "<svg")
+	assert.Contains(t, w.Body.String(), `href="lightning:lnbc21u1ptest"`)
+	assert.Contains(t, w.Body.String(), "/pay/satoshi/events/"+testPaymentHash)
+
+	w = post(url.Values{"amount": {"1000"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1000000), *createdMsat)
+
+	w = post(url.Values{"custom": {"0"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "between 1 and 100000 sats")
+
+	w = post(url.Values{"amount": {"1000"}, "comment": {"much too long"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPayPageEvents(t *testing.T) {
+	page, events, _ := newTestPayPage(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pay/{user}/events/{hash}", page.HandleEvents)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/pay/satoshi/events/" + testPaymentHash)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events.OnPaymentSettled(app.SettledPayment{
+		IssuedInvoice:  app.IssuedInvoice{PaymentHash: testPaymentHash},
+		AmountPaidMsat: 2100000,
+		SettledAt:      time.Now(),
+	})
+
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+	assert.Equal(t, "event: settled", scanner.Text())
+	require.True(t, scanner.Scan())
+	assert.Contains(t, scanner.Text(), `"amount_paid_msat":2100000`)
+
+	resp, err = http.Get(srv.URL + "/pay/satoshi/events/nothex")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestQRSVG(t *testing.T) {
+	svg, err := qrSVG("lightning:lnbc21u1ptest")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(svg), "<svg"))
+	assert.Contains(t, string(svg), `<path d="M`)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/pkg/lnurl"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucketIdleTimeout is how long an unused bucket is kept. A full bucket is
+// the same as no bucket, so only idle ones are dropped.
+const bucketIdleTimeout = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// tokenBuckets rate limits by key. Each key may do burst requests at once,
+// refilled at rate per second.
+type tokenBuckets struct {
+	rate  float64
+	burst float64
+
+	mtx       sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newTokenBuckets(perMinute float64, burst int) *tokenBuckets {
+	return &tokenBuckets{
+		rate:    perMinute / 60,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// take takes a token for key. If none is left, it returns how long until there is one.
+func (tb *tokenBuckets) take(key string, now time.Time) (bool, time.Duration) {
+	tb.mtx.Lock()
+	defer tb.mtx.Unlock()
+
+	if now.Sub(tb.lastSweep) > bucketIdleTimeout {
+		for k, b := range tb.buckets {
+			if now.Sub(b.last) > bucketIdleTimeout {
+				delete(tb.buckets, k)
+			}
+		}
+		tb.lastSweep = now
+	}
+
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.burst, last: now}
+		tb.buckets[key] = b
+	}
+
+	b.tokens = math.Min(tb.burst, b.tokens+now.Sub(b.last).Seconds()*tb.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / tb.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// CallbackLimiter rate limits LNURL callbacks, which each create an invoice
+// on the node, per client IP and per user. Callbacks for other users than
+// username are rejected before they are counted. Callbacks through the onion
+// service have no client IP and are only limited per user.
+type CallbackLimiter struct {
+	username       string
+	perIP          *tokenBuckets
+	perUser        *tokenBuckets
+	trustedProxies []*net.IPNet
+}
+
+// NewCallbackLimiter creates a limiter. Rates are per minute; a rate of 0
+// disables that limit. X-Forwarded-For is only honored from trustedProxies,
+// given as IPs or CIDRs.
+func NewCallbackLimiter(username string, ipRate float64, ipBurst int, userRate float64, userBurst int, trustedProxies []string) (*CallbackLimiter, error) {
+	l := &CallbackLimiter{username: username}
+	if ipRate > 0 {
+		l.perIP = newTokenBuckets(ipRate, ipBurst)
+	}
+	if userRate > 0 {
+		l.perUser = newTokenBuckets(userRate, userBurst)
+	}
+
+	for _, list := range trustedProxies {
+		for _, s := range strings.Split(list, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+					s += "/32"
+				} else {
+					s += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+			l.trustedProxies = append(l.trustedProxies, ipNet)
+		}
+	}
+
+	return l, nil
+}
+
+func (l *CallbackLimiter) isTrusted(ip net.IP) bool {
+	for _, ipNet := range l.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client. Behind trusted proxies it is the
+// rightmost X-Forwarded-For entry that is not a trusted proxy itself.
+func (l *CallbackLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !l.isTrusted(ip) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !l.isTrusted(hop) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+// ipKey returns the key ip is limited by. IPv6 clients usually get a whole
+// /64, so they are limited by it rather than by single addresses.
+func ipKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	prefix := net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+	return prefix.String()
+}
+
+// Wrap limits requests to next.
+func (l *CallbackLimiter) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+
+		// Made up usernames would otherwise each get a bucket.
+		if r.PathValue("user") != l.username {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(lnurl.ErrorResponse{
+				Status: "ERROR",
+				Reason: "User not found",
+			})
+			return
+		}
+
+		// Onion clients can't be told apart; only the per-user limit applies.
+		if l.perIP != nil && !viaOnion(r) {
+			ip := l.clientIP(r)
+			if ok, wait := l.perIP.take(ipKey(ip), now); !ok {
+				slog.Warn("Rate limited LNURL callback", "ip", ip)
+				writeRateLimited(w, wait, "Too many requests, please try again later")
+				return
+			}
+		}
+
+		if l.perUser != nil {
+			user := r.PathValue("user")
+			if ok, wait := l.perUser.take(user, now); !ok {
+				slog.Warn("Rate limited LNURL callback", "user", user)
+				writeRateLimited(w, wait, "Too many payment requests for this user, please try again later")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// writeRateLimited answers with 429 and a LUD-06 error.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(lnurl.ErrorResponse{
+		Status: "ERROR",
+		Reason: reason,
+	})
+}
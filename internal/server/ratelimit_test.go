@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	tb := newTokenBuckets(60, 2) // one per second
+	now := time.Now()
+
+	ok, _ := tb.take("a", now)
+	assert.True(t, ok)
+	ok, _ = tb.take("a", now)
+	assert.True(t, ok)
+
+	ok, wait := tb.take("a", now)
+	assert.False(t, ok)
+	assert.InDelta(t, time.Second, wait, float64(time.Millisecond))
+
+	ok, _ = tb.take("b", now)
+	assert.True(t, ok, "keys are limited separately")
+
+	ok, _ = tb.take("a", now.Add(time.Second))
+	assert.True(t, ok, "a token is refilled after a second")
+}
+
+func TestClientIP(t *testing.T) {
+	limiter, err := NewCallbackLimiter("alice", 1, 1, 0, 0, []string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	request := func(remoteAddr string, forwardedFor ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for _, v := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		return r
+	}
+
+	assert.Equal(t, "203.0.113.5", limiter.clientIP(request("203.0.113.5:1234", "1.2.3.4")), "untrusted peers can't spoof")
+	assert.Equal(t, "1.2.3.4", limiter.clientIP(request("10.1.2.3:1234", "1.2.3.4")))
+	assert.Equal(t, "1.2.3.4", limiter.clientIP(request("10.1.2.3:1234", "6.6.6.6, 1.2.3.4", "192.168.1.1")), "trusted hops are skipped")
+	assert.Equal(t, "10.1.2.3", limiter.clientIP(request("10.1.2.3:1234")))
+
+	_, err = NewCallbackLimiter("alice", 1, 1, 0, 0, []string{"not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestCallbackLimiter(t *testing.T) {
+	limiter, err := NewCallbackLimiter("alice", 60, 1, 0, 0, nil)
+	require.NoError(t, err)
+
+	handler := limiter.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/alice/callback?amount=1000", nil)
+		r.SetPathValue("user", "alice")
+		r.RemoteAddr = "203.0.113.5:1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve().Code)
+
+	limited := serve()
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "1", limited.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":"ERROR","reason":"Too many requests, please try again later"}`, limited.Body.String())
+}
+
+func TestCallbackLimiterIPv6(t *testing.T) {
+	limiter, err := NewCallbackLimiter("alice", 60, 1, 0, 0, nil)
+	require.NoError(t, err)
+
+	handler := limiter.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/alice/callback?amount=1000", nil)
+		r.SetPathValue("user", "alice")
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("[2001:db8:1:2::1]:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("[2001:db8:1:2:ffff::2]:1234"), "same /64")
+	assert.Equal(t, http.StatusOK, serve("[2001:db8:1:3::1]:1234"), "other /64")
+}
+
+func TestCallbackLimiterUnknownUser(t *testing.T) {
+	limiter, err := NewCallbackLimiter("alice", 60, 1, 60, 1, nil)
+	require.NoError(t, err)
+
+	called := false
+	handler := limiter.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	for _, user := range []string{"bob", "carol", "dave"} {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/"+user+"/callback?amount=1000", nil)
+		r.SetPathValue("user", user)
+		r.RemoteAddr = "203.0.113.5:1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"status":"ERROR","reason":"User not found"}`, w.Body.String())
+	}
+
+	assert.False(t, called)
+	assert.Empty(t, limiter.perIP.buckets)
+	assert.Empty(t, limiter.perUser.buckets)
+}
+
+func TestCallbackLimiterOnion(t *testing.T) {
+	limiter, err := NewCallbackLimiter("alice", 60, 1, 60, 3, nil)
+	require.NoError(t, err)
+
+	handler := limiter.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/lnurlp/alice/callback?amount=1000", nil)
+		r = r.WithContext(context.WithValue(r.Context(), onionConnKey{}, true))
+		r.SetPathValue("user", "alice")
+		// Every onion client reaches lmt through the local tor daemon.
+		r.RemoteAddr = "127.0.0.1:1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusOK, serve(), "onion clients don't share a per-IP bucket")
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusTooManyRequests, serve(), "the per-user limit still applies")
+	assert.Empty(t, limiter.perIP.buckets)
+}
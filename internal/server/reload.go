@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// Reloader re-reads the config file and applies the settings that can
+// change while lmt runs.
+type Reloader interface {
+	Reload() (ReloadResult, error)
+}
+
+// ReloadResult lists the config keys a reload changed. Keys in
+// RestartRequired differ from the running config but only take effect after
+// a restart.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// reload re-reads the config file. Nothing is applied when it's invalid.
+func (a *API) reload(w http.ResponseWriter, req *http.Request) {
+	result, err := a.reloader.Reload()
+	if err != nil {
+		slog.Error("Failed to reload config", "error", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
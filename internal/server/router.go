@@ -1,22 +1,31 @@
 package server
 
 import (
+	"context"
 	"github.com/asheswook/lightning-multitool/internal/app"
+	"github.com/asheswook/lightning-multitool/internal/metrics"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Router struct {
 	lnurlInvoiceHandler app.LNURLInvoiceHandler
 	lnurlHandler        app.LNURLHandler
 	nostrHandler        app.NostrHandler
+	callbackLimiter     *CallbackLimiter
+	payPage             *PayPage
 }
 
-func NewRouter(lnurlInvoiceHandler app.LNURLInvoiceHandler, lnurlHandler app.LNURLHandler, nostrHandler app.NostrHandler) Router {
+func NewRouter(lnurlInvoiceHandler app.LNURLInvoiceHandler, lnurlHandler app.LNURLHandler, nostrHandler app.NostrHandler, callbackLimiter *CallbackLimiter, payPage *PayPage) Router {
 	return Router{
 		lnurlInvoiceHandler: lnurlInvoiceHandler,
 		lnurlHandler:        lnurlHandler,
 		nostrHandler:        nostrHandler,
+		callbackLimiter:     callbackLimiter,
+		payPage:             payPage,
 	}
 }
 
@@ -37,14 +46,67 @@ func withCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// withMetrics counts requests to route by status and records their duration.
+func withMetrics(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(sr, r)
+
+		metrics.LNURLRequests.WithLabelValues(route, strconv.Itoa(sr.status)).Inc()
+		metrics.LNURLRequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	}
+}
+
 func (r Router) ServeMux() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/.well-known/lnurlp/{user}", withCORS(r.lnurlHandler.Handle))
-	mux.HandleFunc("/.well-known/nostr.json", withCORS(r.nostrHandler.Handle))
-	mux.HandleFunc("/.well-known/lnurlp/{user}/callback", withCORS(r.lnurlInvoiceHandler.Handle))
+	mux.HandleFunc("/.well-known/lnurlp/{user}", withMetrics("lnurlp", withCORS(r.lnurlHandler.Handle)))
+	mux.HandleFunc("/.well-known/nostr.json", withMetrics("nostr.json", withCORS(r.nostrHandler.Handle)))
+	mux.HandleFunc("/.well-known/lnurlp/{user}/callback", withMetrics("callback", withCORS(r.callbackLimiter.Wrap(r.lnurlInvoiceHandler.Handle))))
+
+	if r.payPage != nil {
+		mux.HandleFunc("GET /pay/{user}", withMetrics("pay", r.payPage.HandlePage))
+		mux.HandleFunc("POST /pay/{user}", withMetrics("pay", r.callbackLimiter.Wrap(r.payPage.HandleInvoice)))
+		mux.HandleFunc("GET /pay/{user}/avatar", r.payPage.HandleAvatar)
+		mux.HandleFunc("GET /pay/{user}/events/{hash}", r.payPage.HandleEvents)
+	}
 	return mux
 }
 
+// onionConnKey marks the context of requests that came through the onion service.
+type onionConnKey struct{}
+
+// viaOnion reports whether r came through the onion service. Those requests
+// all come from the local tor daemon, so their address says nothing about the client.
+func viaOnion(r *http.Request) bool {
+	onion, _ := r.Context().Value(onionConnKey{}).(bool)
+	return onion
+}
+
+// ServeOnion serves the router over plain HTTP on the local target of an
+// onion service.
+func (r Router) ServeOnion(ln net.Listener) error {
+	srv := &http.Server{
+		Handler: r.ServeMux(),
+		ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, onionConnKey{}, true)
+		},
+	}
+	return srv.Serve(ln)
+}
+
 func (r Router) ListenAndServe(addr string) error {
 	slog.Info("Listening on", "addr", addr)
 	return http.ListenAndServe(addr, r.ServeMux())
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/asheswook/lightning-multitool/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithMetrics(t *testing.T) {
+	count := func(route, status string) float64 {
+		return testutil.ToFloat64(metrics.LNURLRequests.WithLabelValues(route, status))
+	}
+	ok, tooMany := count("test", "200"), count("test", "429")
+
+	handler := withMetrics("test", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("limited") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	for _, target := range []string{"/", "/", "/?limited"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	// Handlers that never call WriteHeader are counted as 200.
+	assert.Equal(t, ok+2, count("test", "200"))
+	assert.Equal(t, tooMany+1, count("test", "429"))
+}
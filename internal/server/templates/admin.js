@@ -0,0 +1,166 @@
+"use strict";
+
+// The lmt admin dashboard. Everything shown here comes from the JSON admin
+// API, so anything the dashboard does can also be scripted against it.
+
+function api(method, path, body) {
+  var opts = { method: method, credentials: "same-origin", headers: { "X-Requested-With": "lmt-dashboard" } };
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  return fetch(path, opts).then(function (resp) {
+    if (resp.status === 401) {
+      showLogin();
+      throw new Error("unauthorized");
+    }
+    if (!resp.ok) {
+      return resp.text().then(function (text) { throw new Error(text.trim() || resp.statusText); });
+    }
+    return resp.status === 204 ? null : resp.json();
+  });
+}
+
+function el(tag, attrs, children) {
+  var node = document.createElement(tag);
+  Object.keys(attrs || {}).forEach(function (key) { node.setAttribute(key, attrs[key]); });
+  (children || []).forEach(function (child) {
+    node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+  });
+  return node;
+}
+
+function row(cells) {
+  return el("tr", {}, cells.map(function (cell) {
+    if (cell instanceof Node) return el("td", {}, [cell]);
+    if (cell && typeof cell === "object") return el("td", { class: cell.cls }, [String(cell.text)]);
+    return el("td", {}, [cell == null ? "" : String(cell)]);
+  }));
+}
+
+function fill(id, rows, empty) {
+  var body = document.getElementById(id);
+  body.replaceChildren.apply(body, rows.length ? rows : [el("tr", {}, [el("td", { colspan: "7", class: "muted" }, [empty])])]);
+}
+
+function badge(text) {
+  return el("span", { class: "badge " + text }, [text]);
+}
+
+function sats(msat) {
+  return { cls: "num", text: Math.floor(msat / 1000).toLocaleString() };
+}
+
+function time(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function shorten(s, n) {
+  return s && s.length > n ? s.slice(0, n - 1) + "…" : (s || "");
+}
+
+function showLogin() {
+  document.getElementById("dashboard").hidden = true;
+  document.getElementById("login").hidden = false;
+  document.getElementById("login-error").hidden = new URLSearchParams(location.search).get("error") !== "login";
+}
+
+function loadHealth() {
+  return api("GET", "/readyz").then(function (report) {
+    var box = document.getElementById("health");
+    var names = Object.keys(report.components || {}).sort();
+    box.replaceChildren.apply(box, names.map(function (name) {
+      var c = report.components[name];
+      return el("div", {}, [el("strong", {}, [name + " "]), badge(c.status), el("small", {}, [c.message || ""])]);
+    }));
+  });
+}
+
+function loadDaily() {
+  return api("GET", "/api/stats/daily?days=30").then(function (days) {
+    var max = Math.max.apply(null, days.map(function (d) { return d.amount_msat; }).concat([1]));
+    var chart = document.getElementById("chart");
+    chart.replaceChildren.apply(chart, days.map(function (d) {
+      var bar = el("div", { title: d.date + ": " + Math.floor(d.amount_msat / 1000).toLocaleString() + " sats" });
+      bar.style.height = (100 * d.amount_msat / max) + "%";
+      return bar;
+    }));
+
+    fill("daily", days.slice().reverse().filter(function (d) { return d.payments > 0; }).map(function (d) {
+      return row([d.date, { cls: "num", text: d.payments }, sats(d.amount_msat), { cls: "num", text: d.zaps }, sats(d.zap_amount_msat)]);
+    }), "Nothing received in the last 30 days.");
+  });
+}
+
+function loadInvoices() {
+  var invoices = api("GET", "/api/invoices?limit=20").then(function (page) {
+    fill("invoices", page.invoices.map(function (inv) {
+      return row([time(inv.created_at), badge(inv.status), sats(inv.amount_paid_msat || inv.amount_msat), inv.source, { cls: "wrap", text: shorten(inv.comment, 60) }]);
+    }), "No invoices yet.");
+  });
+  var zaps = api("GET", "/api/invoices?zaps=true&limit=20").then(function (page) {
+    fill("zaps", page.invoices.map(function (inv) {
+      return row([time(inv.created_at), badge(inv.status), sats(inv.amount_paid_msat || inv.amount_msat), shorten(inv.zap_sender, 16), { cls: "wrap", text: shorten(inv.comment, 60) }]);
+    }), "No zaps yet.");
+  });
+  return Promise.all([invoices, zaps]);
+}
+
+function loadEndpoints() {
+  return api("GET", "/api/webhooks/endpoints").then(function (endpoints) {
+    fill("endpoints", endpoints.map(function (ep) {
+      var action = ep.configured ? el("span", { class: "muted" }, ["lmt.conf"]) : el("button", { type: "button" }, ["Remove"]);
+      if (!ep.configured) {
+        action.addEventListener("click", function () {
+          if (!confirm("Remove " + ep.url + "?")) return;
+          api("DELETE", "/api/webhooks/endpoints/" + ep.id).then(loadEndpoints).catch(alertError);
+        });
+      }
+      return row([{ cls: "wrap", text: ep.url }, (ep.events || []).join(", ") || "payment.received", action]);
+    }), "No webhook endpoints.");
+  });
+}
+
+function loadDeliveries() {
+  return api("GET", "/api/webhooks/deliveries?limit=20").then(function (deliveries) {
+    fill("deliveries", deliveries.map(function (d) {
+      var replay = el("button", { type: "button" }, ["Replay"]);
+      replay.addEventListener("click", function () {
+        api("POST", "/api/webhooks/deliveries/" + d.id + "/replay").then(loadDeliveries).catch(alertError);
+      });
+      return row([time(d.created_at), { cls: "wrap", text: d.url }, d.event.type, badge(d.status), { cls: "num", text: d.attempts }, { cls: "wrap", text: d.last_error || "" }, replay]);
+    }), "No deliveries yet.");
+  });
+}
+
+function alertError(err) {
+  if (err.message !== "unauthorized") alert(err.message);
+}
+
+function load() {
+  return Promise.all([loadHealth(), loadDaily(), loadInvoices(), loadEndpoints(), loadDeliveries()]).then(function () {
+    document.getElementById("login").hidden = true;
+    document.getElementById("dashboard").hidden = false;
+  });
+}
+
+document.addEventListener("DOMContentLoaded", function () {
+  document.getElementById("add-endpoint").addEventListener("submit", function (event) {
+    event.preventDefault();
+    var form = event.target;
+    var events = Array.prototype.filter.call(form.elements.events, function (box) { return box.checked; })
+      .map(function (box) { return box.value; });
+    var errorBox = document.getElementById("endpoint-error");
+    errorBox.textContent = "";
+    api("POST", "/api/webhooks/endpoints", { url: form.elements.url.value, secret: form.elements.secret.value, events: events })
+      .then(function (ep) {
+        if (ep.secret) prompt("Signing secret for " + ep.url + ". It is not shown again.", ep.secret);
+        form.reset();
+        return loadEndpoints();
+      })
+      .catch(function (err) { errorBox.textContent = err.message; });
+  });
+
+  load().catch(function (err) { if (err.message !== "unauthorized") console.error(err); });
+  setInterval(function () { if (!document.hidden && !document.getElementById("dashboard").hidden) load().catch(function () {}); }, 30000);
+});
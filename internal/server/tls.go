@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TLSOptions configures HTTPS for the standalone server: either a static
+// certificate, or certificates from an ACME CA such as Let's Encrypt.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+
+	ACME             bool
+	ACMEEmail        string
+	ACMEDirectoryURL string // empty for Let's Encrypt
+	ACMECacheDir     string
+	Domain           string
+
+	// RedirectAddr is where plain HTTP is redirected to HTTPS, and where ACME
+	// HTTP-01 challenges are answered. Empty disables it.
+	RedirectAddr string
+}
+
+// TLS serves the router over HTTPS.
+type TLS struct {
+	config       *tls.Config
+	redirect     http.Handler
+	redirectAddr string
+}
+
+// NewTLS loads the certificate or sets up ACME certificate management.
+func NewTLS(opts TLSOptions) (*TLS, error) {
+	t := &TLS{redirectAddr: opts.RedirectAddr}
+	redirect := http.HandlerFunc(redirectToHTTPS)
+
+	switch {
+	case opts.ACME:
+		if opts.Domain == "" {
+			return nil, fmt.Errorf("ACME needs a domain")
+		}
+
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(opts.ACMECacheDir),
+			HostPolicy: autocert.HostWhitelist(opts.Domain),
+			Email:      opts.ACMEEmail,
+		}
+		if opts.ACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: opts.ACMEDirectoryURL}
+		}
+
+		// TLSConfig answers TLS-ALPN-01 challenges on the HTTPS listener,
+		// HTTPHandler answers HTTP-01 challenges on the redirect listener.
+		t.config = manager.TLSConfig()
+		t.redirect = manager.HTTPHandler(redirect)
+	case opts.CertFile != "" && opts.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		t.config = &tls.Config{Certificates: []tls.Certificate{cert}}
+		t.redirect = redirect
+	default:
+		return nil, fmt.Errorf("TLS needs either a certificate and key file, or ACME")
+	}
+
+	t.config.MinVersion = tls.VersionTLS12
+	return t, nil
+}
+
+// redirectToHTTPS redirects to the same URL over HTTPS on the default port.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// ListenAndServeTLS serves the router over HTTPS on addr, and the redirect
+// listener if one is configured. It returns when either fails.
+func (r Router) ListenAndServeTLS(addr string, t *TLS) error {
+	errCh := make(chan error, 2)
+
+	if t.redirectAddr != "" {
+		go func() {
+			slog.Info("Redirecting HTTP to HTTPS", "addr", t.redirectAddr)
+			errCh <- http.ListenAndServe(t.redirectAddr, t.redirect)
+		}()
+	}
+
+	go func() {
+		slog.Info("Listening with TLS on", "addr", addr)
+		srv := &http.Server{
+			Addr:      addr,
+			Handler:   r.ServeMux(),
+			TLSConfig: t.config,
+		}
+		errCh <- srv.ListenAndServeTLS("", "")
+	}()
+
+	return <-errCh
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a certificate for host and its key to dir.
+func writeSelfSignedCert(t *testing.T, dir, host string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestNewTLSStaticCertificate(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t, t.TempDir(), "example.com")
+
+	tlsServer, err := NewTLS(TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = tlsServer.config
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	certPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	require.True(t, pool.AppendCertsFromPEM(certPEM))
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "example.com"}}}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestNewTLSErrors(t *testing.T) {
+	_, err := NewTLS(TLSOptions{})
+	assert.Error(t, err, "neither a certificate nor ACME")
+
+	_, err = NewTLS(TLSOptions{CertFile: "missing.pem", KeyFile: "missing.key"})
+	assert.Error(t, err)
+
+	_, err = NewTLS(TLSOptions{ACME: true, ACMECacheDir: t.TempDir()})
+	assert.Error(t, err, "ACME without a domain")
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		host, target, want string
+	}{
+		{"example.com", "/.well-known/lnurlp/satoshi", "https://example.com/.well-known/lnurlp/satoshi"},
+		{"example.com:80", "/.well-known/nostr.json?name=satoshi", "https://example.com/.well-known/nostr.json?name=satoshi"},
+		{"[::1]:80", "/", "https://[::1]/"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+
+		redirectToHTTPS(w, r)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, tt.want, w.Header().Get("Location"))
+	}
+}
+
+func TestACMERedirectAnswersChallenges(t *testing.T) {
+	tlsServer, err := NewTLS(TLSOptions{ACME: true, Domain: "example.com", ACMECacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/somewhere", nil)
+	r.Host = "example.com"
+	w := httptest.NewRecorder()
+	tlsServer.redirect.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+
+	// Unknown challenge tokens are answered by autocert, not redirected.
+	r = httptest.NewRequest(http.MethodGet, "/.well-known/acme-challenge/unknown", nil)
+	r.Host = "example.com"
+	w = httptest.NewRecorder()
+	tlsServer.redirect.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Contains(t, tlsServer.config.NextProtos, "acme-tls/1")
+}
+
+// TestACMEPebble obtains a certificate from a local Pebble ACME server. Run
+// Pebble with PEBBLE_VA_ALWAYS_VALID=1, trust its certificate with
+// SSL_CERT_FILE, and set LMT_TEST_ACME_DIRECTORY, e.g.
+// https://localhost:14000/dir
+func TestACMEPebble(t *testing.T) {
+	directoryURL := os.Getenv("LMT_TEST_ACME_DIRECTORY")
+	if directoryURL == "" {
+		t.Skip("LMT_TEST_ACME_DIRECTORY not set")
+	}
+
+	cacheDir := t.TempDir()
+	tlsServer, err := NewTLS(TLSOptions{
+		ACME:             true,
+		ACMEDirectoryURL: directoryURL,
+		ACMECacheDir:     cacheDir,
+		Domain:           "lmt.test",
+	})
+	require.NoError(t, err)
+
+	cert, err := tlsServer.config.GetCertificate(&tls.ClientHelloInfo{
+		ServerName:        "lmt.test",
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		SupportedCurves:   []tls.CurveID{tls.CurveP256},
+		CipherSuites:      []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		SupportedVersions: []uint16{tls.VersionTLS12, tls.VersionTLS13},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, cert.Leaf)
+	assert.Contains(t, cert.Leaf.DNSNames, "lmt.test")
+
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries, "the certificate is cached on disk")
+}
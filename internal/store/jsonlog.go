@@ -0,0 +1,143 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// JSONLog is an append-only file of JSON records, one per line.
+// Records are usually keyed; readers replay the log and let later records
+// replace earlier ones, and Rewrite compacts the file to the latest state.
+type JSONLog struct {
+	path string
+
+	mtx      sync.Mutex
+	file     *os.File
+	readOnly bool
+}
+
+// OpenJSONLog opens the log at path, creating it and its directory if needed.
+func OpenJSONLog(path string) (*JSONLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	return &JSONLog{path: path, file: file}, nil
+}
+
+// OpenJSONLogReadOnly opens the existing log at path for Replay only, e.g.
+// to read it while lmt is running. Append and Rewrite fail.
+func OpenJSONLogReadOnly(path string) (*JSONLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	return &JSONLog{path: path, file: file, readOnly: true}, nil
+}
+
+// Replay calls fn with every record in the log, oldest first.
+// Lines that can't be read, e.g. a torn final write, are skipped.
+func (l *JSONLog) Replay(fn func(record []byte) error) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Append writes a record to the end of the log.
+func (l *JSONLog) Append(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Rewrite atomically replaces the log with the given records.
+func (l *JSONLog) Rewrite(records []interface{}) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.readOnly {
+		return fmt.Errorf("%s is opened read-only", l.path)
+	}
+
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", l.path, err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen %s: %w", l.path, err)
+	}
+	l.file.Close()
+	l.file = file
+	return nil
+}
+
+// Close closes the log file.
+func (l *JSONLog) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,205 @@
+// Package tor publishes onion services through a tor daemon's control port.
+package tor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Controller is an authenticated connection to a tor control port. Onion
+// services added through it are removed by tor when it is closed.
+type Controller struct {
+	conn *textproto.Conn
+}
+
+// Dial connects to the control port at addr and authenticates, with the
+// password if one is given, otherwise with the cookie file or no auth,
+// whichever tor offers.
+func Dial(addr, password string) (*Controller, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tor control port: %w", err)
+	}
+
+	c := &Controller{conn: textproto.NewConn(conn)}
+	if err := c.authenticate(password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// command sends a command and returns the lines of its 250 reply, without
+// the trailing OK.
+func (c *Controller) command(format string, args ...interface{}) ([]string, error) {
+	if err := c.conn.PrintfLine(format, args...); err != nil {
+		return nil, err
+	}
+
+	_, msg, err := c.conn.ReadResponse(250)
+	if err != nil {
+		return nil, fmt.Errorf("tor: %w", err)
+	}
+
+	lines := strings.Split(msg, "\n")
+	if lines[len(lines)-1] == "OK" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+func (c *Controller) authenticate(password string) error {
+	if password != "" {
+		if _, err := c.command("AUTHENTICATE %s", strconv.Quote(password)); err != nil {
+			return fmt.Errorf("tor control port authentication failed: %w", err)
+		}
+		return nil
+	}
+
+	lines, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+
+	var methods []string
+	var cookieFile string
+	for _, line := range lines {
+		rest, ok := strings.CutPrefix(line, "AUTH ")
+		if !ok {
+			continue
+		}
+		for _, field := range splitQuoted(rest) {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "METHODS":
+				methods = strings.Split(value, ",")
+			case "COOKIEFILE":
+				cookieFile, _ = strconv.Unquote(value)
+			}
+		}
+	}
+
+	switch {
+	case slices.Contains(methods, "NULL"):
+		_, err = c.command("AUTHENTICATE")
+	case slices.Contains(methods, "COOKIE") && cookieFile != "":
+		var cookie []byte
+		if cookie, err = os.ReadFile(cookieFile); err != nil {
+			return fmt.Errorf("failed to read tor auth cookie: %w", err)
+		}
+		_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(cookie))
+	default:
+		return fmt.Errorf("tor control port needs a password (offered auth methods: %s)", strings.Join(methods, ","))
+	}
+
+	if err != nil {
+		return fmt.Errorf("tor control port authentication failed: %w", err)
+	}
+	return nil
+}
+
+// AddOnion publishes an onion service forwarding virtPort to target. key is
+// a private key returned by an earlier AddOnion, or empty for a new v3
+// service. It returns the service ID (the address without .onion) and the
+// private key.
+func (c *Controller) AddOnion(key string, virtPort int, target string) (serviceID, privateKey string, err error) {
+	keyArg, flags := "NEW:ED25519-V3", ""
+	if key != "" {
+		keyArg, flags = key, " Flags=DiscardPK"
+	}
+
+	lines, err := c.command("ADD_ONION %s%s Port=%d,%s", keyArg, flags, virtPort, target)
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKey = key
+	for _, line := range lines {
+		k, v, _ := strings.Cut(line, "=")
+		switch k {
+		case "ServiceID":
+			serviceID = v
+		case "PrivateKey":
+			privateKey = v
+		}
+	}
+
+	if serviceID == "" {
+		return "", "", fmt.Errorf("tor did not return an onion service ID")
+	}
+	return serviceID, privateKey, nil
+}
+
+// Wait blocks until the control connection is lost, e.g. because tor was
+// restarted, which also removes its onion services. No commands may be sent
+// while waiting.
+func (c *Controller) Wait() error {
+	for {
+		if _, err := c.conn.ReadLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the control connection, which removes its onion services.
+func (c *Controller) Close() error {
+	return c.conn.Close()
+}
+
+// PublishOnion publishes an onion service with the key stored in keyFile,
+// creating and storing a new key the first time. It returns the onion
+// address, which stays the same as long as keyFile is kept.
+func (c *Controller) PublishOnion(keyFile string, virtPort int, target string) (string, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read onion key: %w", err)
+	}
+
+	serviceID, privateKey, err := c.AddOnion(strings.TrimSpace(string(key)), virtPort, target)
+	if err != nil {
+		return "", err
+	}
+
+	if len(key) == 0 {
+		if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(keyFile, []byte(privateKey+"\n"), 0600); err != nil {
+			return "", fmt.Errorf("failed to save onion key: %w", err)
+		}
+	}
+
+	return serviceID + ".onion", nil
+}
+
+// splitQuoted splits s on spaces outside of double quotes.
+func splitQuoted(s string) []string {
+	var fields []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ' ' && !quoted:
+			if i > start {
+				fields = append(fields, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		fields = append(fields, s[start:])
+	}
+	return fields
+}
@@ -0,0 +1,160 @@
+package tor
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTor answers control port commands with the reply returned by respond,
+// and records the commands it received.
+func fakeTor(t *testing.T, respond func(cmd string) string) (addr string, commands *[]string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	commands = &[]string{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					*commands = append(*commands, scanner.Text())
+					fmt.Fprint(conn, respond(scanner.Text()))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestPublishOnionWithCookieAuth(t *testing.T) {
+	dir := t.TempDir()
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	require.NoError(t, os.WriteFile(cookieFile, []byte{0xde, 0xad, 0xbe, 0xef}, 0600))
+
+	addr, commands := fakeTor(t, func(cmd string) string {
+		switch {
+		case cmd == "PROTOCOLINFO 1":
+			return "250-PROTOCOLINFO 1\r\n" +
+				"250-AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE=\"" + cookieFile + "\"\r\n" +
+				"250-VERSION Tor=\"0.4.8.12\"\r\n" +
+				"250 OK\r\n"
+		case cmd == "AUTHENTICATE deadbeef":
+			return "250 OK\r\n"
+		case strings.HasPrefix(cmd, "ADD_ONION NEW:ED25519-V3 "):
+			return "250-ServiceID=abcdef\r\n250-PrivateKey=ED25519-V3:c2VjcmV0\r\n250 OK\r\n"
+		case strings.HasPrefix(cmd, "ADD_ONION ED25519-V3:c2VjcmV0 "):
+			return "250-ServiceID=abcdef\r\n250 OK\r\n"
+		}
+		return "510 Unrecognized command\r\n"
+	})
+
+	keyFile := filepath.Join(dir, "onion", "key")
+
+	c, err := Dial(addr, "")
+	require.NoError(t, err)
+	onion, err := c.PublishOnion(keyFile, 80, "127.0.0.1:5050")
+	require.NoError(t, err)
+	c.Close()
+	assert.Equal(t, "abcdef.onion", onion)
+	assert.Contains(t, *commands, "ADD_ONION NEW:ED25519-V3 Port=80,127.0.0.1:5050")
+
+	key, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "ED25519-V3:c2VjcmV0\n", string(key))
+
+	// The stored key is reused, so the address stays the same.
+	c, err = Dial(addr, "")
+	require.NoError(t, err)
+	defer c.Close()
+	onion, err = c.PublishOnion(keyFile, 80, "127.0.0.1:5050")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef.onion", onion)
+	assert.Contains(t, *commands, "ADD_ONION ED25519-V3:c2VjcmV0 Flags=DiscardPK Port=80,127.0.0.1:5050")
+}
+
+func TestDialPassword(t *testing.T) {
+	addr, _ := fakeTor(t, func(cmd string) string {
+		if cmd == `AUTHENTICATE "s3cr3t"` {
+			return "250 OK\r\n"
+		}
+		return "515 Authentication failed: Password did not match HashedControlPassword value from configuration\r\n"
+	})
+
+	c, err := Dial(addr, "s3cr3t")
+	require.NoError(t, err)
+	c.Close()
+
+	_, err = Dial(addr, "wrong")
+	assert.ErrorContains(t, err, "Authentication failed")
+}
+
+func TestDialNeedsPassword(t *testing.T) {
+	addr, _ := fakeTor(t, func(cmd string) string {
+		return "250-PROTOCOLINFO 1\r\n250-AUTH METHODS=HASHEDPASSWORD\r\n250 OK\r\n"
+	})
+
+	_, err := Dial(addr, "")
+	assert.ErrorContains(t, err, "needs a password")
+}
+
+func TestSplitQuoted(t *testing.T) {
+	assert.Equal(t,
+		[]string{"METHODS=COOKIE", `COOKIEFILE="/var/lib/tor dir/cookie \"x\""`},
+		splitQuoted(`METHODS=COOKIE COOKIEFILE="/var/lib/tor dir/cookie \"x\""`))
+}
+
+func TestWait(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	restart := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reader.ReadString('\n')
+		fmt.Fprint(conn, "250-PROTOCOLINFO 1\r\n250-AUTH METHODS=NULL\r\n250 OK\r\n")
+		reader.ReadString('\n')
+		fmt.Fprint(conn, "250 OK\r\n")
+		<-restart
+	}()
+
+	c, err := Dial(ln.Addr().String(), "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	select {
+	case <-done:
+		t.Fatal("Wait returned while tor was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(restart)
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait didn't return after tor closed the connection")
+	}
+}
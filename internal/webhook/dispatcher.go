@@ -0,0 +1,502 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatus_PENDING   DeliveryStatus = "PENDING"
+	DeliveryStatus_DELIVERED DeliveryStatus = "DELIVERED"
+	DeliveryStatus_FAILED    DeliveryStatus = "FAILED"
+)
+
+var (
+	ErrEndpointNotFound    = errors.New("webhook endpoint not found")
+	ErrEndpointsNotManaged = errors.New("webhook endpoints can only be changed in the config file")
+)
+
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = 6 * time.Hour
+
+	// deliveryRetention is how long finished deliveries stay in the log.
+	deliveryRetention = 30 * 24 * time.Hour
+	// compactInterval is how often finished deliveries are pruned while lmt runs.
+	compactInterval = time.Hour
+)
+
+// Delivery is one attempt to get an event to an endpoint, including retries.
+type Delivery struct {
+	ID             string         `json:"id"`
+	EndpointID     string         `json:"endpoint_id"`
+	URL            string         `json:"url"`
+	Event          Event          `json:"event"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	LastStatusCode int            `json:"last_status_code,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// Dispatcher posts signed events to webhook endpoints. Deliveries are kept in
+// a persistent log, retried with exponential backoff and resumed after restarts.
+type Dispatcher struct {
+	log         *store.JSONLog
+	httpClient  *http.Client
+	maxAttempts int
+
+	mtx        sync.Mutex
+	endpoints  map[string]Endpoint
+	configured map[string]bool
+	managed    *store.JSONLog
+	deliveries map[string]*Delivery
+	inflight   map[string]bool
+
+	wake chan struct{}
+}
+
+// NewDispatcher creates a dispatcher for the given endpoints, loading
+// unfinished deliveries from log.
+func NewDispatcher(endpoints []Endpoint, log *store.JSONLog, maxAttempts int) (*Dispatcher, error) {
+	d := &Dispatcher{
+		log:         log,
+		httpClient:  &http.Client{Timeout: 15 * time.Second},
+		maxAttempts: maxAttempts,
+		endpoints:   make(map[string]Endpoint),
+		configured:  make(map[string]bool),
+		deliveries:  make(map[string]*Delivery),
+		inflight:    make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+	}
+
+	for _, endpoint := range endpoints {
+		d.endpoints[endpoint.ID] = endpoint
+		d.configured[endpoint.ID] = true
+	}
+
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// load replays the delivery log and compacts it.
+func (d *Dispatcher) load() error {
+	err := d.log.Replay(func(record []byte) error {
+		var delivery Delivery
+		if err := json.Unmarshal(record, &delivery); err != nil {
+			return nil
+		}
+		d.deliveries[delivery.ID] = &delivery
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load webhook deliveries: %w", err)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.compact(time.Now())
+}
+
+// compact drops finished deliveries older than deliveryRetention and
+// rewrites the log to one record per delivery. It must be called with mtx held.
+func (d *Dispatcher) compact(now time.Time) error {
+	cutoff := now.Add(-deliveryRetention)
+	var records []interface{}
+	for id, delivery := range d.deliveries {
+		if delivery.Status != DeliveryStatus_PENDING && delivery.UpdatedAt.Before(cutoff) {
+			delete(d.deliveries, id)
+			continue
+		}
+		records = append(records, delivery)
+	}
+
+	return d.log.Rewrite(records)
+}
+
+// ManageEndpoints loads the endpoints added through AddEndpoint from log, and
+// stores later changes there. Without it, only the configured endpoints exist.
+func (d *Dispatcher) ManageEndpoints(log *store.JSONLog) error {
+	var endpoints []Endpoint
+	err := log.Replay(func(record []byte) error {
+		var endpoint Endpoint
+		if err := json.Unmarshal(record, &endpoint); err != nil {
+			return nil
+		}
+		endpoints = append(endpoints, endpoint)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load webhook endpoints: %w", err)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.managed = log
+	var unsigned bool
+	for _, endpoint := range endpoints {
+		if d.configured[endpoint.ID] {
+			continue
+		}
+		// Endpoints were once added without a secret; their requests are
+		// signed from now on, with a secret only known to lmt until re-added.
+		if endpoint.Secret == "" {
+			slog.Warn("Webhook endpoint has no secret, generated one; add it again with a secret to verify its requests", "endpoint_id", endpoint.ID, "url", endpoint.URL)
+			endpoint.Secret = NewSecret()
+			unsigned = true
+		}
+		d.endpoints[endpoint.ID] = endpoint
+	}
+	if unsigned {
+		return d.saveEndpoints()
+	}
+	return nil
+}
+
+// Endpoints returns all endpoints, sorted by URL.
+func (d *Dispatcher) Endpoints() []Endpoint {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	endpoints := make([]Endpoint, 0, len(d.endpoints))
+	for _, endpoint := range d.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].URL < endpoints[j].URL
+	})
+	return endpoints
+}
+
+// IsConfigured reports whether the endpoint comes from the config file, and
+// so can't be changed through the dispatcher.
+func (d *Dispatcher) IsConfigured(id string) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.configured[id]
+}
+
+// AddEndpoint adds an endpoint, or replaces the one with the same URL. An
+// endpoint without a secret gets a random one.
+func (d *Dispatcher) AddEndpoint(endpoint Endpoint) (Endpoint, error) {
+	if err := endpoint.Validate(); err != nil {
+		return Endpoint{}, err
+	}
+	endpoint.ID = EndpointID(endpoint.URL)
+	if endpoint.Secret == "" {
+		endpoint.Secret = NewSecret()
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.managed == nil {
+		return Endpoint{}, ErrEndpointsNotManaged
+	}
+	if d.configured[endpoint.ID] {
+		return Endpoint{}, fmt.Errorf("endpoint %s is set in the config file", endpoint.URL)
+	}
+
+	d.endpoints[endpoint.ID] = endpoint
+	if err := d.saveEndpoints(); err != nil {
+		return Endpoint{}, err
+	}
+	return endpoint, nil
+}
+
+// RemoveEndpoint removes an endpoint added with AddEndpoint. Its pending
+// deliveries fail.
+func (d *Dispatcher) RemoveEndpoint(id string) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.managed == nil {
+		return ErrEndpointsNotManaged
+	}
+	if _, ok := d.endpoints[id]; !ok {
+		return ErrEndpointNotFound
+	}
+	if d.configured[id] {
+		return fmt.Errorf("endpoint %s is set in the config file", id)
+	}
+
+	delete(d.endpoints, id)
+	return d.saveEndpoints()
+}
+
+// saveEndpoints rewrites the managed endpoints. It must be called with mtx held.
+func (d *Dispatcher) saveEndpoints() error {
+	var records []interface{}
+	for id, endpoint := range d.endpoints {
+		if !d.configured[id] {
+			records = append(records, endpoint)
+		}
+	}
+
+	if err := d.managed.Rewrite(records); err != nil {
+		return fmt.Errorf("failed to save webhook endpoints: %w", err)
+	}
+	return nil
+}
+
+// Publish queues an event for every endpoint subscribed to its type.
+func (d *Dispatcher) Publish(eventType EventType, data PaymentData) {
+	now := time.Now()
+	event := Event{
+		ID:        newID(),
+		Type:      eventType,
+		CreatedAt: now.Unix(),
+		Data:      data,
+	}
+
+	d.mtx.Lock()
+	for _, endpoint := range d.endpoints {
+		if !endpoint.Accepts(eventType) {
+			continue
+		}
+		d.enqueue(endpoint, event, now)
+	}
+	d.mtx.Unlock()
+
+	d.notify()
+}
+
+// enqueue creates a pending delivery. It must be called with mtx held.
+func (d *Dispatcher) enqueue(endpoint Endpoint, event Event, now time.Time) *Delivery {
+	delivery := &Delivery{
+		ID:            newID(),
+		EndpointID:    endpoint.ID,
+		URL:           endpoint.URL,
+		Event:         event,
+		Status:        DeliveryStatus_PENDING,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	d.deliveries[delivery.ID] = delivery
+	d.persist(delivery)
+	return delivery
+}
+
+// Deliveries returns the most recent deliveries, newest first.
+func (d *Dispatcher) Deliveries(limit int) []Delivery {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	deliveries := make([]Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		deliveries = append(deliveries, *delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// Replay sends the event of an earlier delivery again as a new delivery.
+func (d *Dispatcher) Replay(id string) (Delivery, error) {
+	d.mtx.Lock()
+	original, ok := d.deliveries[id]
+	if !ok {
+		d.mtx.Unlock()
+		return Delivery{}, fmt.Errorf("delivery %s not found", id)
+	}
+
+	endpoint, ok := d.endpoints[original.EndpointID]
+	if !ok {
+		d.mtx.Unlock()
+		return Delivery{}, fmt.Errorf("endpoint %s no longer exists", original.EndpointID)
+	}
+
+	delivery := *d.enqueue(endpoint, original.Event, time.Now())
+	d.mtx.Unlock()
+
+	d.notify()
+	return delivery, nil
+}
+
+// Run delivers pending events until ctx is canceled. Finished deliveries
+// are pruned every compactInterval.
+func (d *Dispatcher) Run(ctx context.Context) {
+	compactTicker := time.NewTicker(compactInterval)
+	defer compactTicker.Stop()
+
+	for {
+		next := d.dispatchDue(ctx)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+		case <-timer.C:
+		case now := <-compactTicker.C:
+			d.mtx.Lock()
+			if err := d.compact(now); err != nil {
+				slog.Error("Failed to compact webhook deliveries", "error", err)
+			}
+			d.mtx.Unlock()
+		}
+		timer.Stop()
+	}
+}
+
+// dispatchDue starts attempts for all due deliveries and returns when the next one is due.
+func (d *Dispatcher) dispatchDue(ctx context.Context) time.Time {
+	now := time.Now()
+	next := now.Add(time.Minute)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for id, delivery := range d.deliveries {
+		if delivery.Status != DeliveryStatus_PENDING || d.inflight[id] {
+			continue
+		}
+
+		if delivery.NextAttemptAt.After(now) {
+			if delivery.NextAttemptAt.Before(next) {
+				next = delivery.NextAttemptAt
+			}
+			continue
+		}
+
+		endpoint, ok := d.endpoints[delivery.EndpointID]
+		if !ok {
+			delivery.Status = DeliveryStatus_FAILED
+			delivery.LastError = "endpoint no longer exists"
+			delivery.UpdatedAt = now
+			d.persist(delivery)
+			continue
+		}
+
+		d.inflight[id] = true
+		go d.attempt(ctx, endpoint, *delivery)
+	}
+
+	return next
+}
+
+// attempt posts the delivery once and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, endpoint Endpoint, delivery Delivery) {
+	statusCode, err := d.post(ctx, endpoint, delivery.Event)
+
+	d.mtx.Lock()
+	defer d.notify()
+	defer d.mtx.Unlock()
+
+	delete(d.inflight, delivery.ID)
+	current, ok := d.deliveries[delivery.ID]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	current.Attempts++
+	current.LastStatusCode = statusCode
+	current.UpdatedAt = now
+
+	logger := slog.With("delivery_id", current.ID, "url", current.URL, "event", current.Event.Type, "attempt", current.Attempts)
+
+	switch {
+	case err == nil:
+		current.Status = DeliveryStatus_DELIVERED
+		current.LastError = ""
+		logger.Info("Webhook delivered")
+	case current.Attempts >= d.maxAttempts:
+		current.Status = DeliveryStatus_FAILED
+		current.LastError = err.Error()
+		logger.Error("Webhook delivery failed, giving up", "error", err)
+	default:
+		current.LastError = err.Error()
+		current.NextAttemptAt = now.Add(retryDelay(current.Attempts))
+		logger.Warn("Webhook delivery failed, will retry", "error", err, "next_attempt_at", current.NextAttemptAt)
+	}
+
+	d.persist(current)
+}
+
+func (d *Dispatcher) post(ctx context.Context, endpoint Endpoint, event Event) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lmt-webhook")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// persist appends the delivery's current state to the log. It must be called with mtx held.
+func (d *Dispatcher) persist(delivery *Delivery) {
+	if err := d.log.Append(delivery); err != nil {
+		slog.Error("Failed to persist webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// retryDelay doubles the delay after every failed attempt.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
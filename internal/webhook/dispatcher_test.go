@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/asheswook/lightning-multitool/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	t.Run("url with secret and events", func(t *testing.T) {
+		endpoint, err := ParseEndpoint("https://example.com/hook;secret=s3cr3t;events=zap.received")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/hook", endpoint.URL)
+		assert.Equal(t, "s3cr3t", endpoint.Secret)
+		assert.True(t, endpoint.Accepts(EventZapReceived))
+		assert.False(t, endpoint.Accepts(EventPaymentReceived))
+		assert.Equal(t, EndpointID("https://example.com/hook"), endpoint.ID)
+	})
+
+	t.Run("no event filter only accepts payments", func(t *testing.T) {
+		endpoint, err := ParseEndpoint("http://localhost:8000;secret=s3cr3t")
+		require.NoError(t, err)
+		assert.True(t, endpoint.Accepts(EventPaymentReceived))
+		assert.False(t, endpoint.Accepts(EventZapReceived), "a zap is already published as payment.received")
+	})
+
+	t.Run("invalid endpoints", func(t *testing.T) {
+		for _, s := range []string{"", "example.com", "https://example.com;secret=s;events=foo", "https://example.com;key=value", "https://example.com;secret", "https://example.com", "https://example.com;secret="} {
+			_, err := ParseEndpoint(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	newDispatcher := func(t *testing.T, path string, endpoint Endpoint, maxAttempts int) *Dispatcher {
+		log, err := store.OpenJSONLog(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { log.Close() })
+
+		d, err := NewDispatcher([]Endpoint{endpoint}, log, maxAttempts)
+		require.NoError(t, err)
+		return d
+	}
+
+	waitFor := func(t *testing.T, d *Dispatcher, status DeliveryStatus) Delivery {
+		var delivery Delivery
+		require.Eventually(t, func() bool {
+			deliveries := d.Deliveries(1)
+			if len(deliveries) == 0 {
+				return false
+			}
+			delivery = deliveries[0]
+			return delivery.Status == status
+		}, 5*time.Second, 10*time.Millisecond)
+		return delivery
+	}
+
+	t.Run("delivers signed events", func(t *testing.T) {
+		received := make(chan Event, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+			require.NoError(t, err)
+			assert.Equal(t, Sign("s3cr3t", timestamp, body), r.Header.Get(SignatureHeader))
+
+			var event Event
+			require.NoError(t, json.Unmarshal(body, &event))
+			received <- event
+		}))
+		defer server.Close()
+
+		endpoint, err := ParseEndpoint(server.URL + ";secret=s3cr3t")
+		require.NoError(t, err)
+		d := newDispatcher(t, filepath.Join(t.TempDir(), "webhooks.jsonl"), endpoint, 3)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go d.Run(ctx)
+
+		d.Publish(EventPaymentReceived, PaymentData{PaymentHash: "abcd", AmountMsat: 21000, AmountSat: 21})
+
+		select {
+		case event := <-received:
+			assert.Equal(t, EventPaymentReceived, event.Type)
+			assert.Equal(t, "abcd", event.Data.PaymentHash)
+			assert.Equal(t, int64(21000), event.Data.AmountMsat)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event was not delivered")
+		}
+
+		delivery := waitFor(t, d, DeliveryStatus_DELIVERED)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+	})
+
+	t.Run("gives up after max attempts and resumes from the log", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		endpoint, err := ParseEndpoint(server.URL + ";secret=s3cr3t")
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "webhooks.jsonl")
+		d := newDispatcher(t, path, endpoint, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go d.Run(ctx)
+
+		d.Publish(EventPaymentReceived, PaymentData{PaymentHash: "abcd"})
+		failed := waitFor(t, d, DeliveryStatus_FAILED)
+		assert.Equal(t, http.StatusInternalServerError, failed.LastStatusCode)
+
+		reloaded := newDispatcher(t, path, endpoint, 1)
+		deliveries := reloaded.Deliveries(0)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, failed.ID, deliveries[0].ID)
+		assert.Equal(t, DeliveryStatus_FAILED, deliveries[0].Status)
+
+		replayed, err := reloaded.Replay(failed.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, failed.ID, replayed.ID)
+		assert.Equal(t, failed.Event.ID, replayed.Event.ID)
+		assert.Equal(t, DeliveryStatus_PENDING, replayed.Status)
+	})
+}
+
+func TestDispatcherCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.jsonl")
+	log, err := store.OpenJSONLog(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	endpoint, err := ParseEndpoint("https://example.com/hook;secret=s3cr3t")
+	require.NoError(t, err)
+	d, err := NewDispatcher([]Endpoint{endpoint}, log, 3)
+	require.NoError(t, err)
+
+	now := time.Now()
+	d.mtx.Lock()
+	old := d.enqueue(endpoint, Event{ID: "old"}, now.Add(-2*deliveryRetention))
+	old.Status = DeliveryStatus_DELIVERED
+	d.persist(old)
+	failed := d.enqueue(endpoint, Event{ID: "failed"}, now.Add(-2*deliveryRetention))
+	failed.Status = DeliveryStatus_FAILED
+	d.persist(failed)
+	pending := d.enqueue(endpoint, Event{ID: "pending"}, now.Add(-2*deliveryRetention))
+	recent := d.enqueue(endpoint, Event{ID: "recent"}, now)
+	recent.Status = DeliveryStatus_DELIVERED
+	d.persist(recent)
+
+	require.NoError(t, d.compact(now))
+	d.mtx.Unlock()
+
+	ids := func(deliveries []Delivery) []string {
+		var ids []string
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{pending.ID, recent.ID}, ids(d.Deliveries(0)))
+
+	// The log holds one line per remaining delivery.
+	var lines int
+	require.NoError(t, log.Replay(func([]byte) error {
+		lines++
+		return nil
+	}))
+	assert.Equal(t, 2, lines)
+}
+
+func TestManagedEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	configured, err := ParseEndpoint("https://example.com/configured;secret=s3cr3t")
+	require.NoError(t, err)
+
+	open := func() *Dispatcher {
+		log, err := store.OpenJSONLog(filepath.Join(dir, "webhooks.jsonl"))
+		require.NoError(t, err)
+		t.Cleanup(func() { log.Close() })
+		endpoints, err := store.OpenJSONLog(filepath.Join(dir, "webhook_endpoints.jsonl"))
+		require.NoError(t, err)
+		t.Cleanup(func() { endpoints.Close() })
+
+		d, err := NewDispatcher([]Endpoint{configured}, log, 3)
+		require.NoError(t, err)
+		require.NoError(t, d.ManageEndpoints(endpoints))
+		return d
+	}
+
+	d := open()
+	added, err := d.AddEndpoint(Endpoint{URL: "https://example.com/added", Secret: "s3cr3t", Events: []EventType{EventZapReceived}})
+	require.NoError(t, err)
+	assert.Equal(t, EndpointID("https://example.com/added"), added.ID)
+
+	_, err = d.AddEndpoint(Endpoint{URL: "ftp://example.com"})
+	assert.Error(t, err)
+	_, err = d.AddEndpoint(Endpoint{URL: configured.URL})
+	assert.Error(t, err, "configured endpoints can't be replaced")
+	assert.Error(t, d.RemoveEndpoint(configured.ID))
+	assert.ErrorIs(t, d.RemoveEndpoint("unknown"), ErrEndpointNotFound)
+
+	// Added endpoints survive a restart.
+	d = open()
+	endpoints := d.Endpoints()
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, added, endpoints[0])
+	assert.True(t, d.IsConfigured(configured.ID))
+	assert.False(t, d.IsConfigured(added.ID))
+
+	require.NoError(t, d.RemoveEndpoint(added.ID))
+	d = open()
+	assert.Len(t, d.Endpoints(), 1)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(1))
+	assert.Equal(t, time.Minute, retryDelay(2))
+	assert.Equal(t, 4*time.Minute, retryDelay(4))
+	assert.Equal(t, retryMaxDelay, retryDelay(100))
+}
+
+func TestManagedEndpointsWithoutSecret(t *testing.T) {
+	dir := t.TempDir()
+	endpoints, err := store.OpenJSONLog(filepath.Join(dir, "webhook_endpoints.jsonl"))
+	require.NoError(t, err)
+	defer endpoints.Close()
+	// An endpoint added before secrets were required.
+	require.NoError(t, endpoints.Append(Endpoint{ID: EndpointID("https://example.com/old"), URL: "https://example.com/old"}))
+
+	log, err := store.OpenJSONLog(filepath.Join(dir, "webhooks.jsonl"))
+	require.NoError(t, err)
+	defer log.Close()
+	d, err := NewDispatcher(nil, log, 3)
+	require.NoError(t, err)
+	require.NoError(t, d.ManageEndpoints(endpoints))
+
+	require.Len(t, d.Endpoints(), 1)
+	secret := d.Endpoints()[0].Secret
+	assert.Len(t, secret, 64)
+
+	// The generated secret is kept.
+	var saved Endpoint
+	require.NoError(t, endpoints.Replay(func(record []byte) error {
+		return json.Unmarshal(record, &saved)
+	}))
+	assert.Equal(t, secret, saved.Secret)
+}
@@ -1,11 +1,20 @@
 package lndrest
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -14,13 +23,101 @@ type Client struct {
 	httpClient *http.Client
 	host       string
 	macaroon   string // hex encoded
+	observe    RequestObserver
+}
+
+// APIError is a non-200 response from the LND REST API.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LND API error: %s, body: %s", e.Status, e.Body)
+}
+
+// RequestObserver is called after every LND REST call, e.g. to record metrics.
+// path is the request path including any query; err is nil on success.
+type RequestObserver func(method, path string, duration time.Duration, err error)
+
+// clientConfig is what a ClientOption can change.
+type clientConfig struct {
+	transport *http.Transport
+	observe   RequestObserver
+}
+
+// ClientOption configures optional Client behavior.
+type ClientOption func(*clientConfig) error
+
+// WithRequestObserver calls observe after every REST call. Streaming
+// subscriptions are not observed.
+func WithRequestObserver(observe RequestObserver) ClientOption {
+	return func(cfg *clientConfig) error {
+		cfg.observe = observe
+		return nil
+	}
+}
+
+// WithCertFingerprint pins the LND TLS certificate to the given SHA-256
+// fingerprint (hex, colons optional). The connection is refused if the
+// certificate presented by LND doesn't match, even when no cert file is set.
+func WithCertFingerprint(fingerprint string) ClientOption {
+	return func(cfg *clientConfig) error {
+		want, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+		if err != nil || len(want) != sha256.Size {
+			return fmt.Errorf("invalid certificate fingerprint %q: expected a hex encoded SHA-256 hash", fingerprint)
+		}
+
+		// The pin replaces chain verification for self-signed certificates;
+		// with a cert file both checks apply.
+		cfg.transport.TLSClientConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("LND presented no TLS certificate")
+			}
+			got := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(got[:], want) != 1 {
+				return fmt.Errorf("LND TLS certificate fingerprint mismatch: got %x", got)
+			}
+			return nil
+		}
+		return nil
+	}
+}
+
+// WithSystemRoots verifies the LND TLS certificate against the system's
+// trusted roots instead of skipping verification when no cert file is set.
+func WithSystemRoots() ClientOption {
+	return func(cfg *clientConfig) error {
+		cfg.transport.TLSClientConfig.InsecureSkipVerify = false
+		return nil
+	}
+}
+
+// WithProxy sends all requests to LND through a proxy, e.g.
+// socks5://127.0.0.1:9050 to reach a node over Tor. A bare host:port is
+// taken as a SOCKS5 proxy.
+func WithProxy(proxy string) ClientOption {
+	return func(cfg *clientConfig) error {
+		if !strings.Contains(proxy, "://") {
+			proxy = "socks5://" + proxy
+		}
+
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q", proxy)
+		}
+		cfg.transport.Proxy = http.ProxyURL(u)
+		return nil
+	}
 }
 
 // NewClient creates a new LND client.
 // It configures an HTTP client that trusts the LND's TLS certificate.
-func NewClient(host, macaroonBase64, certPath string) (*Client, error) {
+func NewClient(host, macaroonBase64, certPath string, opts ...ClientOption) (*Client, error) {
 	httpClient := &http.Client{Timeout: 20 * time.Second}
 
+	var transport *http.Transport
 	if certPath != "" {
 		caCert, err := os.ReadFile(certPath)
 		if err != nil {
@@ -30,20 +127,96 @@ func NewClient(host, macaroonBase64, certPath string) (*Client, error) {
 		if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
 			return nil, fmt.Errorf("failed to append LND cert to pool")
 		}
-		httpClient.Transport = &http.Transport{
+		transport = &http.Transport{
 			TLSClientConfig: &tls.Config{
 				RootCAs: caCertPool,
 			},
 		}
 	} else {
-		httpClient.Transport = &http.Transport{
+		transport = &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		}
 	}
 
+	cfg := clientConfig{transport: transport}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	httpClient.Transport = transport
+
 	return &Client{
 		httpClient: httpClient,
 		host:       host,
 		macaroon:   macaroonBase64,
+		observe:    cfg.observe,
 	}, nil
 }
+
+// restURL returns the URL for the given REST path.
+// The host may carry an explicit http:// or https:// scheme; https is assumed otherwise.
+func (c *Client) restURL(path string) string {
+	if strings.HasPrefix(c.host, "http://") || strings.HasPrefix(c.host, "https://") {
+		return strings.TrimSuffix(c.host, "/") + path
+	}
+	return "https://" + c.host + path
+}
+
+// wsURL returns the websocket URL for the given streaming REST path.
+func (c *Client) wsURL(path string) string {
+	u := c.restURL(path)
+	if rest, ok := strings.CutPrefix(u, "http://"); ok {
+		return "ws://" + rest
+	}
+	return "wss://" + strings.TrimPrefix(u, "https://")
+}
+
+// doJSON sends a request to LND and decodes the JSON response into out.
+// body and out may be nil.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out interface{}) (err error) {
+	if c.observe != nil {
+		start := time.Now()
+		defer func() { c.observe(method, path, time.Since(start), err) }()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.restURL(path), reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create http request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Grpc-Metadata-macaroon", c.macaroon)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to LND: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBody)}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode LND response: %w", err)
+	}
+
+	return nil
+}
@@ -1,18 +1,27 @@
 package lndrest
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/pem"
+	"io"
 	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -78,3 +87,203 @@ func TestNewClient(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to append LND cert to pool")
 	})
 }
+
+func TestCertFingerprint(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"alias":"pororo"}`))
+	}))
+	defer server.Close()
+
+	fingerprint := sha256.Sum256(server.Certificate().Raw)
+
+	t.Run("matching fingerprint", func(t *testing.T) {
+		client, err := NewClient(server.URL, "macaroon", "", WithCertFingerprint(hex.EncodeToString(fingerprint[:])))
+		require.NoError(t, err)
+
+		res, err := client.GetInfo(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "pororo", res.Alias)
+	})
+
+	t.Run("mismatching fingerprint", func(t *testing.T) {
+		client, err := NewClient(server.URL, "macaroon", "", WithCertFingerprint(hex.EncodeToString(make([]byte, 32))))
+		require.NoError(t, err)
+
+		_, err = client.GetInfo(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fingerprint mismatch")
+	})
+
+	t.Run("invalid fingerprint", func(t *testing.T) {
+		_, err := NewClient(server.URL, "macaroon", "", WithCertFingerprint("zz"))
+		require.Error(t, err)
+	})
+}
+
+func TestRequestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/getinfo" {
+			w.Write([]byte(`{"alias":"node"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	type observation struct {
+		method, path string
+		err          error
+	}
+	var observed []observation
+
+	client, err := NewClient(server.URL, "macaroon", "", WithRequestObserver(func(method, path string, duration time.Duration, err error) {
+		observed = append(observed, observation{method, path, err})
+	}))
+	require.NoError(t, err)
+
+	_, err = client.GetInfo(context.Background())
+	require.NoError(t, err)
+	_, err = client.ChannelBalance(context.Background())
+	require.Error(t, err)
+
+	require.Len(t, observed, 2)
+	assert.Equal(t, http.MethodGet, observed[0].method)
+	assert.Equal(t, "/v1/getinfo", observed[0].path)
+	assert.NoError(t, observed[0].err)
+	assert.Equal(t, "/v1/balance/channels", observed[1].path)
+	assert.Error(t, observed[1].err)
+}
+
+func TestWithProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`{"alias":"node"}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient("http://lnd.onion:8080", "macaroon", "", WithProxy(proxy.URL))
+	require.NoError(t, err)
+
+	info, err := client.GetInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "node", info.Alias)
+	assert.Equal(t, "http://lnd.onion:8080/v1/getinfo", proxied)
+
+	_, err = NewClient("localhost:8080", "macaroon", "", WithProxy("socks5://"))
+	assert.Error(t, err)
+}
+
+// socks5Proxy runs a minimal SOCKS5 proxy (no auth, CONNECT only) that
+// connects every request to target. It returns the proxy address and the
+// destinations clients asked for.
+func socks5Proxy(t *testing.T, target string) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	requested := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				// Greeting: version, methods. Answer with "no authentication".
+				greeting := make([]byte, 2)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{0x05, 0x00})
+
+				// Request: version, CONNECT, reserved, address type.
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				var host string
+				switch header[3] {
+				case 0x01:
+					addr := make([]byte, 4)
+					io.ReadFull(conn, addr)
+					host = net.IP(addr).String()
+				case 0x03:
+					length := make([]byte, 1)
+					io.ReadFull(conn, length)
+					name := make([]byte, length[0])
+					io.ReadFull(conn, name)
+					host = string(name)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				if _, err := io.ReadFull(conn, port); err != nil {
+					return
+				}
+				requested <- net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String(), requested
+}
+
+func TestWithSOCKS5Proxy(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	lnd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/getinfo" {
+			w.Write([]byte(`{"alias":"node"}`))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"state":"ACCEPTED"}}`))
+		conn.ReadMessage() // Block until the client goes away.
+	}))
+	defer lnd.Close()
+
+	proxy, requested := socks5Proxy(t, lnd.Listener.Addr().String())
+
+	// A bare host:port is a SOCKS5 proxy, which resolves the onion address.
+	client, err := NewClient("http://lnd.onion:8080", "macaroon", "", WithProxy(proxy))
+	require.NoError(t, err)
+
+	info, err := client.GetInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "node", info.Alias)
+	assert.Equal(t, "lnd.onion:8080", <-requested)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	invoices, err := client.SubscribeSingleInvoice(ctx, []byte{0x01})
+	require.NoError(t, err)
+	assert.Equal(t, "lnd.onion:8080", <-requested, "the websocket goes through the proxy too")
+
+	select {
+	case invoice := <-invoices:
+		assert.Equal(t, InvoiceState_ACCEPTED, invoice.State)
+	case <-ctx.Done():
+		t.Fatal("no invoice received through the proxy")
+	}
+}
@@ -1,11 +1,7 @@
 package lndrest
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
 )
 
@@ -33,34 +29,9 @@ type CreateInvoiceResponse struct {
 
 // CreateInvoice creates a new invoice on the LND node.
 func (c *Client) CreateInvoice(ctx context.Context, params CreateInvoiceParams) (CreateInvoiceResponse, error) {
-	bodyBytes, err := json.Marshal(params)
-	if err != nil {
-		return CreateInvoiceResponse{}, fmt.Errorf("failed to marshal invoice request: %w", err)
-	}
-
-	url := fmt.Sprintf("https://%s/v1/invoices", c.host)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return CreateInvoiceResponse{}, fmt.Errorf("failed to create http request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Grpc-Metadata-macaroon", c.macaroon)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return CreateInvoiceResponse{}, fmt.Errorf("failed to send request to LND: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return CreateInvoiceResponse{}, fmt.Errorf("LND API error: %s, body: %s", resp.Status, string(body))
-	}
-
 	var invResp CreateInvoiceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&invResp); err != nil {
-		return CreateInvoiceResponse{}, fmt.Errorf("failed to decode LND response: %w", err)
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/invoices", params, &invResp); err != nil {
+		return CreateInvoiceResponse{}, err
 	}
 
 	return invResp, nil
@@ -0,0 +1,57 @@
+package lndrest
+
+import (
+	"context"
+	"net/http"
+)
+
+// AddHoldInvoiceParams holds the parameters for creating a hold invoice.
+// Unlike a regular invoice, the caller supplies the payment hash and keeps the
+// preimage until it decides to settle.
+type AddHoldInvoiceParams struct {
+	Memo            string `json:"memo,omitempty"`
+	Hash            []byte `json:"hash"`
+	Value           int64  `json:"value,omitempty"`
+	ValueMsat       int64  `json:"value_msat,omitempty"`
+	DescriptionHash []byte `json:"description_hash,omitempty"`
+	Expiry          int64  `json:"expiry,omitempty"`
+	FallbackAddr    string `json:"fallback_addr,omitempty"`
+	CltvExpiry      uint64 `json:"cltv_expiry,omitempty"`
+	Private         bool   `json:"private,omitempty"`
+}
+
+// AddHoldInvoiceResponse is the response from LND after creating a hold invoice.
+type AddHoldInvoiceResponse struct {
+	PaymentRequest string `json:"payment_request"`
+	AddIndex       string `json:"add_index"`
+	PaymentAddr    []byte `json:"payment_addr"`
+}
+
+// AddHoldInvoice creates a hold invoice for the given payment hash.
+// Incoming payments stay in the ACCEPTED state until SettleInvoice or CancelInvoice is called.
+func (c *Client) AddHoldInvoice(ctx context.Context, params AddHoldInvoiceParams) (AddHoldInvoiceResponse, error) {
+	var res AddHoldInvoiceResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/v2/invoices/hodl", params, &res); err != nil {
+		return AddHoldInvoiceResponse{}, err
+	}
+
+	return res, nil
+}
+
+// SettleInvoice settles an accepted hold invoice with its preimage.
+func (c *Client) SettleInvoice(ctx context.Context, preimage []byte) error {
+	body := struct {
+		Preimage []byte `json:"preimage"`
+	}{Preimage: preimage}
+
+	return c.doJSON(ctx, http.MethodPost, "/v2/invoices/settle", body, nil)
+}
+
+// CancelInvoice cancels an open or accepted hold invoice, failing any HTLCs held for it.
+func (c *Client) CancelInvoice(ctx context.Context, paymentHash []byte) error {
+	body := struct {
+		PaymentHash []byte `json:"payment_hash"`
+	}{PaymentHash: paymentHash}
+
+	return c.doJSON(ctx, http.MethodPost, "/v2/invoices/cancel", body, nil)
+}
@@ -0,0 +1,88 @@
+package lndrest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddHoldInvoice(t *testing.T) {
+	t.Run("successful hold invoice creation", func(t *testing.T) {
+		const paymentRequest = "lnbc10u1pjx5g8zpp5z..."
+		var hash = []byte{1, 2, 3}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/v2/invoices/hodl", r.URL.Path)
+			assert.Equal(t, "macaroon", r.Header.Get("Grpc-Metadata-macaroon"))
+
+			var reqBody AddHoldInvoiceParams
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+			assert.Equal(t, hash, reqBody.Hash)
+			assert.Equal(t, int64(5000), reqBody.ValueMsat)
+
+			json.NewEncoder(w).Encode(AddHoldInvoiceResponse{
+				PaymentRequest: paymentRequest,
+				AddIndex:       "7",
+			})
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		resp, err := client.AddHoldInvoice(context.Background(), AddHoldInvoiceParams{
+			Hash:      hash,
+			ValueMsat: 5000,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, paymentRequest, resp.PaymentRequest)
+		assert.Equal(t, "7", resp.AddIndex)
+	})
+
+	t.Run("LND API error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invoice with payment hash already exists"))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		_, err = client.AddHoldInvoice(context.Background(), AddHoldInvoiceParams{Hash: []byte{1}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	})
+}
+
+func TestSettleAndCancelInvoice(t *testing.T) {
+	var preimage = []byte{4, 5, 6}
+	var hash = []byte{7, 8, 9}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]byte
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch r.URL.Path {
+		case "/v2/invoices/settle":
+			assert.Equal(t, preimage, body["preimage"])
+		case "/v2/invoices/cancel":
+			assert.Equal(t, hash, body["payment_hash"])
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	require.NoError(t, client.SettleInvoice(context.Background(), preimage))
+	require.NoError(t, client.CancelInvoice(context.Background(), hash))
+}
@@ -10,26 +10,47 @@ const (
 )
 
 type Invoice struct {
-	Memo            string       `json:"memo,omitempty"`
-	RPreimage       []byte       `json:"r_preimage,omitempty"`
-	RHash           []byte       `json:"r_hash,omitempty"`
-	Value           int64        `json:"value,string,omitempty"`
-	ValueMsat       int64        `json:"value_msat,string,omitempty"`
-	CreationDate    int64        `json:"creation_date,string,omitempty"`
-	SettleDate      int64        `json:"settle_date,string,omitempty"`
-	PaymentRequest  string       `json:"payment_request,omitempty"`
-	DescriptionHash []byte       `json:"description_hash,omitempty"`
-	Expiry          int64        `json:"expiry,string,omitempty"`
-	FallbackAddr    string       `json:"fallback_addr,omitempty"`
-	CltvExpiry      uint64       `json:"cltv_expiry,string,omitempty"`
-	Private         bool         `json:"private,omitempty"`
-	AddIndex        uint64       `json:"add_index,string,omitempty"`
-	SettleIndex     uint64       `json:"settle_index,string,omitempty"`
-	AmtPaidSat      int64        `json:"amt_paid_sat,string,omitempty"`
-	AmtPaidMsat     int64        `json:"amt_paid_msat,string,omitempty"`
-	State           InvoiceState `json:"state,omitempty"`
-	IsKeysend       bool         `json:"is_keysend,omitempty"`
-	PaymentAddr     []byte       `json:"payment_addr,omitempty"`
-	IsAmp           bool         `json:"is_amp,omitempty"`
-	IsBlinded       bool         `json:"is_blinded,omitempty"`
+	Memo            string        `json:"memo,omitempty"`
+	RPreimage       []byte        `json:"r_preimage,omitempty"`
+	RHash           []byte        `json:"r_hash,omitempty"`
+	Value           int64         `json:"value,string,omitempty"`
+	ValueMsat       int64         `json:"value_msat,string,omitempty"`
+	CreationDate    int64         `json:"creation_date,string,omitempty"`
+	SettleDate      int64         `json:"settle_date,string,omitempty"`
+	PaymentRequest  string        `json:"payment_request,omitempty"`
+	DescriptionHash []byte        `json:"description_hash,omitempty"`
+	Expiry          int64         `json:"expiry,string,omitempty"`
+	FallbackAddr    string        `json:"fallback_addr,omitempty"`
+	CltvExpiry      uint64        `json:"cltv_expiry,string,omitempty"`
+	Private         bool          `json:"private,omitempty"`
+	AddIndex        uint64        `json:"add_index,string,omitempty"`
+	SettleIndex     uint64        `json:"settle_index,string,omitempty"`
+	AmtPaidSat      int64         `json:"amt_paid_sat,string,omitempty"`
+	AmtPaidMsat     int64         `json:"amt_paid_msat,string,omitempty"`
+	State           InvoiceState  `json:"state,omitempty"`
+	IsKeysend       bool          `json:"is_keysend,omitempty"`
+	PaymentAddr     []byte        `json:"payment_addr,omitempty"`
+	IsAmp           bool          `json:"is_amp,omitempty"`
+	IsBlinded       bool          `json:"is_blinded,omitempty"`
+	Htlcs           []InvoiceHTLC `json:"htlcs,omitempty"`
+}
+
+type InvoiceHTLCState string
+
+const (
+	InvoiceHTLCState_ACCEPTED InvoiceHTLCState = "ACCEPTED"
+	InvoiceHTLCState_SETTLED  InvoiceHTLCState = "SETTLED"
+	InvoiceHTLCState_CANCELED InvoiceHTLCState = "CANCELED"
+)
+
+// InvoiceHTLC is an HTLC paying to an invoice.
+type InvoiceHTLC struct {
+	ChanID       uint64           `json:"chan_id,string,omitempty"`
+	HtlcIndex    uint64           `json:"htlc_index,string,omitempty"`
+	AmtMsat      uint64           `json:"amt_msat,string,omitempty"`
+	AcceptHeight int32            `json:"accept_height,omitempty"`
+	AcceptTime   int64            `json:"accept_time,string,omitempty"`
+	ResolveTime  int64            `json:"resolve_time,string,omitempty"`
+	ExpiryHeight int32            `json:"expiry_height,omitempty"`
+	State        InvoiceHTLCState `json:"state,omitempty"`
 }
@@ -0,0 +1,248 @@
+package lndrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type SubscriptionState string
+
+const (
+	SubscriptionState_CONNECTING   SubscriptionState = "CONNECTING"
+	SubscriptionState_CONNECTED    SubscriptionState = "CONNECTED"
+	SubscriptionState_RECONNECTING SubscriptionState = "RECONNECTING"
+	SubscriptionState_STOPPED      SubscriptionState = "STOPPED"
+)
+
+type SubscriptionErrorKind string
+
+const (
+	// SubscriptionError_STREAM is an error LND reported on the stream.
+	SubscriptionError_STREAM SubscriptionErrorKind = "STREAM"
+	// SubscriptionError_CONNECTION is a failure to connect or a dropped
+	// connection. The subscription reconnects on its own.
+	SubscriptionError_CONNECTION SubscriptionErrorKind = "CONNECTION"
+)
+
+// SubscriptionError is reported on InvoiceSubscription.Errors.
+type SubscriptionError struct {
+	Kind SubscriptionErrorKind
+	Err  error
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("invoice subscription %s error: %v", e.Kind, e.Err)
+}
+
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	subscriptionMinBackoff = time.Second
+	subscriptionMaxBackoff = time.Minute
+
+	// subscriptionSettleScan is how many recent invoices are scanned for the
+	// latest settle index when a subscription starts without one.
+	subscriptionSettleScan = 100
+)
+
+// InvoiceSubscription is a SubscribeInvoices stream that survives LND restarts
+// and network failures. It reconnects with exponential backoff and resumes from
+// the last add_index/settle_index it has seen, so invoices added or settled
+// while disconnected are delivered once, and nothing is delivered twice.
+type InvoiceSubscription struct {
+	client   *Client
+	invoices chan Invoice
+	errs     chan *SubscriptionError
+
+	mtx         sync.RWMutex
+	state       SubscriptionState
+	addIndex    uint64
+	settleIndex uint64
+	connectedAt time.Time
+}
+
+// NewInvoiceSubscription creates a subscription starting after the given indexes.
+// With zero indexes it starts at the node's current invoices.
+func NewInvoiceSubscription(client *Client, start SubscribeInvoicesParams) *InvoiceSubscription {
+	return &InvoiceSubscription{
+		client:      client,
+		invoices:    make(chan Invoice),
+		errs:        make(chan *SubscriptionError, 16),
+		state:       SubscriptionState_STOPPED,
+		addIndex:    start.AddIndex,
+		settleIndex: start.SettleIndex,
+	}
+}
+
+// Invoices returns the channel of invoice updates. It is closed when Run returns.
+func (s *InvoiceSubscription) Invoices() <-chan Invoice {
+	return s.invoices
+}
+
+// Errors returns the channel of subscription errors. Errors are dropped when
+// nobody reads them. It is closed when Run returns.
+func (s *InvoiceSubscription) Errors() <-chan *SubscriptionError {
+	return s.errs
+}
+
+// State returns the connection state of the subscription.
+func (s *InvoiceSubscription) State() SubscriptionState {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.state
+}
+
+// ConnectedAt returns when the current connection was established.
+func (s *InvoiceSubscription) ConnectedAt() time.Time {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.connectedAt
+}
+
+// Indexes returns the last add and settle index seen, to resume from later.
+func (s *InvoiceSubscription) Indexes() SubscribeInvoicesParams {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return SubscribeInvoicesParams{AddIndex: s.addIndex, SettleIndex: s.settleIndex}
+}
+
+// Run connects and keeps the subscription alive until ctx is canceled.
+func (s *InvoiceSubscription) Run(ctx context.Context) error {
+	defer close(s.invoices)
+	defer close(s.errs)
+	defer s.setState(SubscriptionState_STOPPED)
+
+	backoff := subscriptionMinBackoff
+	for {
+		s.setState(SubscriptionState_CONNECTING)
+
+		if err := s.initIndexes(ctx); err != nil {
+			s.report(SubscriptionError_CONNECTION, err)
+		} else if stream, err := s.client.subscribe(ctx, subscribeInvoicesPath(s.Indexes()), s.onStreamError); err != nil {
+			s.report(SubscriptionError_CONNECTION, err)
+		} else {
+			s.setConnected()
+			backoff = subscriptionMinBackoff
+			s.consume(ctx, stream)
+			if ctx.Err() == nil {
+				s.report(SubscriptionError_CONNECTION, errors.New("invoice stream closed"))
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		s.setState(SubscriptionState_RECONNECTING)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, subscriptionMaxBackoff)
+	}
+}
+
+// initIndexes fills in missing start indexes from the node's recent invoices,
+// so that a disconnect before the first update doesn't lose anything.
+func (s *InvoiceSubscription) initIndexes(ctx context.Context) error {
+	indexes := s.Indexes()
+	if indexes.AddIndex > 0 && indexes.SettleIndex > 0 {
+		return nil
+	}
+
+	res, err := s.client.ListInvoices(ctx, ListInvoicesParams{
+		NumMaxInvoices: subscriptionSettleScan,
+		Reversed:       true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list invoices: %w", err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, invoice := range res.Invoices {
+		if indexes.AddIndex == 0 {
+			s.addIndex = max(s.addIndex, invoice.AddIndex)
+		}
+		if indexes.SettleIndex == 0 {
+			s.settleIndex = max(s.settleIndex, invoice.SettleIndex)
+		}
+	}
+	return nil
+}
+
+func (s *InvoiceSubscription) consume(ctx context.Context, stream <-chan Invoice) {
+	for invoice := range stream {
+		if !s.advance(invoice) {
+			continue
+		}
+
+		select {
+		case s.invoices <- invoice:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// advance records the indexes of the invoice and reports whether it is new.
+// After a reconnect LND replays the invoices added and settled past the resume
+// indexes, which may include updates that were already delivered. Other state
+// changes, like ACCEPTED or CANCELED, keep the add index of the invoice and
+// are never replayed, so they are always new.
+func (s *InvoiceSubscription) advance(invoice Invoice) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch invoice.State {
+	case InvoiceState_OPEN:
+		if invoice.AddIndex <= s.addIndex {
+			return false
+		}
+		s.addIndex = invoice.AddIndex
+	case InvoiceState_SETTLED:
+		if invoice.SettleIndex <= s.settleIndex {
+			return false
+		}
+		s.settleIndex = invoice.SettleIndex
+		s.addIndex = max(s.addIndex, invoice.AddIndex)
+	}
+	return true
+}
+
+func (s *InvoiceSubscription) onStreamError(err error) {
+	var streamErr *StreamError
+	if errors.As(err, &streamErr) {
+		s.report(SubscriptionError_STREAM, err)
+		return
+	}
+	s.report(SubscriptionError_CONNECTION, err)
+}
+
+func (s *InvoiceSubscription) report(kind SubscriptionErrorKind, err error) {
+	select {
+	case s.errs <- &SubscriptionError{Kind: kind, Err: err}:
+	default:
+		slog.Warn("Dropped invoice subscription error", "kind", kind, "error", err)
+	}
+}
+
+func (s *InvoiceSubscription) setState(state SubscriptionState) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.state = state
+}
+
+func (s *InvoiceSubscription) setConnected() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.state = SubscriptionState_CONNECTED
+	s.connectedAt = time.Now()
+}
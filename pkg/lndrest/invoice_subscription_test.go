@@ -0,0 +1,159 @@
+package lndrest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceSubscription(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/invoices":
+			// Start indexes lookup.
+			w.Write([]byte(`{"invoices":[{"add_index":"4","settle_index":"1","state":"SETTLED"},{"add_index":"3","state":"OPEN"}]}`))
+			return
+		case "/v1/invoices/subscribe":
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		switch connections.Add(1) {
+		case 1:
+			assert.Equal(t, "4", r.URL.Query().Get("add_index"))
+			assert.Equal(t, "1", r.URL.Query().Get("settle_index"))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"add_index":"5","state":"OPEN"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"add_index":"3","settle_index":"2","state":"SETTLED"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"error":{"message":"boom"}}`))
+			// Drop the connection like an LND restart would.
+		case 2:
+			assert.Equal(t, "5", r.URL.Query().Get("add_index"))
+			assert.Equal(t, "2", r.URL.Query().Get("settle_index"))
+			// Replayed updates that were already delivered.
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"add_index":"5","state":"OPEN"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"add_index":"3","settle_index":"2","state":"SETTLED"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"result":{"add_index":"5","settle_index":"3","state":"SETTLED"}}`))
+			conn.ReadMessage() // Block until the client goes away.
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sub := NewInvoiceSubscription(client, SubscribeInvoicesParams{})
+	done := make(chan error, 1)
+	go func() { done <- sub.Run(ctx) }()
+
+	var got []Invoice
+	for len(got) < 3 {
+		select {
+		case invoice := <-sub.Invoices():
+			got = append(got, invoice)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for invoices")
+		}
+	}
+
+	assert.Equal(t, uint64(5), got[0].AddIndex)
+	assert.Equal(t, InvoiceState_OPEN, got[0].State)
+	assert.Equal(t, uint64(2), got[1].SettleIndex)
+	assert.Equal(t, uint64(3), got[2].SettleIndex)
+	assert.Equal(t, SubscriptionState_CONNECTED, sub.State())
+	assert.Equal(t, SubscribeInvoicesParams{AddIndex: 5, SettleIndex: 3}, sub.Indexes())
+
+	var kinds []SubscriptionErrorKind
+	for len(kinds) < 2 {
+		subErr := <-sub.Errors()
+		kinds = append(kinds, subErr.Kind)
+	}
+	assert.Equal(t, SubscriptionError_STREAM, kinds[0])
+	assert.Equal(t, SubscriptionError_CONNECTION, kinds[1])
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, SubscriptionState_STOPPED, sub.State())
+}
+
+// streamInvoices runs a subscription against a node that sends messages and
+// returns the first n invoices it delivers.
+func streamInvoices(t *testing.T, n int, messages ...string) []Invoice {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/invoices" {
+			w.Write([]byte(`{"invoices":[{"add_index":"1","settle_index":"1","state":"SETTLED"}]}`))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		for _, message := range messages {
+			conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+		conn.ReadMessage() // Block until the client goes away.
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sub := NewInvoiceSubscription(client, SubscribeInvoicesParams{})
+	go sub.Run(ctx)
+
+	var got []Invoice
+	for len(got) < n {
+		select {
+		case invoice := <-sub.Invoices():
+			got = append(got, invoice)
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d of %d invoices", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestInvoiceSubscriptionCanceled(t *testing.T) {
+	got := streamInvoices(t, 2,
+		`{"result":{"add_index":"2","state":"OPEN"}}`,
+		`{"result":{"add_index":"2","state":"CANCELED"}}`,
+	)
+
+	assert.Equal(t, InvoiceState_OPEN, got[0].State)
+	assert.Equal(t, InvoiceState_CANCELED, got[1].State)
+	assert.Equal(t, uint64(2), got[1].AddIndex)
+}
+
+func TestInvoiceSubscriptionAccepted(t *testing.T) {
+	got := streamInvoices(t, 3,
+		`{"result":{"add_index":"2","state":"OPEN"}}`,
+		`{"result":{"add_index":"2","state":"ACCEPTED"}}`,
+		`{"result":{"add_index":"2","settle_index":"2","state":"SETTLED"}}`,
+	)
+
+	assert.Equal(t, InvoiceState_OPEN, got[0].State)
+	assert.Equal(t, InvoiceState_ACCEPTED, got[1].State)
+	assert.Equal(t, InvoiceState_SETTLED, got[2].State)
+	assert.Equal(t, uint64(2), got[2].SettleIndex)
+}
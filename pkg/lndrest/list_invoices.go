@@ -0,0 +1,52 @@
+package lndrest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListInvoicesParams holds the parameters for ListInvoices.
+type ListInvoicesParams struct {
+	PendingOnly    bool
+	IndexOffset    uint64
+	NumMaxInvoices uint64
+	Reversed       bool
+}
+
+// ListInvoicesResponse is a page of invoices.
+type ListInvoicesResponse struct {
+	Invoices         []Invoice `json:"invoices"`
+	FirstIndexOffset uint64    `json:"first_index_offset,string,omitempty"`
+	LastIndexOffset  uint64    `json:"last_index_offset,string,omitempty"`
+}
+
+// ListInvoices lists invoices ordered by add index.
+func (c *Client) ListInvoices(ctx context.Context, params ListInvoicesParams) (ListInvoicesResponse, error) {
+	q := url.Values{}
+	if params.PendingOnly {
+		q.Set("pending_only", "true")
+	}
+	if params.IndexOffset > 0 {
+		q.Set("index_offset", strconv.FormatUint(params.IndexOffset, 10))
+	}
+	if params.NumMaxInvoices > 0 {
+		q.Set("num_max_invoices", strconv.FormatUint(params.NumMaxInvoices, 10))
+	}
+	if params.Reversed {
+		q.Set("reversed", "true")
+	}
+
+	path := "/v1/invoices"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var res ListInvoicesResponse
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return ListInvoicesResponse{}, err
+	}
+
+	return res, nil
+}
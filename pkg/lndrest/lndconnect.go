@@ -0,0 +1,81 @@
+package lndrest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LNDConnect holds the connection details from an lndconnect URI.
+type LNDConnect struct {
+	// Host is the LND REST host:port.
+	Host string
+	// Cert is the DER encoded TLS certificate, if the URI carries one.
+	Cert []byte
+	// Macaroon is the raw macaroon.
+	Macaroon []byte
+}
+
+// ParseLNDConnectURI parses an lndconnect://host:port?cert=...&macaroon=... URI.
+// The cert and macaroon are base64url encoded; the cert may be omitted when LND
+// uses a certificate trusted by the system.
+func ParseLNDConnectURI(uri string) (LNDConnect, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return LNDConnect{}, fmt.Errorf("invalid lndconnect URI: %w", err)
+	}
+
+	if u.Scheme != "lndconnect" {
+		return LNDConnect{}, fmt.Errorf("invalid lndconnect URI: unexpected scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return LNDConnect{}, fmt.Errorf("invalid lndconnect URI: missing host")
+	}
+
+	q := u.Query()
+
+	macaroonParam := q.Get("macaroon")
+	if macaroonParam == "" {
+		return LNDConnect{}, fmt.Errorf("invalid lndconnect URI: missing macaroon")
+	}
+	macaroon, err := decodeBase64URL(macaroonParam)
+	if err != nil {
+		return LNDConnect{}, fmt.Errorf("invalid lndconnect macaroon: %w", err)
+	}
+
+	lc := LNDConnect{
+		Host:     u.Host,
+		Macaroon: macaroon,
+	}
+
+	if certParam := q.Get("cert"); certParam != "" {
+		lc.Cert, err = decodeBase64URL(certParam)
+		if err != nil {
+			return LNDConnect{}, fmt.Errorf("invalid lndconnect cert: %w", err)
+		}
+	}
+
+	return lc, nil
+}
+
+// NewClient creates an LND client for the connection. A certificate in the URI
+// is pinned, so it is trusted regardless of the host name it was issued for.
+// Without one, LND's certificate must be trusted by the system.
+func (lc LNDConnect) NewClient(opts ...ClientOption) (*Client, error) {
+	if lc.Cert != nil {
+		fingerprint := sha256.Sum256(lc.Cert)
+		opts = append(opts, WithCertFingerprint(hex.EncodeToString(fingerprint[:])))
+	} else {
+		opts = append(opts, WithSystemRoots())
+	}
+
+	return NewClient(lc.Host, hex.EncodeToString(lc.Macaroon), "", opts...)
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	// Some generators keep the padding, others don't.
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,92 @@
+package lndrest
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLNDConnectURI(t *testing.T) {
+	cert := []byte{0x30, 0x82, 0x01, 0xfe, 0xff}
+	macaroon := []byte{0x02, 0x01, 0x03, 0xfb}
+
+	t.Run("with cert and macaroon", func(t *testing.T) {
+		uri := "lndconnect://node.example.com:8080?cert=" + base64.RawURLEncoding.EncodeToString(cert) +
+			"&macaroon=" + base64.RawURLEncoding.EncodeToString(macaroon)
+
+		lc, err := ParseLNDConnectURI(uri)
+		require.NoError(t, err)
+		assert.Equal(t, "node.example.com:8080", lc.Host)
+		assert.Equal(t, cert, lc.Cert)
+		assert.Equal(t, macaroon, lc.Macaroon)
+	})
+
+	t.Run("padded base64url without cert", func(t *testing.T) {
+		uri := "lndconnect://10.0.0.2:8080?macaroon=" + base64.URLEncoding.EncodeToString(macaroon)
+
+		lc, err := ParseLNDConnectURI(uri)
+		require.NoError(t, err)
+		assert.Nil(t, lc.Cert)
+		assert.Equal(t, macaroon, lc.Macaroon)
+	})
+
+	for name, uri := range map[string]string{
+		"wrong scheme":     "https://node.example.com:8080?macaroon=AgED",
+		"missing host":     "lndconnect://?macaroon=AgED",
+		"missing macaroon": "lndconnect://node.example.com:8080",
+		"invalid cert":     "lndconnect://node.example.com:8080?macaroon=AgED&cert=!!",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseLNDConnectURI(uri)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLNDConnectNewClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "020103fb", r.Header.Get("Grpc-Metadata-macaroon"))
+		w.Write([]byte(`{"alias":"pororo","synced_to_chain":true}`))
+	}))
+	defer server.Close()
+
+	lc := LNDConnect{
+		Host:     strings.TrimPrefix(server.URL, "https://"),
+		Cert:     server.Certificate().Raw,
+		Macaroon: []byte{0x02, 0x01, 0x03, 0xfb},
+	}
+
+	client, err := lc.NewClient()
+	require.NoError(t, err)
+
+	res, err := client.GetInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "pororo", res.Alias)
+	assert.True(t, res.SyncedToChain)
+}
+
+func TestLNDConnectNewClientWithoutCert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the macaroon was sent to a server with an untrusted certificate")
+	}))
+	defer server.Close()
+
+	lc := LNDConnect{
+		Host:     strings.TrimPrefix(server.URL, "https://"),
+		Macaroon: []byte{0x02, 0x01, 0x03, 0xfb},
+	}
+
+	client, err := lc.NewClient()
+	require.NoError(t, err)
+
+	// The self-signed test certificate isn't trusted by the system.
+	_, err = client.GetInfo(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+}
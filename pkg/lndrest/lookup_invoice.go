@@ -0,0 +1,26 @@
+package lndrest
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// ErrInvoiceNotFound is returned by LookupInvoice for a payment hash LND does
+// not know, e.g. a canceled invoice it has since deleted.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// LookupInvoice returns the invoice with the given payment hash.
+func (c *Client) LookupInvoice(ctx context.Context, paymentHash []byte) (Invoice, error) {
+	var res Invoice
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/invoice/"+hex.EncodeToString(paymentHash), nil, &res); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return Invoice{}, ErrInvoiceNotFound
+		}
+		return Invoice{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package lndrest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookupInvoice(t *testing.T) {
+	t.Run("settled invoice", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/v1/invoice/0102ff", r.URL.Path)
+			assert.Equal(t, "macaroon", r.Header.Get("Grpc-Metadata-macaroon"))
+
+			w.Write([]byte(`{"r_hash":"AQL/","state":"SETTLED","amt_paid_msat":"5000","settle_date":"1700000000"}`))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		invoice, err := client.LookupInvoice(context.Background(), []byte{0x01, 0x02, 0xff})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x01, 0x02, 0xff}, invoice.RHash)
+		assert.Equal(t, InvoiceState_SETTLED, invoice.State)
+		assert.Equal(t, int64(5000), invoice.AmtPaidMsat)
+		assert.Equal(t, int64(1700000000), invoice.SettleDate)
+	})
+
+	t.Run("LND API error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		_, err = client.LookupInvoice(context.Background(), []byte{0x01})
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.NotErrorIs(t, err, ErrInvoiceNotFound)
+	})
+
+	t.Run("unknown invoice", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 5, "message": "there are no existing invoices"})
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		_, err = client.LookupInvoice(context.Background(), []byte{0x01})
+		assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	})
+}
@@ -0,0 +1,187 @@
+package lndrest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MacaroonPermission is an entity/action pair granted by an LND macaroon,
+// e.g. invoices:write.
+type MacaroonPermission struct {
+	Entity string
+	Action string
+}
+
+func (p MacaroonPermission) String() string {
+	return p.Entity + ":" + p.Action
+}
+
+// DecodeMacaroon decodes a macaroon given as hex or base64.
+func DecodeMacaroon(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	if mac, err := hex.DecodeString(s); err == nil && len(mac) > 0 && mac[0] == macaroonVersion2 {
+		return mac, nil
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if mac, err := enc.DecodeString(s); err == nil && len(mac) > 0 {
+			return mac, nil
+		}
+	}
+
+	return nil, fmt.Errorf("macaroon is neither hex nor base64 encoded")
+}
+
+const (
+	macaroonVersion2 = 0x02
+
+	macaroonFieldEOS        = 0
+	macaroonFieldIdentifier = 2
+
+	// bakeryIDVersion3 prefixes the protobuf encoded identifiers used by LND.
+	bakeryIDVersion3 = 0x03
+)
+
+// MacaroonPermissions returns the permissions baked into an LND macaroon.
+// Only the identifier is inspected; caveats may restrict the macaroon further.
+func MacaroonPermissions(mac []byte) ([]MacaroonPermission, error) {
+	id, err := macaroonIdentifier(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) == 0 || id[0] != bakeryIDVersion3 {
+		return nil, fmt.Errorf("unsupported macaroon identifier version")
+	}
+
+	// message MacaroonId { bytes nonce = 1; bytes storageId = 2; repeated Op ops = 3; }
+	var perms []MacaroonPermission
+	err = walkProto(id[1:], func(field int, value []byte) error {
+		if field != 3 {
+			return nil
+		}
+
+		// message Op { string entity = 1; repeated string actions = 2; }
+		var entity string
+		var actions []string
+		err := walkProto(value, func(field int, value []byte) error {
+			switch field {
+			case 1:
+				entity = string(value)
+			case 2:
+				actions = append(actions, string(value))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, action := range actions {
+			perms = append(perms, MacaroonPermission{Entity: entity, Action: action})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode macaroon identifier: %w", err)
+	}
+
+	return perms, nil
+}
+
+// MissingPermissions returns the required permissions that are not granted.
+func MissingPermissions(granted, required []MacaroonPermission) []MacaroonPermission {
+	have := make(map[MacaroonPermission]bool, len(granted))
+	for _, p := range granted {
+		have[p] = true
+	}
+
+	var missing []MacaroonPermission
+	for _, p := range required {
+		if !have[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// macaroonIdentifier extracts the identifier from a binary (v2) macaroon.
+func macaroonIdentifier(mac []byte) ([]byte, error) {
+	if len(mac) == 0 || mac[0] != macaroonVersion2 {
+		return nil, fmt.Errorf("unsupported macaroon format")
+	}
+
+	data := mac[1:]
+	for len(data) > 0 {
+		fieldType, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed macaroon")
+		}
+		data = data[n:]
+
+		if fieldType == macaroonFieldEOS {
+			break
+		}
+
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, fmt.Errorf("malformed macaroon")
+		}
+		value := data[n : n+int(length)]
+		data = data[n+int(length):]
+
+		if fieldType == macaroonFieldIdentifier {
+			return value, nil
+		}
+	}
+
+	return nil, fmt.Errorf("macaroon has no identifier")
+}
+
+// walkProto calls fn for every length-delimited field of a protobuf message,
+// skipping fields of other wire types.
+func walkProto(data []byte, fn func(field int, value []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("malformed protobuf tag")
+		}
+		data = data[n:]
+
+		field, wireType := int(tag>>3), tag&0x7
+		switch wireType {
+		case 0: // varint
+			_, n := binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("malformed protobuf varint")
+			}
+			data = data[n:]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return fmt.Errorf("malformed protobuf fixed64")
+			}
+			data = data[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("malformed protobuf field")
+			}
+			if err := fn(field, data[n:n+int(length)]); err != nil {
+				return err
+			}
+			data = data[n+int(length):]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return fmt.Errorf("malformed protobuf fixed32")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package lndrest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildMacaroon encodes a minimal v2 macaroon with an LND style identifier.
+func buildMacaroon(ops map[string][]string) []byte {
+	protoField := func(field int, value []byte) []byte {
+		b := binary.AppendUvarint(nil, uint64(field<<3|2))
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		return append(b, value...)
+	}
+	macField := func(fieldType int, value []byte) []byte {
+		b := binary.AppendUvarint(nil, uint64(fieldType))
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		return append(b, value...)
+	}
+
+	id := []byte{bakeryIDVersion3}
+	id = append(id, protoField(1, []byte("0123456789abcdef"))...)
+	id = append(id, protoField(2, []byte("0"))...)
+	for entity, actions := range ops {
+		op := protoField(1, []byte(entity))
+		for _, action := range actions {
+			op = append(op, protoField(2, []byte(action))...)
+		}
+		id = append(id, protoField(3, op)...)
+	}
+
+	mac := []byte{macaroonVersion2}
+	mac = append(mac, macField(1, []byte("lnd"))...)
+	mac = append(mac, macField(2, id)...)
+	mac = append(mac, macaroonFieldEOS, macaroonFieldEOS)
+	mac = append(mac, macField(6, make([]byte, 32))...)
+	return mac
+}
+
+func TestMacaroonPermissions(t *testing.T) {
+	mac := buildMacaroon(map[string][]string{
+		"invoices": {"read", "write"},
+		"address":  {"read"},
+	})
+
+	perms, err := MacaroonPermissions(mac)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []MacaroonPermission{
+		{Entity: "invoices", Action: "read"},
+		{Entity: "invoices", Action: "write"},
+		{Entity: "address", Action: "read"},
+	}, perms)
+
+	missing := MissingPermissions(perms, []MacaroonPermission{
+		{Entity: "invoices", Action: "write"},
+		{Entity: "offchain", Action: "write"},
+	})
+	assert.Equal(t, []MacaroonPermission{{Entity: "offchain", Action: "write"}}, missing)
+	assert.Equal(t, "offchain:write", missing[0].String())
+}
+
+func TestMacaroonPermissionsInvalid(t *testing.T) {
+	_, err := MacaroonPermissions([]byte("not a macaroon"))
+	require.Error(t, err)
+
+	_, err = MacaroonPermissions([]byte{macaroonVersion2, 2, 10, 1})
+	require.Error(t, err)
+}
+
+func TestDecodeMacaroon(t *testing.T) {
+	mac := buildMacaroon(map[string][]string{"invoices": {"read"}})
+
+	for name, encoded := range map[string]string{
+		"hex":        hex.EncodeToString(mac),
+		"base64":     base64.StdEncoding.EncodeToString(mac),
+		"base64 url": base64.RawURLEncoding.EncodeToString(mac),
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := DecodeMacaroon(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, mac, decoded)
+		})
+	}
+
+	_, err := DecodeMacaroon("not a macaroon!")
+	require.Error(t, err)
+}
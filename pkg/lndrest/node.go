@@ -0,0 +1,154 @@
+package lndrest
+
+type Chain struct {
+	Chain   string `json:"chain,omitempty"`
+	Network string `json:"network,omitempty"`
+}
+
+// GetInfoResponse describes the LND node.
+type GetInfoResponse struct {
+	IdentityPubkey      string   `json:"identity_pubkey,omitempty"`
+	Alias               string   `json:"alias,omitempty"`
+	Color               string   `json:"color,omitempty"`
+	Version             string   `json:"version,omitempty"`
+	CommitHash          string   `json:"commit_hash,omitempty"`
+	NumPendingChannels  uint32   `json:"num_pending_channels,omitempty"`
+	NumActiveChannels   uint32   `json:"num_active_channels,omitempty"`
+	NumInactiveChannels uint32   `json:"num_inactive_channels,omitempty"`
+	NumPeers            uint32   `json:"num_peers,omitempty"`
+	BlockHeight         uint32   `json:"block_height,omitempty"`
+	BlockHash           string   `json:"block_hash,omitempty"`
+	BestHeaderTimestamp int64    `json:"best_header_timestamp,string,omitempty"`
+	SyncedToChain       bool     `json:"synced_to_chain,omitempty"`
+	SyncedToGraph       bool     `json:"synced_to_graph,omitempty"`
+	Chains              []Chain  `json:"chains,omitempty"`
+	URIs                []string `json:"uris,omitempty"`
+}
+
+// Amount is a balance expressed both in sats and msats.
+type Amount struct {
+	Sat  uint64 `json:"sat,string,omitempty"`
+	Msat uint64 `json:"msat,string,omitempty"`
+}
+
+// ChannelBalanceResponse is the balance across all open channels.
+type ChannelBalanceResponse struct {
+	LocalBalance             Amount `json:"local_balance"`
+	RemoteBalance            Amount `json:"remote_balance"`
+	UnsettledLocalBalance    Amount `json:"unsettled_local_balance"`
+	UnsettledRemoteBalance   Amount `json:"unsettled_remote_balance"`
+	PendingOpenLocalBalance  Amount `json:"pending_open_local_balance"`
+	PendingOpenRemoteBalance Amount `json:"pending_open_remote_balance"`
+}
+
+// WalletBalanceResponse is the on-chain wallet balance in sats.
+type WalletBalanceResponse struct {
+	TotalBalance              int64 `json:"total_balance,string,omitempty"`
+	ConfirmedBalance          int64 `json:"confirmed_balance,string,omitempty"`
+	UnconfirmedBalance        int64 `json:"unconfirmed_balance,string,omitempty"`
+	LockedBalance             int64 `json:"locked_balance,string,omitempty"`
+	ReservedBalanceAnchorChan int64 `json:"reserved_balance_anchor_chan,string,omitempty"`
+}
+
+// Channel is an open channel. Balances are in sats.
+type Channel struct {
+	Active                bool   `json:"active,omitempty"`
+	RemotePubkey          string `json:"remote_pubkey,omitempty"`
+	PeerAlias             string `json:"peer_alias,omitempty"`
+	ChannelPoint          string `json:"channel_point,omitempty"`
+	ChanID                uint64 `json:"chan_id,string,omitempty"`
+	Capacity              int64  `json:"capacity,string,omitempty"`
+	LocalBalance          int64  `json:"local_balance,string,omitempty"`
+	RemoteBalance         int64  `json:"remote_balance,string,omitempty"`
+	CommitFee             int64  `json:"commit_fee,string,omitempty"`
+	UnsettledBalance      int64  `json:"unsettled_balance,string,omitempty"`
+	TotalSatoshisSent     int64  `json:"total_satoshis_sent,string,omitempty"`
+	TotalSatoshisReceived int64  `json:"total_satoshis_received,string,omitempty"`
+	LocalChanReserveSat   int64  `json:"local_chan_reserve_sat,string,omitempty"`
+	RemoteChanReserveSat  int64  `json:"remote_chan_reserve_sat,string,omitempty"`
+	Private               bool   `json:"private,omitempty"`
+	Initiator             bool   `json:"initiator,omitempty"`
+}
+
+// ListChannelsParams filters the channels returned by ListChannels.
+type ListChannelsParams struct {
+	ActiveOnly   bool
+	InactiveOnly bool
+	PublicOnly   bool
+	PrivateOnly  bool
+}
+
+// PendingChannel is a channel that is being opened or closed. Balances are in sats.
+type PendingChannel struct {
+	RemoteNodePub        string `json:"remote_node_pub,omitempty"`
+	ChannelPoint         string `json:"channel_point,omitempty"`
+	Capacity             int64  `json:"capacity,string,omitempty"`
+	LocalBalance         int64  `json:"local_balance,string,omitempty"`
+	RemoteBalance        int64  `json:"remote_balance,string,omitempty"`
+	LocalChanReserveSat  int64  `json:"local_chan_reserve_sat,string,omitempty"`
+	RemoteChanReserveSat int64  `json:"remote_chan_reserve_sat,string,omitempty"`
+	Initiator            string `json:"initiator,omitempty"`
+	Private              bool   `json:"private,omitempty"`
+}
+
+type PendingOpenChannel struct {
+	Channel   PendingChannel `json:"channel"`
+	CommitFee int64          `json:"commit_fee,string,omitempty"`
+}
+
+type WaitingCloseChannel struct {
+	Channel      PendingChannel `json:"channel"`
+	LimboBalance int64          `json:"limbo_balance,string,omitempty"`
+	ClosingTxid  string         `json:"closing_txid,omitempty"`
+}
+
+type ForceClosedChannel struct {
+	Channel           PendingChannel `json:"channel"`
+	ClosingTxid       string         `json:"closing_txid,omitempty"`
+	LimboBalance      int64          `json:"limbo_balance,string,omitempty"`
+	MaturityHeight    uint32         `json:"maturity_height,omitempty"`
+	BlocksTilMaturity int32          `json:"blocks_til_maturity,omitempty"`
+	RecoveredBalance  int64          `json:"recovered_balance,string,omitempty"`
+}
+
+// PendingChannelsResponse lists channels that are not fully open or closed yet.
+type PendingChannelsResponse struct {
+	TotalLimboBalance           int64                 `json:"total_limbo_balance,string,omitempty"`
+	PendingOpenChannels         []PendingOpenChannel  `json:"pending_open_channels,omitempty"`
+	PendingForceClosingChannels []ForceClosedChannel  `json:"pending_force_closing_channels,omitempty"`
+	WaitingCloseChannels        []WaitingCloseChannel `json:"waiting_close_channels,omitempty"`
+}
+
+// InboundLiquidity is how much the node can currently receive over its active channels.
+type InboundLiquidity struct {
+	// TotalMsat is the sum over all active channels, receivable with multi-part payments.
+	TotalMsat int64 `json:"total_msat"`
+	// MaxChannelMsat is the most any single channel can receive, the limit for
+	// senders that can't split payments.
+	MaxChannelMsat int64 `json:"max_channel_msat"`
+}
+
+// ComputeInboundLiquidity computes the inbound liquidity of the given channels.
+// The remote side can't spend below its channel reserve, so that part of the
+// remote balance is not counted.
+func ComputeInboundLiquidity(channels []Channel) InboundLiquidity {
+	var liquidity InboundLiquidity
+	for _, ch := range channels {
+		if !ch.Active {
+			continue
+		}
+
+		receivable := ch.RemoteBalance - ch.RemoteChanReserveSat
+		if receivable <= 0 {
+			continue
+		}
+
+		receivableMsat := receivable * 1000
+		liquidity.TotalMsat += receivableMsat
+		if receivableMsat > liquidity.MaxChannelMsat {
+			liquidity.MaxChannelMsat = receivableMsat
+		}
+	}
+
+	return liquidity
+}
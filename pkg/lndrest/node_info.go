@@ -0,0 +1,90 @@
+package lndrest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GetInfo returns general information about the LND node.
+func (c *Client) GetInfo(ctx context.Context) (GetInfoResponse, error) {
+	var res GetInfoResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/getinfo", nil, &res); err != nil {
+		return GetInfoResponse{}, err
+	}
+
+	return res, nil
+}
+
+// ChannelBalance returns the balance across all open channels.
+func (c *Client) ChannelBalance(ctx context.Context) (ChannelBalanceResponse, error) {
+	var res ChannelBalanceResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/balance/channels", nil, &res); err != nil {
+		return ChannelBalanceResponse{}, err
+	}
+
+	return res, nil
+}
+
+// WalletBalance returns the on-chain wallet balance.
+func (c *Client) WalletBalance(ctx context.Context) (WalletBalanceResponse, error) {
+	var res WalletBalanceResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/balance/blockchain", nil, &res); err != nil {
+		return WalletBalanceResponse{}, err
+	}
+
+	return res, nil
+}
+
+// ListChannels returns the node's open channels.
+func (c *Client) ListChannels(ctx context.Context, params ListChannelsParams) ([]Channel, error) {
+	q := url.Values{}
+	if params.ActiveOnly {
+		q.Set("active_only", "true")
+	}
+	if params.InactiveOnly {
+		q.Set("inactive_only", "true")
+	}
+	if params.PublicOnly {
+		q.Set("public_only", "true")
+	}
+	if params.PrivateOnly {
+		q.Set("private_only", "true")
+	}
+
+	path := "/v1/channels"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var res struct {
+		Channels []Channel `json:"channels"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Channels, nil
+}
+
+// PendingChannels returns channels that are being opened or closed.
+func (c *Client) PendingChannels(ctx context.Context) (PendingChannelsResponse, error) {
+	var res PendingChannelsResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/channels/pending", nil, &res); err != nil {
+		return PendingChannelsResponse{}, err
+	}
+
+	return res, nil
+}
+
+// InboundLiquidity returns how much the node can currently receive.
+// Invoices above MaxChannelMsat can only be paid by senders that split payments,
+// and invoices above TotalMsat can't be paid at all.
+func (c *Client) InboundLiquidity(ctx context.Context) (InboundLiquidity, error) {
+	channels, err := c.ListChannels(ctx, ListChannelsParams{ActiveOnly: true})
+	if err != nil {
+		return InboundLiquidity{}, err
+	}
+
+	return ComputeInboundLiquidity(channels), nil
+}
@@ -0,0 +1,105 @@
+package lndrest
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/getinfo", r.URL.Path)
+		assert.Equal(t, "macaroon", r.Header.Get("Grpc-Metadata-macaroon"))
+
+		w.Write([]byte(`{"identity_pubkey":"02abc","alias":"pororo","num_active_channels":3,"block_height":850000,"best_header_timestamp":"1700000000","synced_to_chain":true,"chains":[{"chain":"bitcoin","network":"mainnet"}],"version":"0.18.0-beta"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	res, err := client.GetInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "02abc", res.IdentityPubkey)
+	assert.Equal(t, "pororo", res.Alias)
+	assert.Equal(t, uint32(3), res.NumActiveChannels)
+	assert.Equal(t, uint32(850000), res.BlockHeight)
+	assert.Equal(t, int64(1700000000), res.BestHeaderTimestamp)
+	assert.True(t, res.SyncedToChain)
+	assert.Equal(t, []Chain{{Chain: "bitcoin", Network: "mainnet"}}, res.Chains)
+}
+
+func TestBalances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/balance/channels":
+			w.Write([]byte(`{"local_balance":{"sat":"1000","msat":"1000000"},"remote_balance":{"sat":"2000","msat":"2000000"}}`))
+		case "/v1/balance/blockchain":
+			w.Write([]byte(`{"total_balance":"5000","confirmed_balance":"4000","unconfirmed_balance":"1000"}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	channelBalance, err := client.ChannelBalance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), channelBalance.LocalBalance.Sat)
+	assert.Equal(t, uint64(2000000), channelBalance.RemoteBalance.Msat)
+
+	walletBalance, err := client.WalletBalance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), walletBalance.TotalBalance)
+	assert.Equal(t, int64(4000), walletBalance.ConfirmedBalance)
+	assert.Equal(t, int64(1000), walletBalance.UnconfirmedBalance)
+}
+
+func TestPendingChannels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/channels/pending", r.URL.Path)
+		w.Write([]byte(`{"total_limbo_balance":"300","pending_open_channels":[{"channel":{"remote_node_pub":"03def","capacity":"100000","initiator":"INITIATOR_LOCAL"},"commit_fee":"200"}],"pending_force_closing_channels":[{"channel":{"capacity":"50000"},"limbo_balance":"300","blocks_til_maturity":12}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	res, err := client.PendingChannels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), res.TotalLimboBalance)
+	require.Len(t, res.PendingOpenChannels, 1)
+	assert.Equal(t, "03def", res.PendingOpenChannels[0].Channel.RemoteNodePub)
+	assert.Equal(t, int64(100000), res.PendingOpenChannels[0].Channel.Capacity)
+	require.Len(t, res.PendingForceClosingChannels, 1)
+	assert.Equal(t, int32(12), res.PendingForceClosingChannels[0].BlocksTilMaturity)
+}
+
+func TestInboundLiquidity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/channels", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("active_only"))
+
+		w.Write([]byte(`{"channels":[
+			{"active":true,"chan_id":"1","capacity":"100000","local_balance":"40000","remote_balance":"60000","remote_chan_reserve_sat":"1000"},
+			{"active":true,"chan_id":"2","capacity":"50000","local_balance":"20000","remote_balance":"30000","remote_chan_reserve_sat":"500"},
+			{"active":true,"chan_id":"3","capacity":"20000","local_balance":"19500","remote_balance":"400","remote_chan_reserve_sat":"500"},
+			{"active":false,"chan_id":"4","capacity":"90000","remote_balance":"90000"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	liquidity, err := client.InboundLiquidity(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64((59000+29500)*1000), liquidity.TotalMsat)
+	assert.Equal(t, int64(59000*1000), liquidity.MaxChannelMsat)
+}
@@ -0,0 +1,60 @@
+package lndrest
+
+type PaymentStatus string
+
+const (
+	PaymentStatus_UNKNOWN   PaymentStatus = "UNKNOWN"
+	PaymentStatus_INITIATED PaymentStatus = "INITIATED"
+	PaymentStatus_IN_FLIGHT PaymentStatus = "IN_FLIGHT"
+	PaymentStatus_SUCCEEDED PaymentStatus = "SUCCEEDED"
+	PaymentStatus_FAILED    PaymentStatus = "FAILED"
+)
+
+// IsFinal reports whether the payment can no longer change state.
+func (s PaymentStatus) IsFinal() bool {
+	return s == PaymentStatus_SUCCEEDED || s == PaymentStatus_FAILED
+}
+
+type PaymentFailureReason string
+
+const (
+	PaymentFailureReason_NONE                      PaymentFailureReason = "FAILURE_REASON_NONE"
+	PaymentFailureReason_TIMEOUT                   PaymentFailureReason = "FAILURE_REASON_TIMEOUT"
+	PaymentFailureReason_NO_ROUTE                  PaymentFailureReason = "FAILURE_REASON_NO_ROUTE"
+	PaymentFailureReason_ERROR                     PaymentFailureReason = "FAILURE_REASON_ERROR"
+	PaymentFailureReason_INCORRECT_PAYMENT_DETAILS PaymentFailureReason = "FAILURE_REASON_INCORRECT_PAYMENT_DETAILS"
+	PaymentFailureReason_INSUFFICIENT_BALANCE      PaymentFailureReason = "FAILURE_REASON_INSUFFICIENT_BALANCE"
+	PaymentFailureReason_CANCELED                  PaymentFailureReason = "FAILURE_REASON_CANCELED"
+)
+
+// Payment is an outgoing payment as reported by LND.
+// PaymentHash and PaymentPreimage are hex encoded.
+type Payment struct {
+	PaymentHash     string               `json:"payment_hash,omitempty"`
+	PaymentPreimage string               `json:"payment_preimage,omitempty"`
+	PaymentRequest  string               `json:"payment_request,omitempty"`
+	ValueSat        int64                `json:"value_sat,string,omitempty"`
+	ValueMsat       int64                `json:"value_msat,string,omitempty"`
+	FeeSat          int64                `json:"fee_sat,string,omitempty"`
+	FeeMsat         int64                `json:"fee_msat,string,omitempty"`
+	CreationDate    int64                `json:"creation_date,string,omitempty"`
+	CreationTimeNs  int64                `json:"creation_time_ns,string,omitempty"`
+	PaymentIndex    uint64               `json:"payment_index,string,omitempty"`
+	Status          PaymentStatus        `json:"status,omitempty"`
+	FailureReason   PaymentFailureReason `json:"failure_reason,omitempty"`
+}
+
+// PayReq is a decoded BOLT11 payment request.
+type PayReq struct {
+	Destination     string `json:"destination,omitempty"`
+	PaymentHash     string `json:"payment_hash,omitempty"`
+	NumSatoshis     int64  `json:"num_satoshis,string,omitempty"`
+	NumMsat         int64  `json:"num_msat,string,omitempty"`
+	Timestamp       int64  `json:"timestamp,string,omitempty"`
+	Expiry          int64  `json:"expiry,string,omitempty"`
+	Description     string `json:"description,omitempty"`
+	DescriptionHash string `json:"description_hash,omitempty"`
+	FallbackAddr    string `json:"fallback_addr,omitempty"`
+	CltvExpiry      int64  `json:"cltv_expiry,string,omitempty"`
+	PaymentAddr     []byte `json:"payment_addr,omitempty"`
+}
@@ -0,0 +1,178 @@
+package lndrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// SendPaymentParams holds the parameters for SendPaymentV2.
+type SendPaymentParams struct {
+	PaymentRequest string `json:"payment_request"`
+	// AmtMsat is only used for invoices that don't specify an amount.
+	AmtMsat      int64 `json:"amt_msat,omitempty"`
+	FeeLimitSat  int64 `json:"fee_limit_sat,omitempty"`
+	FeeLimitMsat int64 `json:"fee_limit_msat,omitempty"`
+	// TimeoutSeconds is the upper limit on how long LND tries to find a route.
+	TimeoutSeconds    int32    `json:"timeout_seconds"`
+	MaxParts          uint32   `json:"max_parts,omitempty"`
+	OutgoingChanIDs   []uint64 `json:"outgoing_chan_ids,omitempty"`
+	AllowSelfPayment  bool     `json:"allow_self_payment,omitempty"`
+	NoInflightUpdates bool     `json:"no_inflight_updates,omitempty"`
+}
+
+type paymentStreamResponse struct {
+	Result *Payment `json:"result,omitempty"`
+	Error  *struct {
+		Message string `json:"message,omitempty"`
+	} `json:"error,omitempty"`
+}
+
+// SendPaymentV2 sends a payment and streams its state until it succeeds or fails.
+// The payment channel is closed when the stream ends; the error channel then
+// yields the stream error, if any, and is closed as well.
+func (c *Client) SendPaymentV2(ctx context.Context, params SendPaymentParams) (<-chan Payment, <-chan error, error) {
+	bodyBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal payment request: %w", err)
+	}
+
+	return c.streamPayments(ctx, http.MethodPost, "/v2/router/send", bodyBytes)
+}
+
+// TrackPaymentV2 streams the state of a previously sent payment until it succeeds or fails.
+func (c *Client) TrackPaymentV2(ctx context.Context, paymentHash []byte, noInflightUpdates bool) (<-chan Payment, <-chan error, error) {
+	path := "/v2/router/track/" + base64.URLEncoding.EncodeToString(paymentHash)
+	if noInflightUpdates {
+		path += "?no_inflight_updates=true"
+	}
+
+	return c.streamPayments(ctx, http.MethodGet, path, nil)
+}
+
+// streamPayments reads a newline-delimited stream of payment updates.
+func (c *Client) streamPayments(ctx context.Context, method, path string, body []byte) (<-chan Payment, <-chan error, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.restURL(path), reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Grpc-Metadata-macaroon", c.macaroon)
+
+	// Payment streams can outlive the regular request timeout.
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request to LND: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBody)}
+	}
+
+	paymentChan := make(chan Payment)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(paymentChan)
+		defer close(errChan)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var streamResp paymentStreamResponse
+			if err := decoder.Decode(&streamResp); err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					errChan <- fmt.Errorf("failed to decode payment stream: %w", err)
+				}
+				return
+			}
+
+			if streamResp.Error != nil {
+				errChan <- fmt.Errorf("LND stream error: %s", streamResp.Error.Message)
+				return
+			}
+
+			if streamResp.Result == nil {
+				continue
+			}
+
+			select {
+			case paymentChan <- *streamResp.Result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return paymentChan, errChan, nil
+}
+
+// DecodePayReq decodes a BOLT11 payment request.
+func (c *Client) DecodePayReq(ctx context.Context, payReq string) (PayReq, error) {
+	var res PayReq
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/payreq/"+url.PathEscape(payReq), nil, &res); err != nil {
+		return PayReq{}, err
+	}
+
+	return res, nil
+}
+
+// ListPaymentsParams holds the parameters for ListPayments.
+type ListPaymentsParams struct {
+	IncludeIncomplete bool
+	IndexOffset       uint64
+	MaxPayments       uint64
+	Reversed          bool
+}
+
+// ListPaymentsResponse is a page of payments.
+type ListPaymentsResponse struct {
+	Payments         []Payment `json:"payments"`
+	FirstIndexOffset uint64    `json:"first_index_offset,string,omitempty"`
+	LastIndexOffset  uint64    `json:"last_index_offset,string,omitempty"`
+}
+
+// ListPayments lists outgoing payments.
+func (c *Client) ListPayments(ctx context.Context, params ListPaymentsParams) (ListPaymentsResponse, error) {
+	q := url.Values{}
+	if params.IncludeIncomplete {
+		q.Set("include_incomplete", "true")
+	}
+	if params.IndexOffset > 0 {
+		q.Set("index_offset", strconv.FormatUint(params.IndexOffset, 10))
+	}
+	if params.MaxPayments > 0 {
+		q.Set("max_payments", strconv.FormatUint(params.MaxPayments, 10))
+	}
+	if params.Reversed {
+		q.Set("reversed", "true")
+	}
+
+	path := "/v1/payments"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var res ListPaymentsResponse
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return ListPaymentsResponse{}, err
+	}
+
+	return res, nil
+}
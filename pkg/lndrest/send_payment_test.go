@@ -0,0 +1,178 @@
+package lndrest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendPaymentV2(t *testing.T) {
+	t.Run("streams updates until the payment succeeds", func(t *testing.T) {
+		const payReq = "lnbc10u1pjx5g8zpp5z..."
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/v2/router/send", r.URL.Path)
+			assert.Equal(t, "macaroon", r.Header.Get("Grpc-Metadata-macaroon"))
+
+			var reqBody SendPaymentParams
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+			assert.Equal(t, payReq, reqBody.PaymentRequest)
+			assert.Equal(t, int64(10), reqBody.FeeLimitSat)
+			assert.Equal(t, int32(60), reqBody.TimeoutSeconds)
+			assert.Equal(t, uint32(4), reqBody.MaxParts)
+			assert.Equal(t, []uint64{123}, reqBody.OutgoingChanIDs)
+
+			w.Write([]byte(`{"result":{"payment_hash":"abcd","status":"IN_FLIGHT","value_msat":"1000"}}` + "\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(`{"result":{"payment_hash":"abcd","status":"SUCCEEDED","payment_preimage":"ef01","fee_msat":"12"}}` + "\n"))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		updates, errs, err := client.SendPaymentV2(context.Background(), SendPaymentParams{
+			PaymentRequest:  payReq,
+			FeeLimitSat:     10,
+			TimeoutSeconds:  60,
+			MaxParts:        4,
+			OutgoingChanIDs: []uint64{123},
+		})
+		require.NoError(t, err)
+
+		var got []Payment
+		for p := range updates {
+			got = append(got, p)
+		}
+		require.NoError(t, <-errs)
+
+		require.Len(t, got, 2)
+		assert.Equal(t, PaymentStatus_IN_FLIGHT, got[0].Status)
+		assert.Equal(t, int64(1000), got[0].ValueMsat)
+		assert.Equal(t, PaymentStatus_SUCCEEDED, got[1].Status)
+		assert.True(t, got[1].Status.IsFinal())
+		assert.Equal(t, "ef01", got[1].PaymentPreimage)
+		assert.Equal(t, int64(12), got[1].FeeMsat)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error":{"code":6,"message":"invoice is already paid"}}` + "\n"))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		updates, errs, err := client.SendPaymentV2(context.Background(), SendPaymentParams{PaymentRequest: "lnbc1..."})
+		require.NoError(t, err)
+
+		for range updates {
+			t.Fatal("unexpected payment update")
+		}
+		err = <-errs
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invoice is already paid")
+	})
+
+	t.Run("LND API error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal error"))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(server.URL, "macaroon", "")
+		require.NoError(t, err)
+
+		_, _, err = client.SendPaymentV2(context.Background(), SendPaymentParams{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "LND API error: 500 Internal Server Error")
+	})
+}
+
+func TestTrackPaymentV2(t *testing.T) {
+	var hash = []byte{0xfb, 0xff, 0x01}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v2/router/track/"+base64.URLEncoding.EncodeToString(hash), r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("no_inflight_updates"))
+
+		w.Write([]byte(`{"result":{"payment_hash":"fbff01","status":"FAILED","failure_reason":"FAILURE_REASON_NO_ROUTE"}}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	updates, errs, err := client.TrackPaymentV2(context.Background(), hash, true)
+	require.NoError(t, err)
+
+	p, ok := <-updates
+	require.True(t, ok)
+	assert.Equal(t, PaymentStatus_FAILED, p.Status)
+	assert.Equal(t, PaymentFailureReason_NO_ROUTE, p.FailureReason)
+
+	_, ok = <-updates
+	assert.False(t, ok)
+	require.NoError(t, <-errs)
+}
+
+func TestDecodePayReq(t *testing.T) {
+	const payReq = "lnbc10u1pjx5g8zpp5z"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/payreq/"+payReq, r.URL.Path)
+
+		w.Write([]byte(`{"destination":"02abc","payment_hash":"abcd","num_satoshis":"1000","num_msat":"1000000","expiry":"3600","description":"coffee"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	res, err := client.DecodePayReq(context.Background(), payReq)
+	require.NoError(t, err)
+	assert.Equal(t, "02abc", res.Destination)
+	assert.Equal(t, int64(1000), res.NumSatoshis)
+	assert.Equal(t, int64(1000000), res.NumMsat)
+	assert.Equal(t, int64(3600), res.Expiry)
+	assert.Equal(t, "coffee", res.Description)
+}
+
+func TestListPayments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/payments", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("include_incomplete"))
+		assert.Equal(t, "5", r.URL.Query().Get("index_offset"))
+		assert.Equal(t, "2", r.URL.Query().Get("max_payments"))
+		assert.Equal(t, "true", r.URL.Query().Get("reversed"))
+
+		w.Write([]byte(`{"payments":[{"payment_hash":"aa","status":"SUCCEEDED","payment_index":"4"},{"payment_hash":"bb","status":"FAILED","payment_index":"3"}],"first_index_offset":"3","last_index_offset":"4"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "macaroon", "")
+	require.NoError(t, err)
+
+	res, err := client.ListPayments(context.Background(), ListPaymentsParams{
+		IncludeIncomplete: true,
+		IndexOffset:       5,
+		MaxPayments:       2,
+		Reversed:          true,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Payments, 2)
+	assert.Equal(t, uint64(4), res.Payments[0].PaymentIndex)
+	assert.Equal(t, PaymentStatus_FAILED, res.Payments[1].Status)
+	assert.Equal(t, uint64(3), res.FirstIndexOffset)
+	assert.Equal(t, uint64(4), res.LastIndexOffset)
+}
@@ -2,6 +2,7 @@ package lndrest
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
@@ -24,9 +25,23 @@ type SubscribeInvoicesResponse struct {
 	} `json:"error,omitempty"`
 }
 
+// StreamError is an error reported by LND on an invoice stream.
+type StreamError struct {
+	Message string
+}
+
+func (e *StreamError) Error() string {
+	return "lnd stream error: " + e.Message
+}
+
 // SubscribeInvoices subscribes to invoices from the LND node.
-// It returns a channel for invoices, a channel for errors, and an error for setup issues.
+// The channel is closed when the stream ends; errors on the stream are only logged.
+// Use InvoiceSubscription for a stream that reconnects and reports errors.
 func (c *Client) SubscribeInvoices(ctx context.Context, req SubscribeInvoicesParams) (<-chan Invoice, error) {
+	return c.subscribe(ctx, subscribeInvoicesPath(req), logStreamError)
+}
+
+func subscribeInvoicesPath(req SubscribeInvoicesParams) string {
 	q := url.Values{}
 	if req.AddIndex > 0 {
 		q.Set("add_index", fmt.Sprintf("%d", req.AddIndex))
@@ -40,11 +55,23 @@ func (c *Client) SubscribeInvoices(ctx context.Context, req SubscribeInvoicesPar
 		path = path + "?" + q.Encode()
 	}
 
-	wsurl, err := url.JoinPath("wss://", c.host, path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to join path: %w", err)
-	}
+	return path
+}
+
+func logStreamError(err error) {
+	slog.Error("invoice stream error", "error", err)
+}
 
+// SubscribeSingleInvoice subscribes to state changes of a single invoice.
+// Unlike SubscribeInvoices, it also reports the ACCEPTED state of hold invoices.
+func (c *Client) SubscribeSingleInvoice(ctx context.Context, paymentHash []byte) (<-chan Invoice, error) {
+	return c.subscribe(ctx, "/v2/invoices/subscribe/"+base64.URLEncoding.EncodeToString(paymentHash), logStreamError)
+}
+
+// subscribe opens a websocket stream of invoices on the given path.
+// onError is called for errors reported on the stream (as *StreamError) and
+// for the read error that ends the stream.
+func (c *Client) subscribe(ctx context.Context, path string, onError func(error)) (<-chan Invoice, error) {
 	dialer := websocket.DefaultDialer
 	if transport, ok := c.httpClient.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
 		dialer = &websocket.Dialer{
@@ -52,12 +79,15 @@ func (c *Client) SubscribeInvoices(ctx context.Context, req SubscribeInvoicesPar
 			HandshakeTimeout: 30 * time.Second,
 			TLSClientConfig:  transport.TLSClientConfig.Clone(),
 		}
+		if transport.Proxy != nil {
+			dialer.Proxy = transport.Proxy
+		}
 	}
 
 	header := http.Header{}
 	header.Set("Grpc-Metadata-macaroon", c.macaroon)
 
-	conn, resp, err := dialer.DialContext(ctx, wsurl, header)
+	conn, resp, err := dialer.DialContext(ctx, c.wsURL(path), header)
 	if err != nil {
 		if resp != nil && resp.Body != nil {
 			resp.Body.Close()
@@ -65,24 +95,25 @@ func (c *Client) SubscribeInvoices(ctx context.Context, req SubscribeInvoicesPar
 		return nil, fmt.Errorf("failed to dial websocket: %w", err)
 	}
 
+	// Unblock ReadMessage when the caller is done with the subscription.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
 	invoiceChan := make(chan Invoice)
 	go func() {
 		defer close(invoiceChan)
+		defer stop()
 		defer conn.Close()
 
 		for {
 			// Wait for the next message (blocked until a new message is received)
 			_, message, err := conn.ReadMessage()
 			if err != nil {
-				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 					// 정상 종료 또는 컨텍스트 취소로 인한 종료
 					return
 				}
 
-				slog.Error("error reading websocket message",
-					"message", message,
-					"error", err,
-				)
+				onError(fmt.Errorf("error reading websocket message: %w", err))
 				return
 			}
 
@@ -96,7 +127,11 @@ func (c *Client) SubscribeInvoices(ctx context.Context, req SubscribeInvoicesPar
 			}
 
 			if streamResp.Error != nil {
-				slog.Error("lnd stream error", "error", streamResp.Error.Message)
+				onError(&StreamError{Message: streamResp.Error.Message})
+				continue
+			}
+
+			if streamResp.Result == nil {
 				continue
 			}
 
@@ -0,0 +1,39 @@
+package lnurl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Currency is an entry of the LUD-21 `currencies` list in a payRequest.
+type Currency struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+	// Multiplier is the number of msats one smallest unit of the currency is worth.
+	Multiplier  float64              `json:"multiplier"`
+	Convertible *ConvertibleCurrency `json:"convertible,omitempty"`
+}
+
+// ConvertibleCurrency is the range, in smallest units, that the receiver
+// converts to bitcoin.
+type ConvertibleCurrency struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+// ParseAmount parses the callback amount, either msats ("21000") or, as per
+// LUD-21, smallest units of a currency ("1000.USD").
+// The returned currency is empty for msats.
+func ParseAmount(s string) (amount int64, currency string, err error) {
+	value, currency, _ := strings.Cut(s, ".")
+
+	amount, err = strconv.ParseInt(value, 10, 64)
+	if err != nil || amount < 0 {
+		return 0, "", fmt.Errorf("invalid amount %q", s)
+	}
+
+	return amount, strings.ToUpper(currency), nil
+}
@@ -22,6 +22,7 @@ type PayParams struct {
 	EncodedMetadata string         `json:"metadata"`
 	CommentAllowed  int64          `json:"commentAllowed"`
 	PayerData       *PayerDataSpec `json:"payerData,omitempty"`
+	Currencies      []Currency     `json:"currencies,omitempty"`
 
 	Metadata Metadata `json:"-"`
 }
@@ -0,0 +1,73 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+	"strings"
+)
+
+// ErrPassphraseRequired is returned for an encrypted private key without a passphrase.
+var ErrPassphraseRequired = errors.New("the private key is encrypted (ncryptsec) and needs a passphrase")
+
+// ParsePrivateKey returns the hex private key from an nsec, a hex key, or a
+// NIP-49 ncryptsec, which is decrypted with passphrase.
+func ParsePrivateKey(key, passphrase string) (string, error) {
+	key = strings.TrimSpace(key)
+
+	switch {
+	case strings.HasPrefix(key, "ncryptsec1"):
+		if passphrase == "" {
+			return "", ErrPassphraseRequired
+		}
+		sk, err := nip49.Decrypt(key, passphrase)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt ncryptsec: %w", err)
+		}
+		return sk, nil
+
+	case strings.HasPrefix(key, "nsec1"):
+		_, value, err := nip19.Decode(key)
+		if err != nil {
+			return "", fmt.Errorf("invalid nsec: %w", err)
+		}
+		return value.(string), nil
+
+	case isHexKey(key):
+		return strings.ToLower(key), nil
+	}
+
+	return "", fmt.Errorf("not an nsec, ncryptsec or 64 character hex key")
+}
+
+// ParsePublicKey returns the hex public key from an npub or a hex key.
+func ParsePublicKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+
+	switch {
+	case strings.HasPrefix(key, "npub1"):
+		_, value, err := nip19.Decode(key)
+		if err != nil {
+			return "", fmt.Errorf("invalid npub: %w", err)
+		}
+		return value.(string), nil
+
+	case isHexKey(key):
+		return strings.ToLower(key), nil
+	}
+
+	return "", fmt.Errorf("not an npub or 64 character hex key")
+}
+
+// PublicKey derives the hex public key of a hex private key.
+func PublicKey(privateKey string) (string, error) {
+	return nostr.GetPublicKey(privateKey)
+}
+
+func isHexKey(key string) bool {
+	b, err := hex.DecodeString(key)
+	return err == nil && len(b) == 32
+}
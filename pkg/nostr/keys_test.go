@@ -0,0 +1,55 @@
+package nostr
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	nsec, err := nip19.EncodePrivateKey(sk)
+	require.NoError(t, err)
+	ncryptsec, err := nip49.Encrypt(sk, "correct horse", 16, nip49.ClientDoesNotTrackThisData)
+	require.NoError(t, err)
+
+	for _, key := range []string{sk, strings.ToUpper(sk), nsec, nsec + "\n"} {
+		got, err := ParsePrivateKey(key, "")
+		require.NoError(t, err, key)
+		assert.Equal(t, sk, got)
+	}
+
+	got, err := ParsePrivateKey(ncryptsec, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, sk, got)
+
+	_, err = ParsePrivateKey(ncryptsec, "")
+	assert.ErrorIs(t, err, ErrPassphraseRequired)
+	_, err = ParsePrivateKey(ncryptsec, "wrong")
+	assert.Error(t, err)
+
+	npub, _ := nip19.EncodePublicKey(sk)
+	for _, key := range []string{"", "nsec1abc", npub, sk[:62]} {
+		_, err := ParsePrivateKey(key, "")
+		assert.Error(t, err, key)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pk, err := PublicKey(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	for _, key := range []string{pk, npub} {
+		got, err := ParsePublicKey(key)
+		require.NoError(t, err)
+		assert.Equal(t, pk, got)
+	}
+
+	_, err = ParsePublicKey("nsec1abc")
+	assert.Error(t, err)
+}
@@ -0,0 +1,345 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip46"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Signer signs events and encrypts messages as lmt's Nostr identity.
+type Signer interface {
+	// Keyer signs events and encrypts with NIP-44.
+	nostr.Keyer
+
+	// PublicKey returns the hex public key events are signed with.
+	PublicKey() string
+
+	// NIP04Encrypt encrypts plaintext for recipient with legacy NIP-04.
+	NIP04Encrypt(ctx context.Context, plaintext, recipient string) (string, error)
+
+	// Ready reports whether the signer can sign now. Only a remote signer
+	// that is still connecting is not ready.
+	Ready() bool
+}
+
+// LocalSigner signs with a private key held in memory.
+type LocalSigner struct {
+	keyer.KeySigner
+	privateKey string
+	publicKey  string
+}
+
+// NewLocalSigner returns a signer for the hex privateKey.
+func NewLocalSigner(privateKey string) (*LocalSigner, error) {
+	ks, err := keyer.NewPlainKeySigner(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	publicKey, err := ks.GetPublicKey(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalSigner{KeySigner: ks, privateKey: privateKey, publicKey: publicKey}, nil
+}
+
+func (s *LocalSigner) PublicKey() string {
+	return s.publicKey
+}
+
+func (s *LocalSigner) Ready() bool {
+	return true
+}
+
+func (s *LocalSigner) NIP04Encrypt(_ context.Context, plaintext, recipient string) (string, error) {
+	sharedSecret, err := nip04.ComputeSharedSecret(recipient, s.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	return nip04.Encrypt(plaintext, sharedSecret)
+}
+
+// remoteSignerTimeout bounds every request to a remote signer, which may be
+// offline or waiting for its user to approve. Tests shorten it.
+var remoteSignerTimeout = 30 * time.Second
+
+// RemoteSigner asks a NIP-46 remote signer ("bunker") to sign over relays,
+// so the private key never has to be on this machine.
+type RemoteSigner struct {
+	bunker    *nip46.BunkerClient
+	publicKey string
+}
+
+// ConnectRemoteSigner connects to the remote signer at bunkerURI
+// (bunker://<signer pubkey>?relay=...&secret=...) as the client key
+// clientSecretKey. The client key should be kept: a signer that already
+// knows it answers without connecting again, which matters because the
+// secret in a bunker URI is usually only accepted once.
+//
+// ctx must live as long as the signer is used, it keeps the relay
+// subscription for responses open.
+func ConnectRemoteSigner(ctx context.Context, bunkerURI, clientSecretKey string) (*RemoteSigner, error) {
+	target, relays, secret, err := ParseBunkerURI(bunkerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	onAuth := func(authURL string) {
+		slog.Warn("The remote signer asks to approve lmt, open this URL", "url", authURL)
+	}
+	// go-nostr's response subscription normalizes the relay list in place
+	// while requests range over it. The subscription connects to each relay
+	// after writing its entry, so requests wait until it opens the last one.
+	relays = normalizeRelays(relays)
+	last := relays[len(relays)-1]
+	subscribed := make(chan struct{})
+	markSubscribed := sync.OnceFunc(func() { close(subscribed) })
+	pool := nostr.NewSimplePool(ctx, nostr.WithRelayOptions(relayHook(func(relay *nostr.Relay) {
+		if relay.URL == last {
+			markSubscribed()
+		}
+	})))
+	bunker := nip46.NewBunker(ctx, clientSecretKey, target, relays, pool, onAuth)
+	select {
+	case <-subscribed:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	publicKey, err := remoteCall(ctx, func(ctx context.Context) (string, error) {
+		return bunker.GetPublicKey(ctx)
+	})
+	if err != nil {
+		slog.Info("Connecting to the remote signer", "signer", target, "relays", relays)
+		if _, err := remoteCall(ctx, func(ctx context.Context) (string, error) {
+			return bunker.RPC(ctx, "connect", []string{target, secret})
+		}); err != nil {
+			return nil, fmt.Errorf("remote signer refused to connect: %w", err)
+		}
+
+		publicKey, err = remoteCall(ctx, func(ctx context.Context) (string, error) {
+			return bunker.GetPublicKey(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the public key from the remote signer: %w", err)
+		}
+	}
+	if !nostr.IsValidPublicKey(publicKey) {
+		return nil, fmt.Errorf("remote signer returned an invalid public key %q", publicKey)
+	}
+
+	return &RemoteSigner{bunker: bunker, publicKey: publicKey}, nil
+}
+
+// ParseBunkerURI returns the signer's public key, relays and secret from a
+// bunker:// URI.
+func ParseBunkerURI(bunkerURI string) (target string, relays []string, secret string, err error) {
+	u, err := url.Parse(bunkerURI)
+	if err != nil || u.Scheme != "bunker" {
+		return "", nil, "", fmt.Errorf("not a bunker:// URI")
+	}
+	if !nostr.IsValidPublicKey(u.Host) {
+		return "", nil, "", fmt.Errorf("bunker URI has no valid signer public key")
+	}
+
+	relays = u.Query()["relay"]
+	if len(relays) == 0 {
+		return "", nil, "", fmt.Errorf("bunker URI has no relay")
+	}
+	return u.Host, relays, u.Query().Get("secret"), nil
+}
+
+// normalizeRelays normalizes relay URLs and drops duplicates, which go-nostr
+// would skip without opening them.
+func normalizeRelays(relays []string) []string {
+	normalized := make([]string, 0, len(relays))
+	for _, relay := range relays {
+		relay = nostr.NormalizeURL(relay)
+		if !slices.Contains(normalized, relay) {
+			normalized = append(normalized, relay)
+		}
+	}
+	return normalized
+}
+
+// relayHook is a relay option that is called for every relay a pool opens.
+type relayHook func(*nostr.Relay)
+
+func (h relayHook) ApplyRelayOption(relay *nostr.Relay) {
+	h(relay)
+}
+
+func remoteCall(ctx context.Context, call func(context.Context) (string, error)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteSignerTimeout)
+	defer cancel()
+	return call(ctx)
+}
+
+func (s *RemoteSigner) PublicKey() string {
+	return s.publicKey
+}
+
+func (s *RemoteSigner) GetPublicKey(context.Context) (string, error) {
+	return s.publicKey, nil
+}
+
+func (s *RemoteSigner) Ready() bool {
+	return true
+}
+
+func (s *RemoteSigner) SignEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteSignerTimeout)
+	defer cancel()
+
+	if err := s.bunker.SignEvent(ctx, event); err != nil {
+		return err
+	}
+	if event.PubKey != s.publicKey {
+		return fmt.Errorf("remote signer signed as %s instead of %s", event.PubKey, s.publicKey)
+	}
+	return nil
+}
+
+func (s *RemoteSigner) Encrypt(ctx context.Context, plaintext, recipient string) (string, error) {
+	return remoteCall(ctx, func(ctx context.Context) (string, error) {
+		return s.bunker.NIP44Encrypt(ctx, recipient, plaintext)
+	})
+}
+
+func (s *RemoteSigner) Decrypt(ctx context.Context, ciphertext, sender string) (string, error) {
+	return remoteCall(ctx, func(ctx context.Context) (string, error) {
+		return s.bunker.NIP44Decrypt(ctx, sender, ciphertext)
+	})
+}
+
+func (s *RemoteSigner) NIP04Encrypt(ctx context.Context, plaintext, recipient string) (string, error) {
+	return remoteCall(ctx, func(ctx context.Context) (string, error) {
+		return s.bunker.NIP04Encrypt(ctx, recipient, plaintext)
+	})
+}
+
+// ErrSignerNotReady is returned by a BackgroundSigner that isn't connected yet.
+var ErrSignerNotReady = errors.New("the remote signer is not connected")
+
+const (
+	// connectRetryDelay is how long a BackgroundSigner waits after a failed
+	// connection attempt. It doubles up to connectRetryMaxDelay.
+	connectRetryDelay    = 10 * time.Second
+	connectRetryMaxDelay = 5 * time.Minute
+)
+
+// BackgroundSigner connects to a remote signer in the background and keeps
+// retrying until it answers, so an offline signer doesn't keep lmt from
+// starting. Until then it only knows the public key it expects, and signing
+// and encrypting fail with ErrSignerNotReady.
+type BackgroundSigner struct {
+	publicKey  string
+	remote     atomic.Pointer[RemoteSigner]
+	err        atomic.Pointer[error] // why it stopped connecting for good
+	retryDelay time.Duration
+}
+
+// ConnectRemoteSignerInBackground starts connecting to the remote signer,
+// see ConnectRemoteSigner. publicKey is the hex public key the signer must
+// sign as; a signer that signs as another key is not used.
+func ConnectRemoteSignerInBackground(ctx context.Context, bunkerURI, clientSecretKey, publicKey string) *BackgroundSigner {
+	s := &BackgroundSigner{publicKey: publicKey, retryDelay: connectRetryDelay}
+	go s.connect(ctx, bunkerURI, clientSecretKey)
+	return s
+}
+
+func (s *BackgroundSigner) connect(ctx context.Context, bunkerURI, clientSecretKey string) {
+	delay := s.retryDelay
+	for {
+		// A failed attempt's relay subscriptions are closed with its context.
+		attemptCtx, cancel := context.WithCancel(ctx)
+		remote, err := ConnectRemoteSigner(attemptCtx, bunkerURI, clientSecretKey)
+		switch {
+		case err == nil && remote.PublicKey() != s.publicKey:
+			cancel()
+			err = fmt.Errorf("the remote signer signs as %s instead of %s", remote.PublicKey(), s.publicKey)
+			s.err.Store(&err)
+			slog.Error("The remote signer signs as another key than nostr.publickey, not using it",
+				"signer_pubkey", remote.PublicKey(), "pubkey", s.publicKey)
+			return
+		case err == nil:
+			context.AfterFunc(ctx, cancel)
+			s.remote.Store(remote)
+			slog.Info("Signing Nostr events with the remote signer", "pubkey", s.publicKey)
+			return
+		}
+		cancel()
+
+		slog.Warn("Failed to connect to the remote signer, retrying", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, connectRetryMaxDelay)
+	}
+}
+
+func (s *BackgroundSigner) PublicKey() string {
+	return s.publicKey
+}
+
+func (s *BackgroundSigner) GetPublicKey(context.Context) (string, error) {
+	return s.publicKey, nil
+}
+
+func (s *BackgroundSigner) Ready() bool {
+	return s.remote.Load() != nil
+}
+
+// Err returns why the signer will never be ready, e.g. because it signs as
+// another key. It's nil while the signer is connected or still connecting.
+func (s *BackgroundSigner) Err() error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (s *BackgroundSigner) SignEvent(ctx context.Context, event *nostr.Event) error {
+	remote := s.remote.Load()
+	if remote == nil {
+		return ErrSignerNotReady
+	}
+	return remote.SignEvent(ctx, event)
+}
+
+func (s *BackgroundSigner) Encrypt(ctx context.Context, plaintext, recipient string) (string, error) {
+	remote := s.remote.Load()
+	if remote == nil {
+		return "", ErrSignerNotReady
+	}
+	return remote.Encrypt(ctx, plaintext, recipient)
+}
+
+func (s *BackgroundSigner) Decrypt(ctx context.Context, ciphertext, sender string) (string, error) {
+	remote := s.remote.Load()
+	if remote == nil {
+		return "", ErrSignerNotReady
+	}
+	return remote.Decrypt(ctx, ciphertext, sender)
+}
+
+func (s *BackgroundSigner) NIP04Encrypt(ctx context.Context, plaintext, recipient string) (string, error) {
+	remote := s.remote.Load()
+	if remote == nil {
+		return "", ErrSignerNotReady
+	}
+	return remote.NIP04Encrypt(ctx, plaintext, recipient)
+}
@@ -0,0 +1,275 @@
+package nostr
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip46"
+	"github.com/nbd-wtf/go-nostr/nip59"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalSigner(t *testing.T) {
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+
+	signer, err := NewLocalSigner(sk)
+	require.NoError(t, err)
+	assert.Equal(t, pk, signer.PublicKey())
+
+	event := nostr.Event{Kind: 1, Content: "gm", CreatedAt: nostr.Now()}
+	require.NoError(t, signer.SignEvent(ctx, &event))
+	assert.Equal(t, pk, event.PubKey)
+	ok, err := event.CheckSignature()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	recipientSK := nostr.GeneratePrivateKey()
+	recipientPK, _ := nostr.GetPublicKey(recipientSK)
+	ciphertext, err := signer.NIP04Encrypt(ctx, "paid 21 sats", recipientPK)
+	require.NoError(t, err)
+	sharedSecret, err := nip04.ComputeSharedSecret(pk, recipientSK)
+	require.NoError(t, err)
+	plaintext, err := nip04.Decrypt(ciphertext, sharedSecret)
+	require.NoError(t, err)
+	assert.Equal(t, "paid 21 sats", plaintext)
+
+	_, err = NewLocalSigner("not a key")
+	assert.Error(t, err)
+}
+
+func TestParseBunkerURI(t *testing.T) {
+	pk, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	target, relays, secret, err := ParseBunkerURI("bunker://" + pk + "?relay=wss://relay.nsec.app&relay=wss://relay.damus.io&secret=s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, pk, target)
+	assert.Equal(t, []string{"wss://relay.nsec.app", "wss://relay.damus.io"}, relays)
+	assert.Equal(t, "s3cret", secret)
+
+	for _, uri := range []string{
+		"",
+		"nostrconnect://" + pk + "?relay=wss://relay.nsec.app",
+		"bunker://" + pk[:62] + "?relay=wss://relay.nsec.app",
+		"bunker://" + pk,
+	} {
+		_, _, _, err := ParseBunkerURI(uri)
+		assert.Error(t, err, uri)
+	}
+}
+
+// testRelay is a minimal Nostr relay. It keeps every event, so a subscriber
+// also gets the events published just before it subscribed.
+type testRelay struct {
+	mtx    sync.Mutex
+	events []nostr.Event
+	subs   map[*relayConn]map[string]nostr.Filters
+}
+
+type relayConn struct {
+	mtx  sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *relayConn) send(envelope nostr.Envelope) {
+	b, _ := envelope.MarshalJSON()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.conn.WriteMessage(websocket.TextMessage, b)
+}
+
+// newTestRelay starts a relay and returns its ws:// URL.
+func newTestRelay(t *testing.T) string {
+	relay := &testRelay{subs: make(map[*relayConn]map[string]nostr.Filters)}
+	upgrader := websocket.Upgrader{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn := &relayConn{conn: ws}
+		defer func() {
+			relay.mtx.Lock()
+			delete(relay.subs, conn)
+			relay.mtx.Unlock()
+			ws.Close()
+		}()
+
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			relay.handle(conn, nostr.ParseMessage(string(message)))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func (r *testRelay) handle(conn *relayConn, envelope nostr.Envelope) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	switch env := envelope.(type) {
+	case *nostr.EventEnvelope:
+		r.events = append(r.events, env.Event)
+		conn.send(&nostr.OKEnvelope{EventID: env.Event.ID, OK: true})
+		for sub, filters := range r.subs {
+			for id, f := range filters {
+				if f.MatchIgnoringTimestampConstraints(&env.Event) {
+					sub.send(&nostr.EventEnvelope{SubscriptionID: &id, Event: env.Event})
+				}
+			}
+		}
+	case *nostr.ReqEnvelope:
+		if r.subs[conn] == nil {
+			r.subs[conn] = make(map[string]nostr.Filters)
+		}
+		r.subs[conn][env.SubscriptionID] = env.Filters
+		for _, event := range r.events {
+			if env.Filters.MatchIgnoringTimestampConstraints(&event) {
+				conn.send(&nostr.EventEnvelope{SubscriptionID: &env.SubscriptionID, Event: event})
+			}
+		}
+		eose := nostr.EOSEEnvelope(env.SubscriptionID)
+		conn.send(&eose)
+	case *nostr.CloseEnvelope:
+		delete(r.subs[conn], string(*env))
+	}
+}
+
+// runBunker runs a NIP-46 signer for secretKey on relayURL. It accepts
+// clients once they connect with secret, and records the methods called.
+func runBunker(t *testing.T, ctx context.Context, relayURL, secretKey, secret string) (called func() []string) {
+	var (
+		mtx        sync.Mutex
+		methods    []string
+		authorized = make(map[string]bool)
+	)
+	signer := nip46.NewStaticKeySigner(secretKey)
+	signer.AuthorizeRequest = func(harmless bool, from, s string) bool {
+		if s == secret {
+			authorized[from] = true
+		}
+		return authorized[from]
+	}
+
+	relay, err := nostr.RelayConnect(ctx, relayURL)
+	require.NoError(t, err)
+	publicKey, _ := nostr.GetPublicKey(secretKey)
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{Kinds: []int{nostr.KindNostrConnect}, Tags: nostr.TagMap{"p": {publicKey}}}})
+	require.NoError(t, err)
+
+	go func() {
+		for event := range sub.Events {
+			req, _, response, err := signer.HandleRequest(ctx, event)
+			if err != nil {
+				continue
+			}
+			mtx.Lock()
+			methods = append(methods, req.Method)
+			mtx.Unlock()
+			relay.Publish(ctx, response)
+		}
+	}()
+	return func() []string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]string(nil), methods...)
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	relayURL := newTestRelay(t)
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	called := runBunker(t, ctx, relayURL, sk, "s3cr3t")
+
+	// The signer doesn't know the client yet, so it has to connect with the secret.
+	signer, err := ConnectRemoteSigner(ctx, "bunker://"+pk+"?relay="+relayURL+"&secret=s3cr3t", nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	assert.Equal(t, pk, signer.PublicKey())
+	assert.Equal(t, []string{"get_public_key", "connect", "get_public_key"}, called())
+
+	event := nostr.Event{Kind: 1, Content: "gm", CreatedAt: nostr.Now()}
+	require.NoError(t, signer.SignEvent(ctx, &event))
+	assert.Equal(t, pk, event.PubKey)
+	ok, err := event.CheckSignature()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	otherPK, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	mismatched := &RemoteSigner{bunker: signer.bunker, publicKey: otherPK}
+	event = nostr.Event{Kind: 1, Content: "gm", CreatedAt: nostr.Now()}
+	assert.ErrorContains(t, mismatched.SignEvent(ctx, &event), "instead of "+otherPK)
+
+	// NIP-17 seals and wraps with NIP-44, all through the remote signer.
+	recipientSK := nostr.GeneratePrivateKey()
+	recipientPK, _ := nostr.GetPublicKey(recipientSK)
+	recipient, err := keyer.NewPlainKeySigner(recipientSK)
+	require.NoError(t, err)
+
+	_, toThem, err := nip17.PrepareMessage(ctx, "paid 21 sats", nil, signer, recipientPK, nil)
+	require.NoError(t, err)
+	rumor, err := nip59.GiftUnwrap(toThem, func(sender, ciphertext string) (string, error) {
+		return recipient.Decrypt(ctx, ciphertext, sender)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "paid 21 sats", rumor.Content)
+	assert.Equal(t, pk, rumor.PubKey)
+}
+
+func TestBackgroundSigner(t *testing.T) {
+	timeout := remoteSignerTimeout
+	remoteSignerTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { remoteSignerTimeout = timeout })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	relayURL := newTestRelay(t)
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	uri := "bunker://" + pk + "?relay=" + relayURL + "&secret=s3cr3t"
+
+	signer := &BackgroundSigner{publicKey: pk, retryDelay: 50 * time.Millisecond}
+	go signer.connect(ctx, uri, nostr.GeneratePrivateKey())
+
+	// The remote signer is offline.
+	assert.False(t, signer.Ready())
+	assert.Equal(t, pk, signer.PublicKey())
+	event := nostr.Event{Kind: 1, Content: "gm", CreatedAt: nostr.Now()}
+	assert.ErrorIs(t, signer.SignEvent(ctx, &event), ErrSignerNotReady)
+	_, err := signer.NIP04Encrypt(ctx, "gm", pk)
+	assert.ErrorIs(t, err, ErrSignerNotReady)
+
+	runBunker(t, ctx, relayURL, sk, "s3cr3t")
+	require.Eventually(t, signer.Ready, 10*time.Second, 20*time.Millisecond)
+	require.NoError(t, signer.SignEvent(ctx, &event))
+	assert.Equal(t, pk, event.PubKey)
+
+	// A signer signing as another key is never used.
+	otherPK, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	mismatched := &BackgroundSigner{publicKey: otherPK, retryDelay: 50 * time.Millisecond}
+	go mismatched.connect(ctx, uri, nostr.GeneratePrivateKey())
+	assert.Never(t, mismatched.Ready, time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool { return mismatched.Err() != nil }, 10*time.Second, 20*time.Millisecond)
+	assert.ErrorContains(t, mismatched.Err(), "instead of "+otherPK)
+	assert.NoError(t, signer.Err())
+}
@@ -1,6 +1,7 @@
 package nostr
 
 import (
+	"context"
 	"fmt"
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -33,15 +34,15 @@ func (z ZapRequest) Event() nostr.Event {
 }
 
 type ZapReceiptParams struct {
-	ZapRequest       ZapRequest
-	ZapRequestRaw    string
-	Bolt11           string // Paid Invoice
-	Preimage         string // Preimage (hex-encoded)
-	RecipientPubkey  string // Public key of recipient
-	RecipientPrivkey string // Private key to sign
+	ZapRequest      ZapRequest
+	ZapRequestRaw   string
+	Bolt11          string // Paid Invoice
+	Preimage        string // Preimage (hex-encoded)
+	RecipientPubkey string // Public key of recipient
+	Signer          Signer // Signs as the recipient
 }
 
-func NewZapReceipt(params ZapReceiptParams) (ZapReceipt, error) {
+func NewZapReceipt(ctx context.Context, params ZapReceiptParams) (ZapReceipt, error) {
 	tags := nostr.Tags{
 		{"p", params.RecipientPubkey},
 		{"bolt11", params.Bolt11},
@@ -68,7 +69,7 @@ func NewZapReceipt(params ZapReceiptParams) (ZapReceipt, error) {
 		Content:   "", // intended
 	}
 
-	if err := event.Sign(params.RecipientPrivkey); err != nil {
+	if err := params.Signer.SignEvent(ctx, &event); err != nil {
 		return ZapReceipt{}, fmt.Errorf("failed to sign zap event: %w", err)
 	}
 
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,19 +18,53 @@ type Handler interface {
 	OnInvoiceRequest(ctx context.Context, payload *InvoiceRequestPayload) (*InvoiceResponsePayload, error)
 }
 
+// DefaultMaxConcurrent is the number of requests handled at once when
+// NewClient is given a non-positive limit.
+const DefaultMaxConcurrent = 16
+
 type Client struct {
-	conn    *Conn
 	handler Handler
 	token   string
 	host    string
+
+	// sem bounds the number of requests handled concurrently.
+	sem chan struct{}
+
+	connected          atomic.Bool
+	onConnectionChange func(connected bool)
 }
 
 // NewClient creates a new Oksusu Connect client.
-func NewClient(host, token string, handler Handler) *Client {
+// At most maxConcurrent requests are handled at once; requests beyond that
+// are rejected with an error response instead of being queued.
+func NewClient(host, token string, handler Handler, maxConcurrent int) *Client {
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrent
+	}
+
 	return &Client{
 		host:    host,
 		token:   token,
 		handler: handler,
+		sem:     make(chan struct{}, maxConcurrent),
+	}
+}
+
+// OnConnectionChange registers fn to be called when the client becomes
+// connected (after authentication) or disconnected. It must be called before ConnectAndServe.
+func (c *Client) OnConnectionChange(fn func(connected bool)) {
+	c.onConnectionChange = fn
+}
+
+// Connected reports whether the client is connected and authenticated.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+func (c *Client) setConnected(connected bool) {
+	c.connected.Store(connected)
+	if c.onConnectionChange != nil {
+		c.onConnectionChange(connected)
 	}
 }
 
@@ -43,22 +79,42 @@ func (c *Client) ConnectAndServe(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
-	c.conn = NewConn(ws)
-	defer c.conn.Close()
+	conn := NewConn(ws)
+	defer conn.Close()
+
+	return c.serve(ctx, conn)
+}
+
+// session is one connection to the Oksu server. Requests are answered on
+// the connection they arrived on, so a handler that finishes after a
+// reconnect never writes to the new connection.
+type session struct {
+	*Client
+	conn *Conn
+
+	inflightMtx sync.Mutex
+	inflight    map[string]context.CancelFunc
+}
+
+// serve authenticates on conn and handles requests until it's closed.
+func (c *Client) serve(ctx context.Context, conn *Conn) error {
+	s := &session{Client: c, conn: conn, inflight: make(map[string]context.CancelFunc)}
 
 	// 1. authentication
 	authCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	if err = c.authenticate(authCtx); err != nil {
+	if err := s.authenticate(authCtx); err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 	slog.Info("Successfully authenticated with Oksu server")
+	c.setConnected(true)
+	defer c.setConnected(false)
 
 	// 2. listening loop
-	return c.listen(ctx)
+	return s.listen(ctx)
 }
 
-func (c *Client) authenticate(ctx context.Context) error {
+func (c *session) authenticate(ctx context.Context) error {
 	authPayload := AuthRequestPayload{Token: c.token}
 	payloadBytes, _ := json.Marshal(authPayload)
 
@@ -92,7 +148,12 @@ func (c *Client) authenticate(ctx context.Context) error {
 	return fmt.Errorf("unexpected response type during auth: %s", resp.Type)
 }
 
-func (c *Client) listen(ctx context.Context) error {
+func (c *session) listen(ctx context.Context) error {
+	// connCtx is canceled when the connection goes away, which cancels
+	// every request still being handled on it.
+	connCtx, cancelConn := context.WithCancel(ctx)
+	defer cancelConn()
+
 	for {
 		msg, err := c.conn.ReadMessage(ctx)
 		if err != nil {
@@ -103,15 +164,92 @@ func (c *Client) listen(ctx context.Context) error {
 			slog.Error("Failed to read message, disconnecting", "error", err)
 			return err
 		}
+		if msg == nil {
+			// Non-text frame, nothing to handle.
+			continue
+		}
+
+		if msg.Type == S2CCancel {
+			c.cancelRequest(msg)
+			continue
+		}
 
-		go c.handleMessage(context.Background(), msg)
+		select {
+		case c.sem <- struct{}{}:
+		default:
+			slog.Warn("Too many concurrent requests, rejecting", "type", msg.Type, "request_id", msg.ID)
+			c.writeError(connCtx, msg.ID, fmt.Errorf("too many concurrent requests"), 0)
+			continue
+		}
+
+		reqCtx, ok := c.register(connCtx, msg.ID)
+		if !ok {
+			<-c.sem
+			slog.Warn("Duplicate request ID, rejecting", "type", msg.Type, "request_id", msg.ID)
+			c.writeError(connCtx, msg.ID, fmt.Errorf("duplicate request id"), 0)
+			continue
+		}
+
+		go func() {
+			defer func() { <-c.sem }()
+			defer c.unregister(msg.ID)
+			c.handleMessage(reqCtx, msg)
+		}()
 	}
 }
 
-func (c *Client) handleMessage(ctx context.Context, msg *Message) {
+// register creates a cancelable context for the request with the given ID.
+// It returns false if a request with the same ID is already in flight.
+func (c *session) register(ctx context.Context, id string) (context.Context, bool) {
+	c.inflightMtx.Lock()
+	defer c.inflightMtx.Unlock()
+
+	if _, exists := c.inflight[id]; exists {
+		return nil, false
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	c.inflight[id] = cancel
+	return reqCtx, true
+}
+
+func (c *session) unregister(id string) {
+	c.inflightMtx.Lock()
+	defer c.inflightMtx.Unlock()
+
+	if cancel, ok := c.inflight[id]; ok {
+		cancel()
+		delete(c.inflight, id)
+	}
+}
+
+// cancelRequest cancels the in-flight request named by an S2CCancel message.
+func (c *session) cancelRequest(msg *Message) {
+	var p CancelPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		slog.Warn("Invalid cancel payload, ignoring", "request_id", msg.ID, "error", err)
+		return
+	}
+
+	c.inflightMtx.Lock()
+	cancel, ok := c.inflight[p.RequestID]
+	c.inflightMtx.Unlock()
+
+	if !ok {
+		slog.Debug("Cancel for unknown request, ignoring", "request_id", p.RequestID)
+		return
+	}
+
+	slog.Info("Request canceled by server", "request_id", p.RequestID)
+	cancel()
+}
+
+func (c *session) handleMessage(ctx context.Context, msg *Message) {
 	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	start := time.Now()
+
 	var err error
 	var responsePayload interface{}
 
@@ -131,27 +269,48 @@ func (c *Client) handleMessage(ctx context.Context, msg *Message) {
 		return
 	}
 
-	respMsg := Message{ID: msg.ID} // Response ID is same as the request ID
+	latency := time.Since(start)
+
+	if ctx.Err() != nil {
+		// Canceled by the server or the connection is gone; nobody is
+		// waiting for the response anymore.
+		slog.Info("Request canceled before completion", "type", msg.Type, "request_id", msg.ID, "latency", latency)
+		return
+	}
 
 	if err != nil {
 		slog.Error("Error handling request", "type", msg.Type, "request_id", msg.ID, "error", err)
-		respMsg.Type = C2SError
-		payload, _ := json.Marshal(ErrorPayload{Message: err.Error()})
-		respMsg.Payload = payload
-	} else {
-		switch msg.Type {
-		case S2CLNURLPRequest:
-			respMsg.Type = C2SLNURLPResponse
-		case S2CInvoiceRequest:
-			respMsg.Type = C2SInvoiceResponse
-		}
-		payload, _ := json.Marshal(responsePayload)
-		respMsg.Payload = payload
+		c.writeError(ctx, msg.ID, err, latency)
+		return
+	}
+
+	respMsg := Message{ID: msg.ID, LatencyMs: latency.Milliseconds()} // Response ID is same as the request ID
+	switch msg.Type {
+	case S2CLNURLPRequest:
+		respMsg.Type = C2SLNURLPResponse
+	case S2CInvoiceRequest:
+		respMsg.Type = C2SInvoiceResponse
 	}
+	payload, _ := json.Marshal(responsePayload)
+	respMsg.Payload = payload
+
+	c.write(ctx, &respMsg)
+}
+
+func (c *session) writeError(ctx context.Context, id string, err error, latency time.Duration) {
+	payload, _ := json.Marshal(ErrorPayload{Message: err.Error()})
+	c.write(ctx, &Message{
+		ID:        id,
+		Type:      C2SError,
+		Payload:   payload,
+		LatencyMs: latency.Milliseconds(),
+	})
+}
 
+func (c *session) write(ctx context.Context, msg *Message) {
 	writeCtx, writeCancel := context.WithTimeout(ctx, 10*time.Second)
 	defer writeCancel()
-	if err := c.conn.WriteMessage(writeCtx, &respMsg); err != nil {
+	if err := c.conn.WriteMessage(writeCtx, msg); err != nil {
 		slog.Error("Failed to send response", "request_id", msg.ID, "error", err)
 	}
 }
@@ -0,0 +1,205 @@
+package oksusu
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler answers invoice requests once release is closed, and
+// reports each request it starts and every request whose context ends.
+type blockingHandler struct {
+	started  chan string
+	canceled chan string
+	release  chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started:  make(chan string, 8),
+		canceled: make(chan string, 8),
+		release:  make(chan struct{}),
+	}
+}
+
+func (h *blockingHandler) OnLNURLPRequest(context.Context, *LNURLRequestPayload) (*LNURLResponsePayload, error) {
+	return &LNURLResponsePayload{Tag: "payRequest"}, nil
+}
+
+func (h *blockingHandler) OnInvoiceRequest(ctx context.Context, p *InvoiceRequestPayload) (*InvoiceResponsePayload, error) {
+	h.started <- p.Comment
+	select {
+	case <-h.release:
+		return &InvoiceResponsePayload{PR: "lnbc1" + p.Comment}, nil
+	case <-ctx.Done():
+		h.canceled <- p.Comment
+		return nil, ctx.Err()
+	}
+}
+
+// fakeServer is the Oksu server side of one connection.
+type fakeServer struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+// startSession connects client to a fake Oksu server, authenticates and
+// returns the server side of the connection.
+func startSession(t *testing.T, client *Client) *fakeServer {
+	t.Helper()
+
+	accepted := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		accepted <- ws
+	}))
+	t.Cleanup(srv.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.serve(ctx, NewConn(ws))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	server := &fakeServer{t: t, ws: <-accepted}
+	t.Cleanup(func() { server.ws.Close() })
+
+	auth := server.recv()
+	require.Equal(t, C2SAuth, auth.Type)
+	server.send(Message{ID: auth.ID, Type: S2CAuthOK})
+	require.Eventually(t, client.Connected, time.Second, 10*time.Millisecond)
+	return server
+}
+
+func (s *fakeServer) send(msg Message) {
+	s.t.Helper()
+	require.NoError(s.t, s.ws.WriteJSON(msg))
+}
+
+func (s *fakeServer) requestInvoice(id, comment string) {
+	s.t.Helper()
+	payload, _ := json.Marshal(InvoiceRequestPayload{AmountMsat: 1000, Comment: comment})
+	s.send(Message{ID: id, Type: S2CInvoiceRequest, Payload: payload})
+}
+
+func (s *fakeServer) recv() Message {
+	s.t.Helper()
+	s.ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	require.NoError(s.t, s.ws.ReadJSON(&msg))
+	return msg
+}
+
+func errorMessage(t *testing.T, msg Message) string {
+	t.Helper()
+	require.Equal(t, C2SError, msg.Type)
+	var p ErrorPayload
+	require.NoError(t, json.Unmarshal(msg.Payload, &p))
+	return p.Message
+}
+
+func waitFor(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+		return ""
+	}
+}
+
+func TestClientRejectsOverConcurrencyLimit(t *testing.T) {
+	handler := newBlockingHandler()
+	server := startSession(t, NewClient("", "token", handler, 1))
+
+	server.requestInvoice("req-1", "a")
+	assert.Equal(t, "a", waitFor(t, handler.started))
+
+	server.requestInvoice("req-2", "b")
+	msg := server.recv()
+	assert.Equal(t, "req-2", msg.ID)
+	assert.Equal(t, "too many concurrent requests", errorMessage(t, msg))
+
+	close(handler.release)
+	msg = server.recv()
+	assert.Equal(t, "req-1", msg.ID)
+	assert.Equal(t, C2SInvoiceResponse, msg.Type)
+}
+
+func TestClientRejectsDuplicateRequestID(t *testing.T) {
+	handler := newBlockingHandler()
+	server := startSession(t, NewClient("", "token", handler, 4))
+
+	server.requestInvoice("req-1", "a")
+	assert.Equal(t, "a", waitFor(t, handler.started))
+
+	server.requestInvoice("req-1", "b")
+	msg := server.recv()
+	assert.Equal(t, "req-1", msg.ID)
+	assert.Equal(t, "duplicate request id", errorMessage(t, msg))
+
+	close(handler.release)
+	msg = server.recv()
+	assert.Equal(t, "req-1", msg.ID)
+	assert.Equal(t, C2SInvoiceResponse, msg.Type)
+}
+
+func TestClientCancelsInflightRequest(t *testing.T) {
+	handler := newBlockingHandler()
+	server := startSession(t, NewClient("", "token", handler, 4))
+
+	server.requestInvoice("req-1", "a")
+	server.requestInvoice("req-2", "b")
+	waitFor(t, handler.started)
+	waitFor(t, handler.started)
+
+	payload, _ := json.Marshal(CancelPayload{RequestID: "req-1"})
+	server.send(Message{ID: "cancel-1", Type: S2CCancel, Payload: payload})
+	assert.Equal(t, "a", waitFor(t, handler.canceled))
+
+	// The canceled request gets no response; the other one still does.
+	close(handler.release)
+	msg := server.recv()
+	assert.Equal(t, "req-2", msg.ID)
+	assert.Equal(t, C2SInvoiceResponse, msg.Type)
+}
+
+func TestClientDropsResponsesOfClosedSession(t *testing.T) {
+	handler := newBlockingHandler()
+	client := NewClient("", "token", handler, 4)
+
+	old := startSession(t, client)
+	old.requestInvoice("req-1", "a")
+	waitFor(t, handler.started)
+
+	// The server drops the connection and the client reconnects; the request
+	// from the old session must not be answered on the new one.
+	old.ws.Close()
+	assert.Equal(t, "a", waitFor(t, handler.canceled))
+
+	server := startSession(t, client)
+	server.requestInvoice("req-2", "b")
+	waitFor(t, handler.started)
+	close(handler.release)
+
+	msg := server.recv()
+	assert.Equal(t, "req-2", msg.ID)
+}
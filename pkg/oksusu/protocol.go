@@ -18,12 +18,17 @@ const (
 	S2CLNURLPRequest  MessageType = "s2c_lnurlp_request"
 	S2CInvoiceRequest MessageType = "s2c_invoice_request"
 	S2CError          MessageType = "s2c_error"
+	S2CCancel         MessageType = "s2c_cancel"
 )
 
 type Message struct {
 	ID      string          `json:"id"`
 	Type    MessageType     `json:"type"`
 	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// LatencyMs is set on responses to report how long the handler took,
+	// so the server can monitor the client side of each request.
+	LatencyMs int64 `json:"latency_ms,omitempty"`
 }
 
 type AuthRequestPayload struct {
@@ -51,9 +56,10 @@ type LNURLResponsePayload struct {
 }
 
 type InvoiceRequestPayload struct {
-	AmountMsat int64  `json:"amount_msat"`
-	Comment    string `json:"comment,omitempty"`
-	NostrZap   string `json:"nostr_zap,omitempty"` // URL-decoded nostr event JSON string
+	AmountMsat int64           `json:"amount_msat"`
+	Comment    string          `json:"comment,omitempty"`
+	NostrZap   string          `json:"nostr_zap,omitempty"` // URL-decoded nostr event JSON string
+	PayerData  json.RawMessage `json:"payerdata,omitempty"` // LUD-18 payer data
 }
 
 type InvoiceResponsePayload struct {
@@ -68,6 +74,11 @@ type SuccessActionPayload struct {
 	URL     string `json:"url,omitempty"`
 }
 
+// CancelPayload asks the client to abandon an in-flight request.
+type CancelPayload struct {
+	RequestID string `json:"request_id"`
+}
+
 type ErrorPayload struct {
 	Message string `json:"message"`
 }